@@ -0,0 +1,111 @@
+/**
+xenv-schema 根据配置 Bean 生成 JSON Schema、示例配置文件，配置项解析规则和 xenv.BindProperties 一致
+
+用法：
+	xenv-schema -pkg github.com/example/app/config -type AppConfig -prefix app. -format schema -o app.schema.json
+
+支持的格式：schema、properties、yaml、keys
+
+实现方式：在当前目录下生成一个临时的 main 程序，导入配置 Bean 所在的包，调用 xenv.WriteConfigSchema 输出，
+因此需要在能够引用到目标包的 module 中执行
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"text/template"
+)
+
+var (
+	pkgPath = flag.String("pkg", "", "配置 Bean 所在包的 import path，必填")
+	typName = flag.String("type", "", "配置 Bean 类型名称，必填")
+	prefix  = flag.String("prefix", "", "配置前缀，和 BindProperties 的 keyPrefix 一致")
+	format  = flag.String("format", "schema", "输出格式：schema、properties、yaml、keys")
+	output  = flag.String("o", "", "输出文件，默认输出到标准输出")
+)
+
+var identRegex = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+var programTemplate = template.Must(template.New("main").Parse(`package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xkgo/xkit/xenv"
+	target {{ printf "%q" .Pkg }}
+)
+
+func main() {
+	if err := xenv.WriteConfigSchema(os.Stdout, xenv.ConfigSchemaFormat({{ printf "%q" .Format }}), {{ printf "%q" .Prefix }}, &target.{{ .Type }}{}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+func main() {
+	flag.Parse()
+	if len(*pkgPath) < 1 || !identRegex.MatchString(*typName) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := generate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if len(*output) < 1 {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+	if err = ioutil.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+/**
+生成临时程序并执行，返回程序的标准输出
+*/
+func generate() ([]byte, error) {
+	program := &bytes.Buffer{}
+	err := programTemplate.Execute(program, map[string]string{
+		"Pkg":    *pkgPath,
+		"Type":   *typName,
+		"Prefix": *prefix,
+		"Format": *format,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 临时程序放在当前目录下，这样才能使用当前 module 的依赖
+	dir, err := ioutil.TempDir(".", "xenv-schema-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "main.go"), program.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.Command("go", "run", "./"+filepath.Base(dir))
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("生成失败：%v", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,247 @@
+package xenv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xkgo/xkit/xcontext"
+	"github.com/xkgo/xkit/xlog"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// 默认在内存中保留的审计记录数
+	DefaultAuditLogCapacity = 1000
+
+	// 运行时覆盖配置的配置来源名称，优先级最高，参考 RuntimeOverrides
+	RuntimeOverridePropertySourceName = "runtimeOverrides"
+)
+
+/**
+配置变更审计记录，记录的是配置项生效值的变更
+*/
+type AuditEntry struct {
+	Seq        int64         `json:"seq"`  // 递增的序号，从 1 开始，用于回滚，参考 RollbackRuntimeOverrides
+	Time       time.Time     `json:"time"` // 变更时间
+	Source     string        `json:"source"`
+	Key        string        `json:"key"`
+	ChangeType KeyChangeType `json:"changeType"`
+	Ov         string        `json:"ov"`                // 变更之前的生效值，敏感配置脱敏
+	Nv         string        `json:"nv"`                // 变更之后的生效值，敏感配置脱敏
+	TraceId    string        `json:"traceId,omitempty"` // 触发变更的调用链 ID，参考 xcontext.GetTraceId
+
+	runtimeOverride bool   // 是否是运行时覆盖配置来源中的变更
+	sourceOv        string // 运行时覆盖配置来源中的原始旧值，用于回滚
+	sourceOexists   bool
+}
+
+func (e *AuditEntry) String() string {
+	return fmt.Sprintf("#%d [%s][%s][%s] ov:[%s], nv:[%s], traceId:[%s]", e.Seq, e.Source, e.ChangeType, e.Key, e.Ov, e.Nv, e.TraceId)
+}
+
+/**
+内存中保留最近 capacity 条审计记录，可以同时追加到 JSON lines 格式的审计文件
+*/
+type auditLog struct {
+	lock     sync.Mutex
+	entries  []*AuditEntry // 环形缓冲区
+	start    int           // 最早一条记录的下标
+	size     int
+	seq      int64
+	file     *os.File
+	filePath string
+}
+
+/**
+内存中保留的审计记录数，默认 DefaultAuditLogCapacity，小于等于 0 的话不在内存中保留，也就无法回滚
+*/
+func AuditLogCapacity(capacity int) Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.auditLogCapacity = capacity
+	}
+}
+
+/**
+审计记录同时以 JSON lines 的格式追加到文件，文件无法打开的话 New 的时候 panic
+*/
+func AuditLogFile(filePath string) Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.auditLogFile = filePath
+	}
+}
+
+func newAuditLog(capacity int, filePath string) *auditLog {
+	log := &auditLog{filePath: filePath}
+	if capacity > 0 {
+		log.entries = make([]*AuditEntry, capacity)
+	}
+	if len(filePath) > 0 {
+		file, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			panic("打开配置审计文件[" + filePath + "]失败：" + err.Error())
+		}
+		log.file = file
+	}
+	return log
+}
+
+func (l *auditLog) append(entry *AuditEntry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.seq++
+	entry.Seq = l.seq
+
+	if capacity := len(l.entries); capacity > 0 {
+		if l.size < capacity {
+			l.entries[(l.start+l.size)%capacity] = entry
+			l.size++
+		} else {
+			l.entries[l.start] = entry
+			l.start = (l.start + 1) % capacity
+		}
+	}
+
+	if l.file != nil {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			_, err = l.file.Write(append(data, '\n'))
+		}
+		if err != nil {
+			xlog.Error("写入配置审计文件["+l.filePath+"]失败：", err)
+		}
+	}
+}
+
+/**
+内存中保留的审计记录，按照序号排序
+*/
+func (l *auditLog) list() []*AuditEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entries := make([]*AuditEntry, 0, l.size)
+	for i := 0; i < l.size; i++ {
+		entries = append(entries, l.entries[(l.start+i)%len(l.entries)])
+	}
+	return entries
+}
+
+func (l *auditLog) lastSeq() int64 {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.seq
+}
+
+func (l *auditLog) close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+/**
+记录配置变更，运行时覆盖配置来源中的变更即使生效值没有变化也会记录，保证可以回滚
+@param event 配置来源发出的变更事件
+@param effective 生效值的变更，为 nil 表示生效值没有变化
+*/
+func (s *StandardEnvironment) audit(source PropertySource, event *KeyChangeEvent, effective *KeyChangeEvent) {
+	if s.auditLog == nil {
+		return
+	}
+	runtimeOverride := source.GetName() == RuntimeOverridePropertySourceName && event != nil
+	if effective == nil {
+		if !runtimeOverride {
+			return
+		}
+		effective = event
+	}
+	entry := &AuditEntry{
+		Time:       time.Now(),
+		Source:     source.GetName(),
+		Key:        effective.Key,
+		ChangeType: effective.ChangeType,
+		Ov:         s.MaskPropertyValue(effective.Key, effective.Ov),
+		Nv:         s.MaskPropertyValue(effective.Key, effective.Nv),
+		TraceId:    xcontext.GetTraceId(),
+	}
+	if runtimeOverride {
+		entry.runtimeOverride = true
+		entry.sourceOv = event.Ov
+		entry.sourceOexists = event.ChangeType != PropertyAdd
+	}
+	s.auditLog.append(entry)
+}
+
+/**
+内存中保留的配置变更审计记录，按照时间排序，最多保留 AuditLogCapacity 条
+*/
+func (s *StandardEnvironment) AuditLog() []*AuditEntry {
+	if s.auditLog == nil {
+		return make([]*AuditEntry, 0)
+	}
+	return s.auditLog.list()
+}
+
+/**
+运行时覆盖配置的配置来源，优先级最高，不存在的话创建并添加到最前面，变更会触发配置变更事件并记录审计日志；
+同名的配置来源不是 *MapPropertySource 的话 panic
+*/
+func (s *StandardEnvironment) RuntimeOverrides() *MapPropertySource {
+	s.runtimeOverridesLock.Lock()
+	defer s.runtimeOverridesLock.Unlock()
+	if source, exists := s.propertySources.Get(RuntimeOverridePropertySourceName); exists {
+		overrides, ok := source.(*MapPropertySource)
+		if !ok {
+			panic("配置来源[" + RuntimeOverridePropertySourceName + "]必须是 *MapPropertySource 类型，实际为：" + reflect.TypeOf(source).String())
+		}
+		return overrides
+	}
+	overrides := NewMapPropertySource(RuntimeOverridePropertySourceName, nil)
+	s.propertySources.AddFirst(overrides)
+	return overrides
+}
+
+/**
+将运行时覆盖的配置回滚到审计记录 seq 对应的时间点，即撤销 seq 之后运行时覆盖配置来源中的所有变更，seq 为 0 表示撤销全部变更；
+回滚本身也会触发配置变更事件并记录审计日志；会先等待运行时覆盖配置来源中还没有记录的变更，所以不能在配置变更监听器中调用
+@return 回滚的配置 key，按照变更顺序
+*/
+func (s *StandardEnvironment) RollbackRuntimeOverrides(seq int64) ([]string, error) {
+	overrides := s.RuntimeOverrides()
+	// 变更事件是异步记录的，刚刚 Put 的变更可能还没有审计记录
+	overrides.pending.wait()
+	entries := s.AuditLog()
+	if len(entries) > 0 && entries[0].Seq > seq+1 {
+		return nil, fmt.Errorf("审计记录[%d]之后的部分记录已经被淘汰，最早的记录为[%d]，无法回滚", seq, entries[0].Seq)
+	}
+	if len(entries) < 1 && seq < s.auditLog.lastSeq() {
+		return nil, errors.New("内存中没有保留审计记录，无法回滚，参考 AuditLogCapacity")
+	}
+
+	keys := make([]string, 0)
+	restored := make(map[string]bool)
+	for _, entry := range entries {
+		// 同一个 key 只需要恢复 seq 之后第一次变更之前的值
+		if entry.Seq <= seq || !entry.runtimeOverride || restored[entry.Key] {
+			continue
+		}
+		restored[entry.Key] = true
+		keys = append(keys, entry.Key)
+		if entry.sourceOexists {
+			overrides.Put(entry.Key, entry.sourceOv)
+		} else {
+			overrides.Remove(entry.Key)
+		}
+	}
+	xlog.Info("回滚运行时覆盖的配置到审计记录[", seq, "]：", keys)
+	return keys, nil
+}
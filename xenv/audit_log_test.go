@@ -0,0 +1,146 @@
+package xenv
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkgo/xkit/xcontext"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newAuditTestEnvironment(options ...Option) (*StandardEnvironment, *MapPropertySource, *MapPropertySource) {
+	high := NewMapPropertySource("high", map[string]string{"app.name": "high"})
+	low := NewMapPropertySource("low", map[string]string{"app.name": "low", "app.port": "8080"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(high)
+	sources.AddLast(low)
+	options = append(options, IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+	return New(options...), high, low
+}
+
+func awaitAuditLog(t *testing.T, env *StandardEnvironment, size int) []*AuditEntry {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		entries := env.AuditLog()
+		if len(entries) >= size {
+			return entries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待审计记录超时，期望：%d，实际：%d", size, len(entries))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStandardEnvironment_AuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenv-audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	auditFile := filepath.Join(dir, "audit.log")
+
+	env, high, low := newAuditTestEnvironment(AuditLogCapacity(2), AuditLogFile(auditFile))
+
+	xcontext.BindContext("trace-1", nil, nil)
+	low.Put("app.port", "9090")
+	xcontext.UnBindContext()
+	entries := awaitAuditLog(t, env, 1)
+	assert.Equal(t, int64(1), entries[0].Seq)
+	assert.Equal(t, "low", entries[0].Source)
+	assert.Equal(t, "app.port", entries[0].Key)
+	assert.Equal(t, PropertyUpdate, entries[0].ChangeType)
+	assert.Equal(t, "8080", entries[0].Ov)
+	assert.Equal(t, "9090", entries[0].Nv)
+	assert.Equal(t, "trace-1", entries[0].TraceId)
+
+	// 被优先级更高的配置来源覆盖，生效值没有变化，不记录
+	low.Put("app.name", "low2")
+	high.Put("app.password", "123456")
+	entries = awaitAuditLog(t, env, 2)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "app.password", entries[1].Key)
+	assert.Equal(t, "******", entries[1].Nv)
+
+	// 超过容量淘汰最早的记录
+	high.Remove("app.name")
+	deadline := time.Now().Add(3 * time.Second)
+	for env.AuditLog()[0].Seq != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries = env.AuditLog()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, int64(3), entries[1].Seq)
+	assert.Equal(t, "high", entries[1].Ov)
+	assert.Equal(t, "low2", entries[1].Nv)
+
+	assert.Nil(t, env.Close(nil))
+	file, err := os.Open(auditFile)
+	assert.Nil(t, err)
+	defer file.Close()
+	lines := make([]*AuditEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := &AuditEntry{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), entry))
+		lines = append(lines, entry)
+	}
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "trace-1", lines[0].TraceId)
+	assert.Equal(t, "******", lines[1].Nv)
+}
+
+func TestStandardEnvironment_RollbackRuntimeOverrides(t *testing.T) {
+	env, _, _ := newAuditTestEnvironment()
+	overrides := env.RuntimeOverrides()
+	assert.Equal(t, overrides, env.RuntimeOverrides())
+
+	overrides.Put("app.port", "9090")
+	awaitAuditLog(t, env, 1)
+	overrides.Put("app.name", "override")
+	overrides.Put("app.timeout", "10s")
+	entries := awaitAuditLog(t, env, 3)
+	point := entries[0].Seq
+	overrides.Put("app.port", "9091")
+	awaitAuditLog(t, env, 4)
+
+	keys, err := env.RollbackRuntimeOverrides(point)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"app.name", "app.timeout", "app.port"}, keys)
+	awaitAuditLog(t, env, 7)
+	assert.Equal(t, "9090", env.GetPropertyWithDef("app.port", ""))
+	assert.Equal(t, "high", env.GetPropertyWithDef("app.name", ""))
+	assert.False(t, env.ContainsProperty("app.timeout"))
+
+	keys, err = env.RollbackRuntimeOverrides(0)
+	assert.Nil(t, err)
+	assert.Contains(t, keys, "app.port")
+	awaitAuditLog(t, env, 8)
+	assert.Equal(t, "8080", env.GetPropertyWithDef("app.port", ""))
+	_, exists := overrides.GetProperty("app.port")
+	assert.False(t, exists)
+
+	// 记录已经被淘汰
+	env, _, _ = newAuditTestEnvironment(AuditLogCapacity(1))
+	env.RuntimeOverrides().Put("app.port", "1")
+	awaitAuditLog(t, env, 1)
+	env.RuntimeOverrides().Put("app.port", "2")
+	deadline := time.Now().Add(3 * time.Second)
+	for env.AuditLog()[0].Seq != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = env.RollbackRuntimeOverrides(0)
+	assert.NotNil(t, err)
+
+	// 刚刚覆盖的配置还没有审计记录，也要能回滚
+	env, _, _ = newAuditTestEnvironment()
+	overrides = env.RuntimeOverrides()
+	overrides.Put("app.port", "9090")
+	keys, err = env.RollbackRuntimeOverrides(0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app.port"}, keys)
+	_, exists = overrides.GetProperty("app.port")
+	assert.False(t, exists)
+}
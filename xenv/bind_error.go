@@ -0,0 +1,190 @@
+package xenv
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/xkgo/xkit/xlog"
+	"github.com/xkgo/xkit/xreflect"
+	"reflect"
+	"strings"
+)
+
+const (
+	// 配置项不存在，使用属性 def tag 默认值时的配置来源名称
+	DefaultValuePropertySourceName = "def"
+)
+
+/**
+配置项转换失败信息
+*/
+type BindError struct {
+	Key        string       // 配置 key
+	Value      string       // 配置原始值，没有处理占位符，使用默认值的话为 def tag 的值
+	Resolved   string       // 处理占位符之后的值，也就是实际转换的值
+	TargetType reflect.Type // 属性类型
+	Source     string       // 配置来源，参考 GetPropertyOrigin，使用默认值的话为 DefaultValuePropertySourceName
+	Err        error        // 转换失败原因
+	secret     bool         // 是否是当前环境中的敏感配置，输出的时候脱敏
+}
+
+func (e *BindError) Error() string {
+	value := e.maskValue(e.Value)
+	if e.Resolved != e.Value {
+		value += "] => [" + e.maskValue(e.Resolved)
+	}
+	return fmt.Sprintf("配置项[%s]的值[%s]无法转换成[%v]类型, 配置来源：[%s], err:%v",
+		e.Key, value, e.TargetType, e.Source, e.Err)
+}
+
+func (e *BindError) maskValue(value string) string {
+	if e.secret && len(value) > 0 {
+		return SecretMask
+	}
+	return MaskPropertyValue(e.Key, value)
+}
+
+/**
+配置 Bean 所有的转换失败信息，严格绑定模式下 BindProperties 返回这个异常
+*/
+type BindErrors []*BindError
+
+func (e BindErrors) Error() string {
+	buf := bytes.Buffer{}
+	buf.WriteString("配置绑定失败：")
+	for _, err := range e {
+		buf.WriteString("\n\t" + err.Error())
+	}
+	return buf.String()
+}
+
+/**
+合并转换失败信息，err 不是 BindErrors 的话返回 false
+*/
+func (e *BindErrors) merge(err error) bool {
+	errs, ok := err.(BindErrors)
+	if ok {
+		*e = append(*e, errs...)
+	}
+	return ok
+}
+
+func (e BindErrors) errorOrNil() error {
+	if len(e) < 1 {
+		return nil
+	}
+	return e
+}
+
+/**
+非严格模式下忽略转换失败信息，转换失败只会打印日志，属性保持原值
+*/
+func filterBindErrors(err error, strict bool) error {
+	if _, ok := err.(BindErrors); ok && !strict {
+		return nil
+	}
+	return err
+}
+
+/**
+BindProperties 单次调用的选项
+*/
+type BindOption func(options *bindOptions)
+
+type bindOptions struct {
+	strict bool
+}
+
+/**
+是否使用严格绑定模式，覆盖全局的 StrictBinding 配置，参考 StrictBinding
+*/
+func StrictBind(strict bool) BindOption {
+	return func(options *bindOptions) {
+		options.strict = strict
+	}
+}
+
+/**
+全局使用严格绑定模式：配置项转换失败的时候 BindProperties 返回 BindErrors，热更新时拒绝更新并通知 OnRebindFailure 注册的监听器；
+默认只打印日志，属性保持原值
+*/
+func StrictBinding() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.strictBinding = true
+	}
+}
+
+/**
+配置项当前生效值所在的配置来源
+*/
+func (s *StandardEnvironment) propertySourceOrigin(key string) string {
+	origin := ""
+	keys := []string{key}
+	for _, info := range s.deprecatedKeys.oldKeys(key) {
+		keys = append(keys, info.OldKey)
+	}
+	for _, item := range keys {
+		s.propertySources.Each(func(index int, source PropertySource) (stop bool) {
+			if _, ok := source.GetProperty(item); ok {
+				origin = GetPropertyOrigin(source, item)
+				return true
+			}
+			return false
+		})
+		if len(origin) > 0 {
+			return origin
+		}
+	}
+	return DefaultValuePropertySourceName
+}
+
+/**
+属性绑定的配置原始值，没有处理占位符：数组、切片属性使用 key[n] 形式配置的话为组装的值，
+配置项（包括废弃的旧 key）不存在的话为 def tag 的值
+*/
+func (s *StandardEnvironment) rawFieldValue(configKey string, tfield reflect.StructField, value string) string {
+	if isListType(tfield.Type) && s.isIndexedListProperty(configKey) {
+		return value
+	}
+	keys := []string{configKey}
+	for _, info := range s.deprecatedKeys.oldKeys(configKey) {
+		keys = append(keys, info.OldKey)
+	}
+	for _, key := range keys {
+		if raw, ok := s.rawProperty(key); ok {
+			return raw
+		}
+	}
+	return tfield.Tag.Get("def")
+}
+
+/**
+检查配置值是否能够转换成属性类型，空值表示使用零值，不检查
+*/
+func (s *StandardEnvironment) checkBeanPropertyValue(configKey string, tfield reflect.StructField, value string) *BindError {
+	if len(strings.TrimSpace(value)) < 1 {
+		return nil
+	}
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		_, err = xreflect.ConvertTo(value, tfield.Type)
+	}()
+	if err == nil {
+		return nil
+	}
+	return &BindError{Key: configKey, Value: s.rawFieldValue(configKey, tfield, value), Resolved: value, TargetType: tfield.Type, Source: s.propertySourceOrigin(configKey), Err: err,
+		secret: isSecretField(tfield, configKey) || s.IsSecretKey(configKey)}
+}
+
+/**
+热更新失败（严格模式下转换失败或者占位符处理失败），拒绝本次更新并通知监听器
+*/
+func (s *StandardEnvironment) rejectBeanChange(bean *boundBean, key string, err error) {
+	failure := &RebindFailure{KeyPrefix: bean.keyPrefix, Bean: bean.beanPtr, ChangedKeys: []string{key}, Err: err}
+	xlog.Error(failure.Error())
+	s.rebindFailureListeners.publish(failure)
+}
@@ -0,0 +1,121 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type StrictServerConfig struct {
+	Port    int                          `ck:"port" def:"8080"`
+	Timeout time.Duration                `ck:"timeout"`
+	Debug   bool                         `ck:"debug"`
+	Pools   map[string]*StrictPoolConfig `ck:"pools" expand:"true"`
+}
+
+// 绑定时会处理所有属性，所以回调通知放在 Bean 之外
+var strictServerConfigChanged = make(chan []string, 2)
+
+func (c *StrictServerConfig) OnPropertiesChanged(changedKeys []string) {
+	strictServerConfigChanged <- changedKeys
+}
+
+type StrictPoolConfig struct {
+	Size int `ck:"size"`
+}
+
+func TestStandardEnvironment_StrictBinding(t *testing.T) {
+	newEnv := func(properties map[string]string, options ...Option) (*StandardEnvironment, *MapPropertySource) {
+		source := NewMapPropertySource("test", properties)
+		sources := NewMutablePropertySources()
+		sources.AddLast(source)
+		options = append(options, IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(),
+			AdditionalPropertySources(sources), RebindCallbackDelay(10*time.Millisecond))
+		return New(options...), source
+	}
+
+	properties := map[string]string{"server.port": "abc", "server.debug": "yes?", "server.pools.a.size": "x"}
+
+	// 默认非严格模式，只打印日志
+	env, _ := newEnv(properties)
+	cfg := &StrictServerConfig{}
+	_, err := env.BindProperties("server.", cfg, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, cfg.Port)
+
+	// 单次调用指定严格模式
+	_, err = env.BindProperties("server.", &StrictServerConfig{}, false, StrictBind(true))
+	bindErrs, ok := err.(BindErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(bindErrs))
+	assert.Equal(t, "server.port", bindErrs[0].Key)
+	assert.Equal(t, "abc", bindErrs[0].Value)
+	assert.Equal(t, reflect.TypeOf(0), bindErrs[0].TargetType)
+	assert.Equal(t, "test", bindErrs[0].Source)
+	assert.Equal(t, "server.debug", bindErrs[1].Key)
+	assert.Equal(t, "server.pools.a.size", bindErrs[2].Key)
+
+	// 全局严格模式，单次调用可以关闭
+	env, source := newEnv(map[string]string{"server.port": "abc"}, StrictBinding())
+	_, err = env.BindProperties("server.", &StrictServerConfig{}, false)
+	assert.NotNil(t, err)
+	_, err = env.BindProperties("server.", &StrictServerConfig{}, false, StrictBind(false))
+	assert.Nil(t, err)
+
+	// 严格模式下热更新转换失败，拒绝更新
+	source.Put("server.port", "9090")
+	time.Sleep(20 * time.Millisecond)
+	cfg = &StrictServerConfig{}
+	_, err = env.BindProperties("server.", cfg, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 9090, cfg.Port)
+
+	failures := make(chan *RebindFailure, 2)
+	env.OnRebindFailure(func(failure *RebindFailure) {
+		failures <- failure
+	})
+	source.Put("server.port", "90a")
+	select {
+	case failure := <-failures:
+		assert.Equal(t, []string{"server.port"}, failure.ChangedKeys)
+		errs, ok := failure.Err.(BindErrors)
+		assert.True(t, ok)
+		assert.Equal(t, "90a", errs[0].Value)
+	case <-time.After(time.Second):
+		t.Fatal("未收到热更新失败通知")
+	}
+	assert.Equal(t, 9090, cfg.Port)
+
+	source.Put("server.pools.a.size", "x")
+	select {
+	case failure := <-failures:
+		assert.Equal(t, []string{"server.pools.a.size"}, failure.ChangedKeys)
+	case <-time.After(time.Second):
+		t.Fatal("未收到热更新失败通知")
+	}
+	assert.Equal(t, 0, len(cfg.Pools))
+
+	source.Put("server.port", "9091")
+	select {
+	case keys := <-strictServerConfigChanged:
+		assert.Equal(t, []string{"server.port"}, keys)
+		assert.Equal(t, 9091, cfg.Port)
+	case <-time.After(time.Second):
+		t.Fatal("未收到 OnPropertiesChanged 回调")
+	}
+}
+
+func TestBindError_RawValue(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", map[string]string{"server.port": "${server.base}", "server.base": "abc"}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	_, err := env.BindProperties("server.", &StrictServerConfig{}, false, StrictBind(true))
+	bindErrs, ok := err.(BindErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(bindErrs))
+	assert.Equal(t, "${server.base}", bindErrs[0].Value)
+	assert.Equal(t, "abc", bindErrs[0].Resolved)
+	assert.Contains(t, bindErrs[0].Error(), "的值[${server.base}] => [abc]无法转换")
+}
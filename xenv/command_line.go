@@ -3,38 +3,136 @@ package xenv
 import (
 	"github.com/xkgo/xkit/xstr"
 	"os"
+	"strconv"
 	"strings"
 )
 
 /**
-获取命令行参数, 命令行参数格式：
---{key}={value}
-如：
-./app.exe --env=test --set=sg --cluster=asia
+命令行参数解析结果
 */
-func GetCommandLineProperties(appendCommandLine string) map[string]string {
-	var args = os.Args
-	if len(appendCommandLine) > 4 {
-		args = append(args, xstr.SplitByRegex(appendCommandLine, "\\s+")...)
+type CommandLineArgs struct {
+	/**
+	选项参数，同一个 key 出现多次的话，按照出现顺序使用英文逗号拼接，如：--tag=a --tag=b ==> tag=a,b
+	*/
+	Properties map[string]string
+
+	/**
+	选项参数的原始值列表，按照出现顺序
+	*/
+	Values map[string][]string
+
+	/**
+	非选项参数，如：./app.exe start -- --name=x ==> [start, --name=x]
+	*/
+	NonOptionArgs []string
+}
+
+/**
+解析命令行参数，支持的格式：
+	--key=value    ==> key=value
+	--key value    ==> key=value，下一个参数不是以 - 开头（负数除外，比如 --offset -1）的时候才会当成值，
+	                   所以 --flag 之后的非选项参数会被当成值，非选项参数需要放在选项之前或者 -- 之后
+	--flag         ==> flag=true
+	--no-flag      ==> flag=false，后面跟着值的话和 --no-flag=value 一样作为普通选项，
+	                   比如 --no-proxy host ==> no-proxy=host，不会把 host 当成非选项参数
+	-Dkey=value    ==> key=value
+	-Dkey          ==> key=""
+	--             ==> 终止选项解析，之后的参数都作为非选项参数
+同一个 key 重复出现的时候，收集成列表，Properties 中使用英文逗号拼接
+*/
+func ParseCommandLine(args []string) *CommandLineArgs {
+	result := &CommandLineArgs{
+		Properties:    make(map[string]string),
+		Values:        make(map[string][]string),
+		NonOptionArgs: make([]string, 0),
 	}
 
-	properties := make(map[string]string)
+	terminated := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if terminated {
+			result.NonOptionArgs = append(result.NonOptionArgs, arg)
+			continue
+		}
+		if arg == "--" {
+			terminated = true
+			continue
+		}
 
-	for _, arg := range args {
-		if len(arg) < 4 || !strings.HasPrefix(arg, "--") { // 至少四个字符， --k=
+		if strings.HasPrefix(arg, "--") {
+			body := arg[2:]
+			if index := strings.Index(body, "="); index >= 0 {
+				result.add(xstr.Trim(body[0:index]), xstr.Trim(body[index+1:]))
+				continue
+			}
+			key := xstr.Trim(body)
+			if i+1 < len(args) && isCommandLineValue(args[i+1]) {
+				result.add(key, xstr.Trim(args[i+1]))
+				i++
+				continue
+			}
+			if strings.HasPrefix(key, "no-") && len(key) > 3 {
+				result.add(key[3:], "false")
+				continue
+			}
+			result.add(key, "true")
 			continue
 		}
-		// 替换一次
-		arg = xstr.Trim(strings.Replace(arg, "--", "", 1))
-		index := strings.Index(arg, "=")
-		if index < 1 {
+
+		if strings.HasPrefix(arg, "-D") && len(arg) > 2 {
+			body := arg[2:]
+			if index := strings.Index(body, "="); index >= 0 {
+				result.add(xstr.Trim(body[0:index]), xstr.Trim(body[index+1:]))
+			} else {
+				result.add(xstr.Trim(body), "")
+			}
 			continue
 		}
 
-		key := xstr.Trim(arg[0:index])
-		value := xstr.Trim(arg[index+1:])
+		result.NonOptionArgs = append(result.NonOptionArgs, arg)
+	}
+	return result
+}
+
+/**
+是否可以作为 --key value 中的值，不以 - 开头或者是负数
+*/
+func isCommandLineValue(arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return true
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
 
-		properties[key] = value
+func (c *CommandLineArgs) add(key, value string) {
+	if len(key) < 1 {
+		return
+	}
+	c.Values[key] = append(c.Values[key], value)
+	c.Properties[key] = strings.Join(c.Values[key], ",")
+}
+
+/**
+获取当前进程的命令行参数（不含程序名称），并追加 appendCommandLine 中的参数
+*/
+func commandLineArgs(appendCommandLine string) []string {
+	args := make([]string, 0)
+	if len(os.Args) > 1 {
+		args = append(args, os.Args[1:]...)
 	}
-	return properties
+	appendCommandLine = xstr.Trim(appendCommandLine)
+	if len(appendCommandLine) > 0 {
+		args = append(args, xstr.SplitByRegex(appendCommandLine, "\\s+")...)
+	}
+	return args
+}
+
+/**
+获取命令行参数, 命令行参数格式参考 ParseCommandLine
+如：
+./app.exe --env=test --set=sg --cluster=asia
+*/
+func GetCommandLineProperties(appendCommandLine string) map[string]string {
+	return ParseCommandLine(commandLineArgs(appendCommandLine)).Properties
 }
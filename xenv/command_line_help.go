@@ -0,0 +1,66 @@
+package xenv
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+const (
+	// 请求输出帮助信息的命令行参数，即 --help
+	CommandLineHelpKey = "help"
+)
+
+/**
+根据配置 Bean 生成命令行帮助信息，配置项解析规则参考 ResolveConfigFields，如：
+	--xlog.level=<string>    日志级别 (默认: DEBUG)
+@param keyPrefix 配置前缀
+@param cfg 配置 Bean 指针、配置 Bean 或者 reflect.Type
+*/
+func WriteCommandLineHelp(w io.Writer, keyPrefix string, cfg interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 4, ' ', 0)
+	if err := writeConfigFieldsHelp(tw, ResolveConfigFields(keyPrefix, cfg)); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func writeConfigFieldsHelp(w io.Writer, fields []*ConfigField) (err error) {
+	EachLeafConfigField(fields, func(field *ConfigField) (stop bool) {
+		line := "  --" + field.Key + "=<" + configFieldTypeName(field.Type) + ">\t" + field.Desc
+		if len(field.Def) > 0 {
+			line += " (默认: " + field.Def + ")"
+		}
+		_, err = fmt.Fprintln(w, line)
+		return err != nil
+	})
+	return
+}
+
+/**
+是否通过命令行请求了帮助信息，即命令行中包含 --help
+*/
+func (s *StandardEnvironment) IsHelpRequested() bool {
+	source, ok := s.GetPropertySources().Get(CommandLineEnvironmentPropertySourceName)
+	if !ok {
+		return false
+	}
+	value, ok := source.GetProperty(CommandLineHelpKey)
+	return ok && value != "false"
+}
+
+/**
+输出所有通过 BindProperties 绑定的配置 Bean 的命令行帮助信息
+*/
+func (s *StandardEnvironment) WriteCommandLineHelp(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "Options:"); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 4, ' ', 0)
+	for _, bean := range s.boundBeans {
+		if err := writeConfigFieldsHelp(tw, ResolveConfigFields(bean.keyPrefix, bean.beanPtr)); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
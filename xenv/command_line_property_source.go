@@ -1,58 +1,43 @@
 package xenv
 
-import (
-	"github.com/xkgo/xkit/xstr"
-	"os"
-	"strings"
-)
-
 const (
 	/** 命令行变量 PropertySource GetName */
 	CommandLineEnvironmentPropertySourceName = "commandLineEnvironment"
 )
 
 /***
-命令行参数解析，解析格式：
-格式： --key=value
+命令行参数解析，解析格式参考 ParseCommandLine
 实例：
 	--name=arvin ==> key=name, value = arvin
 	--name=      ==> key=name, value = ""
+	--name arvin ==> key=name, value = arvin
+	--debug      ==> key=debug, value = true
+	--no-debug   ==> key=debug, value = false
+	-Dname=arvin ==> key=name, value = arvin
 */
 type CommandLinePropertySource struct {
 	MapPropertySource
+	nonOptionArgs []string
 }
 
 func NewCommandLinePropertySource(appendCommandLine string) *CommandLinePropertySource {
-	var args []string = os.Args
-	if len(appendCommandLine) > 4 {
-		args = append(args, xstr.SplitByRegex(appendCommandLine, "\\s+")...)
-	}
-
-	name := CommandLineEnvironmentPropertySourceName
-	properties := make(map[string]string)
+	args := ParseCommandLine(commandLineArgs(appendCommandLine))
 
-	for _, arg := range args {
-		if len(arg) < 4 || !strings.HasPrefix(arg, "--") { // 至少四个字符， --k=
-			continue
-		}
-		// 替换一次
-		arg = xstr.Trim(strings.Replace(arg, "--", "", 1))
-		index := strings.Index(arg, "=")
-		if index < 1 {
-			continue
-		}
-
-		key := xstr.Trim(arg[0:index])
-		value := xstr.Trim(arg[index+1:])
-
-		properties[key] = value
+	source := &CommandLinePropertySource{
+		MapPropertySource: *NewMapPropertySource(CommandLineEnvironmentPropertySourceName, args.Properties),
+		nonOptionArgs:     args.NonOptionArgs,
 	}
 
-	source := &CommandLinePropertySource{*NewMapPropertySource(name, properties)}
-
 	return source
 }
 
+/**
+获取非选项参数，即不是以 --、-D 开头的参数，以及 -- 之后的所有参数
+*/
+func (c *CommandLinePropertySource) GetNonOptionArgs() []string {
+	return c.nonOptionArgs
+}
+
 /**
 获取指定的命令行参数
 */
@@ -60,24 +45,6 @@ func GetCommandLineProperty(key string) (value string, exists bool) {
 	if len(key) < 1 {
 		return "", false
 	}
-
-	for _, arg := range os.Args {
-		if len(arg) < 4 || !strings.HasPrefix(arg, "--") { // 至少四个字符， --k=
-			continue
-		}
-		// 替换一次
-		arg = xstr.Trim(strings.Replace(arg, "--", "", 1))
-		index := strings.Index(arg, "=")
-		if index < 1 {
-			continue
-		}
-
-		k := xstr.Trim(arg[0:index])
-
-		if k == key {
-			value := xstr.Trim(arg[index+1:])
-			return value, true
-		}
-	}
-	return "", false
+	value, exists = GetCommandLineProperties("")[key]
+	return
 }
@@ -0,0 +1,84 @@
+package xenv
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	args := ParseCommandLine([]string{
+		"start",
+		"--app.name=Test",
+		"--env", "dev",
+		"--debug",
+		"--no-cache",
+		"-Dserver.port=8080",
+		"--tag=a", "--tag", "b",
+		"--equal=1-2=x",
+		"--",
+		"--name=ignored",
+	})
+
+	assert.Equal(t, "Test", args.Properties["app.name"])
+	assert.Equal(t, "dev", args.Properties["env"])
+	assert.Equal(t, "true", args.Properties["debug"])
+	assert.Equal(t, "false", args.Properties["cache"])
+	assert.Equal(t, "8080", args.Properties["server.port"])
+	assert.Equal(t, "a,b", args.Properties["tag"])
+	assert.Equal(t, []string{"a", "b"}, args.Values["tag"])
+	assert.Equal(t, "1-2=x", args.Properties["equal"])
+	assert.Equal(t, []string{"start", "--name=ignored"}, args.NonOptionArgs)
+
+	_, exists := args.Properties["name"]
+	assert.False(t, exists)
+}
+
+func TestParseCommandLine_Values(t *testing.T) {
+	// 负数作为值
+	args := ParseCommandLine([]string{"--offset", "-1", "--ratio", "-0.5", "--debug", "-Dx=1"})
+	assert.Equal(t, "-1", args.Properties["offset"])
+	assert.Equal(t, "-0.5", args.Properties["ratio"])
+	assert.Equal(t, "true", args.Properties["debug"])
+	assert.Equal(t, "1", args.Properties["x"])
+
+	// 非选项参数放在选项之前或者 -- 之后，不会被 --flag 当成值
+	args = ParseCommandLine([]string{"start", "--debug", "--", "stop"})
+	assert.Equal(t, "true", args.Properties["debug"])
+	assert.Equal(t, []string{"start", "stop"}, args.NonOptionArgs)
+	args = ParseCommandLine([]string{"--debug", "stop"})
+	assert.Equal(t, "stop", args.Properties["debug"])
+	assert.Equal(t, 0, len(args.NonOptionArgs))
+
+	// --no-x 后面跟着值的话作为普通选项，和 --no-x=value 一致
+	args = ParseCommandLine([]string{"--no-proxy", "localhost", "--no-cache", "--no-check=1"})
+	assert.Equal(t, "localhost", args.Properties["no-proxy"])
+	assert.Equal(t, "false", args.Properties["cache"])
+	assert.Equal(t, "1", args.Properties["no-check"])
+	assert.Equal(t, 0, len(args.NonOptionArgs))
+	_, exists := args.Properties["proxy"]
+	assert.False(t, exists)
+}
+
+type HelpServerConfig struct {
+	Host string `ck:"host" def:"127.0.0.1" desc:"监听地址"`
+	Port int    `ck:"port" def:"8080" desc:"监听端口"`
+}
+
+type HelpConfig struct {
+	Name   string            `ck:"name" desc:"应用名称"`
+	Server *HelpServerConfig `ck:"server" expand:"true"`
+}
+
+func TestWriteCommandLineHelp(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteCommandLineHelp(buf, "app.", &HelpConfig{})
+	assert.Nil(t, err)
+
+	help := buf.String()
+	fmt.Println(help)
+	assert.Contains(t, help, "--app.name=<string>")
+	assert.Contains(t, help, "--app.server.host=<string>")
+	assert.Contains(t, help, "监听端口 (默认: 8080)")
+}
@@ -0,0 +1,155 @@
+package xenv
+
+import (
+	"github.com/xkgo/xkit/xstr"
+	"reflect"
+	"strings"
+)
+
+const (
+	// map 展开的时候，map key 部分在配置 key 中的占位表示
+	MapKeyPlaceholder = "<key>"
+)
+
+/**
+配置 Bean 属性描述，解析规则和 BindProperties 保持一致：
+	> key 默认是属性名首字母小写，可以通过 ck 或者 sk tag 指定
+	> def tag 为默认值
+	> desc tag 为配置项描述
+	> expand:"true" 的 struct、map 会继续展开
+*/
+type ConfigField struct {
+	Key      string       // 完整配置 key，map 展开的部分使用 MapKeyPlaceholder 占位
+	SubKey   string       // 相对于上级前缀的 key
+	Name     string       // 属性名称
+	Type     reflect.Type // 属性类型
+	Def      string       // 默认值
+	Desc     string       // 描述
+	Tag      reflect.StructTag
+	IsMap    bool           // 是否是展开的 map
+	IsStruct bool           // 是否是展开的 struct
+	Children []*ConfigField // 展开后的子属性，map 的话就是 value 类型的属性
+}
+
+/**
+是否是叶子配置项，即直接对应一个配置 key
+*/
+func (f *ConfigField) IsLeaf() bool {
+	return !f.IsMap && !f.IsStruct
+}
+
+/**
+解析配置 Bean 的所有配置项
+@param keyPrefix 配置前缀，和 BindProperties 的 keyPrefix 一致
+@param cfg 配置 Bean 指针、配置 Bean 或者 reflect.Type
+*/
+func ResolveConfigFields(keyPrefix string, cfg interface{}) []*ConfigField {
+	t, ok := cfg.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(cfg)
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return make([]*ConfigField, 0)
+	}
+	return resolveConfigFields(keyPrefix, t, map[reflect.Type]bool{})
+}
+
+func resolveConfigFields(keyPrefix string, t reflect.Type, visiting map[reflect.Type]bool) []*ConfigField {
+	fields := make([]*ConfigField, 0)
+	if visiting[t] {
+		return fields
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		tfield := t.Field(i)
+
+		subKey := configFieldSubKey(tfield)
+
+		field := &ConfigField{
+			Key:    keyPrefix + subKey,
+			SubKey: subKey,
+			Name:   tfield.Name,
+			Type:   tfield.Type,
+			Def:    tfield.Tag.Get("def"),
+			Desc:   tfield.Tag.Get("desc"),
+			Tag:    tfield.Tag,
+		}
+
+		if "true" == tfield.Tag.Get("expand") {
+			ft := tfield.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Map {
+				field.IsMap = true
+				vt := ft.Elem()
+				if vt.Kind() == reflect.Ptr {
+					vt = vt.Elem()
+				}
+				if vt.Kind() == reflect.Struct {
+					field.Children = resolveConfigFields(field.Key+"."+MapKeyPlaceholder+".", vt, visiting)
+				}
+			} else if ft.Kind() == reflect.Struct {
+				field.IsStruct = true
+				field.Children = resolveConfigFields(field.Key+".", ft, visiting)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+/**
+属性对应的配置 key（相对于上级前缀），默认是属性名首字母小写，可以通过 ck 或者 sk tag 指定
+*/
+func configFieldSubKey(tfield reflect.StructField) string {
+	subKey := tfield.Tag.Get("ck")
+	if len(subKey) < 1 {
+		subKey = tfield.Tag.Get("sk")
+	}
+	if len(subKey) < 1 {
+		subKey = xstr.FirstLetterLower(tfield.Name)
+	}
+	return subKey
+}
+
+/**
+遍历所有的叶子配置项，consumer 返回 true 则停止遍历
+*/
+func EachLeafConfigField(fields []*ConfigField, consumer func(field *ConfigField) (stop bool)) bool {
+	for _, field := range fields {
+		if field.IsLeaf() {
+			if consumer(field) {
+				return true
+			}
+			continue
+		}
+		if EachLeafConfigField(field.Children, consumer) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+类型的简短描述，用于帮助信息、示例配置等
+*/
+func configFieldTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map:
+		return "map"
+	case reflect.Struct:
+		return "object"
+	}
+	return strings.ToLower(t.Kind().String())
+}
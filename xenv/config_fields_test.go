@@ -0,0 +1,54 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type FieldsDbConfig struct {
+	Host string
+	Port int `ck:"port"`
+}
+
+type FieldsConfig struct {
+	Name    string
+	Db      FieldsDbConfig             `expand:"true"`
+	Replica *FieldsDbConfig            `sk:"replica" expand:"true"`
+	Pools   map[string]*FieldsDbConfig `expand:"true"`
+}
+
+func TestResolveConfigFields_BindParity(t *testing.T) {
+	// 按照 ResolveConfigFields 解析出来的 key 配置，BindProperties 要能全部绑定上
+	properties := make(map[string]string)
+	EachLeafConfigField(ResolveConfigFields("app.", &FieldsConfig{}), func(field *ConfigField) (stop bool) {
+		key := strings.Replace(field.Key, MapKeyPlaceholder, "a", -1)
+		if field.Type.Kind() == reflect.Int {
+			properties[key] = "3306"
+		} else {
+			properties[key] = key
+		}
+		return false
+	})
+	assert.Equal(t, map[string]string{
+		"app.name":         "app.name",
+		"app.db.host":      "app.db.host",
+		"app.db.port":      "3306",
+		"app.replica.host": "app.replica.host",
+		"app.replica.port": "3306",
+		"app.pools.a.host": "app.pools.a.host",
+		"app.pools.a.port": "3306",
+	}, properties)
+
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", properties))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+	cfg := &FieldsConfig{}
+	_, err := env.BindProperties("app.", cfg, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "app.name", cfg.Name)
+	assert.Equal(t, FieldsDbConfig{Host: "app.db.host", Port: 3306}, cfg.Db)
+	assert.Equal(t, &FieldsDbConfig{Host: "app.replica.host", Port: 3306}, cfg.Replica)
+	assert.Equal(t, &FieldsDbConfig{Host: "app.pools.a.host", Port: 3306}, cfg.Pools["a"])
+}
@@ -0,0 +1,271 @@
+package xenv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+配置描述输出格式
+*/
+type ConfigSchemaFormat string
+
+const (
+	ConfigSchemaJson       ConfigSchemaFormat = "schema"     // JSON Schema
+	ConfigSampleProperties ConfigSchemaFormat = "properties" // 带注释的示例 application.properties
+	ConfigSampleYaml       ConfigSchemaFormat = "yaml"       // 带注释的示例 application.yml
+	ConfigKeyList          ConfigSchemaFormat = "keys"       // 所有配置 key，每行一个
+)
+
+/**
+按照指定格式输出配置 Bean 的描述，配置项解析规则参考 ResolveConfigFields
+@param format 输出格式
+@param keyPrefix 配置前缀，和 BindProperties 的 keyPrefix 一致
+@param cfg 配置 Bean 指针、配置 Bean 或者 reflect.Type
+*/
+func WriteConfigSchema(w io.Writer, format ConfigSchemaFormat, keyPrefix string, cfg interface{}) error {
+	switch format {
+	case ConfigSchemaJson:
+		data, err := GenerateJsonSchema(keyPrefix, cfg)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case ConfigSampleProperties:
+		return WriteSampleProperties(w, keyPrefix, cfg)
+	case ConfigSampleYaml:
+		return WriteSampleYaml(w, keyPrefix, cfg)
+	case ConfigKeyList:
+		for _, key := range ConfigFieldKeys(keyPrefix, cfg) {
+			if _, err := fmt.Fprintln(w, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New("不支持的配置描述格式：" + string(format))
+}
+
+/**
+配置树节点，按照 key 中的 . 拆分层级
+*/
+type configNode struct {
+	name     string
+	field    *ConfigField // 叶子节点对应的配置项
+	children []*configNode
+}
+
+func (n *configNode) child(name string) *configNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	child := &configNode{name: name}
+	n.children = append(n.children, child)
+	return child
+}
+
+func buildConfigTree(keyPrefix string, cfg interface{}) *configNode {
+	root := &configNode{}
+	EachLeafConfigField(ResolveConfigFields(keyPrefix, cfg), func(field *ConfigField) (stop bool) {
+		node := root
+		for _, name := range strings.Split(field.Key, ".") {
+			if len(name) > 0 {
+				node = node.child(name)
+			}
+		}
+		node.field = field
+		return false
+	})
+	return root
+}
+
+/**
+生成 JSON Schema，配置 key 按照 . 拆分成嵌套的 object，map 展开的部分使用 additionalProperties 描述
+*/
+func GenerateJsonSchema(keyPrefix string, cfg interface{}) ([]byte, error) {
+	schema := configNodeSchema(buildConfigTree(keyPrefix, cfg))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func configNodeSchema(node *configNode) map[string]interface{} {
+	if node.field != nil && len(node.children) < 1 {
+		return configFieldSchema(node.field)
+	}
+	schema := map[string]interface{}{
+		"type": "object",
+	}
+	properties := make(map[string]interface{})
+	for _, child := range node.children {
+		if child.name == MapKeyPlaceholder {
+			schema["additionalProperties"] = configNodeSchema(child)
+			continue
+		}
+		properties[child.name] = configNodeSchema(child)
+	}
+	if len(properties) > 0 {
+		schema["properties"] = properties
+	}
+	return schema
+}
+
+func configFieldSchema(field *ConfigField) map[string]interface{} {
+	schema := jsonSchemaOfType(field.Type)
+	if len(field.Desc) > 0 {
+		schema["description"] = field.Desc
+	}
+	if len(field.Def) > 0 {
+		schema["default"] = jsonSchemaDefault(schema["type"], field.Def)
+	}
+	return schema
+}
+
+func jsonSchemaOfType(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOfType(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOfType(t.Elem())}
+	case reflect.Struct:
+		return map[string]interface{}{"type": "object"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+func jsonSchemaDefault(schemaType interface{}, def string) interface{} {
+	switch schemaType {
+	case "boolean":
+		if v, err := strconv.ParseBool(def); err == nil {
+			return v
+		}
+	case "integer":
+		if v, err := strconv.ParseInt(def, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(def, 64); err == nil {
+			return v
+		}
+	case "array", "object":
+		var v interface{}
+		if err := json.Unmarshal([]byte(def), &v); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+/**
+生成带注释的示例 properties 配置，每个配置项的值为默认值
+*/
+func WriteSampleProperties(w io.Writer, keyPrefix string, cfg interface{}) (err error) {
+	EachLeafConfigField(ResolveConfigFields(keyPrefix, cfg), func(field *ConfigField) (stop bool) {
+		text := ""
+		if len(field.Desc) > 0 {
+			text += "# " + field.Desc + "\n"
+		}
+		text += "# type: " + configFieldTypeName(field.Type) + "\n"
+		text += field.Key + "=" + field.Def + "\n\n"
+		_, err = io.WriteString(w, text)
+		return err != nil
+	})
+	return
+}
+
+/**
+生成带注释的示例 yaml 配置，每个配置项的值为默认值
+*/
+func WriteSampleYaml(w io.Writer, keyPrefix string, cfg interface{}) error {
+	return writeYamlNode(w, buildConfigTree(keyPrefix, cfg), 0)
+}
+
+func writeYamlNode(w io.Writer, node *configNode, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	for _, child := range node.children {
+		text := ""
+		if child.field != nil && len(child.field.Desc) > 0 {
+			text += indent + "# " + child.field.Desc + "\n"
+		}
+		name := child.name
+		if name == MapKeyPlaceholder {
+			name = strconv.Quote(name)
+		}
+		if len(child.children) > 0 {
+			text += indent + name + ":\n"
+		} else {
+			value := ""
+			if child.field != nil {
+				value = yamlSampleValue(child.field)
+			}
+			text += fmt.Sprintf("%s%s: %s\n", indent, name, value)
+		}
+		if _, err := io.WriteString(w, text); err != nil {
+			return err
+		}
+		if err := writeYamlNode(w, child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func yamlSampleValue(field *ConfigField) string {
+	def := field.Def
+	switch configFieldTypeName(field.Type) {
+	case "list":
+		if len(def) < 1 {
+			return "[]"
+		}
+		if strings.HasPrefix(def, "[") {
+			return def
+		}
+		items := strings.Split(def, ",")
+		for i, item := range items {
+			items[i] = strconv.Quote(strings.TrimSpace(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case "map":
+		if len(def) < 1 {
+			return "{}"
+		}
+		return def
+	case "string":
+		return strconv.Quote(def)
+	}
+	if len(def) < 1 {
+		return `""`
+	}
+	return def
+}
+
+/**
+所有叶子配置项的 key，按照字典序排序
+*/
+func ConfigFieldKeys(keyPrefix string, cfg interface{}) []string {
+	keys := make([]string, 0)
+	EachLeafConfigField(ResolveConfigFields(keyPrefix, cfg), func(field *ConfigField) (stop bool) {
+		keys = append(keys, field.Key)
+		return false
+	})
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,62 @@
+package xenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type SchemaAddr struct {
+	Country string `ck:"country" def:"CN" desc:"国家"`
+}
+
+type SchemaConfig struct {
+	Name  string                 `ck:"name" desc:"应用名称"`
+	Port  int                    `ck:"port" def:"8080"`
+	Tags  []string               `ck:"tags"`
+	Addr  *SchemaAddr            `ck:"addr" expand:"true"`
+	Addrs map[string]*SchemaAddr `ck:"addrs" expand:"true"`
+}
+
+func TestGenerateJsonSchema(t *testing.T) {
+	data, err := GenerateJsonSchema("app.", &SchemaConfig{})
+	assert.Nil(t, err)
+	fmt.Println(string(data))
+
+	schema := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(data, &schema))
+
+	app := schema["properties"].(map[string]interface{})["app"].(map[string]interface{})
+	properties := app["properties"].(map[string]interface{})
+
+	port := properties["port"].(map[string]interface{})
+	assert.Equal(t, "integer", port["type"])
+	assert.Equal(t, float64(8080), port["default"])
+
+	assert.Equal(t, "array", properties["tags"].(map[string]interface{})["type"])
+
+	addrs := properties["addrs"].(map[string]interface{})
+	country := addrs["additionalProperties"].(map[string]interface{})["properties"].(map[string]interface{})["country"].(map[string]interface{})
+	assert.Equal(t, "国家", country["description"])
+}
+
+func TestWriteSampleProperties(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteSampleProperties(buf, "app.", &SchemaConfig{}))
+	fmt.Println(buf.String())
+
+	assert.Contains(t, buf.String(), "# 应用名称\n# type: string\napp.name=\n")
+	assert.Contains(t, buf.String(), "app.addr.country=CN\n")
+	assert.Contains(t, buf.String(), "app.addrs.<key>.country=CN\n")
+}
+
+func TestWriteSampleYaml(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteSampleYaml(buf, "app.", &SchemaConfig{}))
+	fmt.Println(buf.String())
+
+	assert.Contains(t, buf.String(), "app:\n  # 应用名称\n  name: \"\"\n  port: 8080\n")
+	assert.Contains(t, buf.String(), "  addrs:\n    \"<key>\":\n      # 国家\n      country: \"CN\"\n")
+}
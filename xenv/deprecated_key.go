@@ -0,0 +1,150 @@
+package xenv
+
+import (
+	"github.com/xkgo/xkit/xlog"
+	"sort"
+	"sync"
+)
+
+/**
+废弃的配置 key，配置 key 重命名之后，旧的 key 仍然可以生效
+*/
+type DeprecatedKeyInfo struct {
+	OldKey string // 旧的配置 key
+	NewKey string // 新的配置 key
+	Since  string // 从哪个版本开始废弃
+}
+
+/**
+废弃配置 key 注册表，新 key 不存在的时候回退使用旧 key
+*/
+type deprecatedKeyRegistry struct {
+	lock   sync.RWMutex
+	byNew  map[string][]*DeprecatedKeyInfo
+	byOld  map[string][]*DeprecatedKeyInfo
+	warned map[string]bool // 已经告警过的旧 key
+}
+
+func newDeprecatedKeyRegistry() *deprecatedKeyRegistry {
+	return &deprecatedKeyRegistry{
+		byNew:  make(map[string][]*DeprecatedKeyInfo),
+		byOld:  make(map[string][]*DeprecatedKeyInfo),
+		warned: make(map[string]bool),
+	}
+}
+
+func (r *deprecatedKeyRegistry) add(oldKey, newKey, since string) {
+	if len(oldKey) < 1 || len(newKey) < 1 || oldKey == newKey {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, info := range r.byNew[newKey] {
+		if info.OldKey == oldKey {
+			return
+		}
+	}
+	info := &DeprecatedKeyInfo{OldKey: oldKey, NewKey: newKey, Since: since}
+	r.byNew[newKey] = append(r.byNew[newKey], info)
+	r.byOld[oldKey] = append(r.byOld[oldKey], info)
+}
+
+/**
+新 key 对应的所有旧 key，按照注册顺序
+*/
+func (r *deprecatedKeyRegistry) oldKeys(newKey string) []*DeprecatedKeyInfo {
+	if r == nil {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.byNew[newKey]
+}
+
+/**
+旧 key 对应的所有新 key
+*/
+func (r *deprecatedKeyRegistry) newKeys(oldKey string) []*DeprecatedKeyInfo {
+	if r == nil {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.byOld[oldKey]
+}
+
+func (r *deprecatedKeyRegistry) all() []*DeprecatedKeyInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	infos := make([]*DeprecatedKeyInfo, 0)
+	for _, items := range r.byNew {
+		infos = append(infos, items...)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].NewKey != infos[j].NewKey {
+			return infos[i].NewKey < infos[j].NewKey
+		}
+		return infos[i].OldKey < infos[j].OldKey
+	})
+	return infos
+}
+
+/**
+使用了旧 key 的时候告警，每个旧 key 只告警一次
+@param origin 旧 key 所在的配置来源，参考 GetPropertyOrigin
+*/
+func (r *deprecatedKeyRegistry) warnOnce(info *DeprecatedKeyInfo, origin string) {
+	r.lock.Lock()
+	warned := r.warned[info.OldKey]
+	r.warned[info.OldKey] = true
+	r.lock.Unlock()
+	if warned {
+		return
+	}
+	xlog.Warn("配置项[" + info.OldKey + "]已经从版本[" + info.Since + "]开始废弃，请使用[" + info.NewKey + "]代替，配置来源：[" + origin + "]")
+}
+
+/**
+注册废弃的配置 key，新 key 不存在的时候，读取、绑定配置会回退使用旧 key，旧 key 的变更事件也会通知新 key 的监听器
+@param oldKey 旧的配置 key
+@param newKey 新的配置 key
+@param since 从哪个版本开始废弃
+*/
+func DeprecatedKey(oldKey, newKey, since string) Option {
+	return func(environment *StandardEnvironment) {
+		environment.AddDeprecatedKey(oldKey, newKey, since)
+	}
+}
+
+/**
+注册废弃的配置 key，参考 DeprecatedKey
+*/
+func (s *StandardEnvironment) AddDeprecatedKey(oldKey, newKey, since string) {
+	s.deprecatedKeys.add(oldKey, newKey, since)
+}
+
+/**
+所有注册的废弃配置 key，按照新 key 排序
+*/
+func (s *StandardEnvironment) DeprecatedKeys() []*DeprecatedKeyInfo {
+	return s.deprecatedKeys.all()
+}
+
+/**
+旧 key 的变更事件转换成新 key 的变更事件，新 key 在配置中存在的话，旧 key 的变更不影响生效值，不转换
+*/
+func (s *StandardEnvironment) deprecatedKeyEvents(event *KeyChangeEvent) []*KeyChangeEvent {
+	events := make([]*KeyChangeEvent, 0)
+	for _, info := range s.deprecatedKeys.newKeys(event.Key) {
+		if _, exists := s.rawProperty(info.NewKey); exists {
+			continue
+		}
+		events = append(events, &KeyChangeEvent{
+			Key:        info.NewKey,
+			Ov:         event.Ov,
+			Nv:         event.Nv,
+			ChangeType: event.ChangeType,
+		})
+	}
+	return events
+}
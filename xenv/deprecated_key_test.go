@@ -0,0 +1,70 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type DeprecatedKeyConfig struct {
+	Timeout int    `ck:"timeout"`
+	Host    string `ck:"host"`
+}
+
+func TestStandardEnvironment_DeprecatedKey(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"server.read-timeout": "10", "server.host": "127.0.0.1", "server.address": "10.0.0.1"})
+	source.SetPropertyOrigin("server.read-timeout", "/config/application.yml")
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(),
+		AdditionalPropertySources(sources),
+		DeprecatedKey("server.read-timeout", "server.timeout", "1.2.0"),
+		DeprecatedKey("server.address", "server.host", "1.2.0"))
+
+	assert.Equal(t, []*DeprecatedKeyInfo{
+		{OldKey: "server.address", NewKey: "server.host", Since: "1.2.0"},
+		{OldKey: "server.read-timeout", NewKey: "server.timeout", Since: "1.2.0"},
+	}, env.DeprecatedKeys())
+	assert.Equal(t, "/config/application.yml", GetPropertyOrigin(source, "server.read-timeout"))
+	assert.Equal(t, "test", GetPropertyOrigin(source, "server.host"))
+
+	// 新 key 不存在，回退使用旧 key
+	value, ok := env.GetProperty("server.timeout")
+	assert.True(t, ok)
+	assert.Equal(t, "10", value)
+	assert.True(t, env.ContainsProperty("server.timeout"))
+	// 新 key 存在，旧 key 不生效
+	assert.Equal(t, "127.0.0.1", env.GetPropertyWithDef("server.host", ""))
+
+	cfg := &DeprecatedKeyConfig{}
+	_, err := env.BindProperties("server.", cfg, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, cfg.Timeout)
+	assert.Equal(t, "127.0.0.1", cfg.Host)
+
+	events := make(chan *KeyChangeEvent, 2)
+	env.Subscribe("server.timeout", func(event *KeyChangeEvent) {
+		events <- event
+	})
+
+	// 旧 key 变更通知新 key 的监听器
+	source.Put("server.read-timeout", "20")
+	select {
+	case event := <-events:
+		assert.Equal(t, "server.timeout", event.Key)
+		assert.Equal(t, "20", event.Nv)
+	case <-time.After(time.Second):
+		t.Fatal("未收到新 key 的变更事件")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 20, cfg.Timeout)
+
+	// 设置了新 key 之后，旧 key 的变更不再影响新 key
+	source.Put("server.timeout", "30")
+	<-events
+	source.Put("server.read-timeout", "40")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "30", env.GetPropertyWithDef("server.timeout", ""))
+	assert.Equal(t, 30, cfg.Timeout)
+	assert.Equal(t, 0, len(events))
+}
@@ -1,5 +1,7 @@
 package xenv
 
+import "context"
+
 /*
 环境，包含运行环境、启动项目用到的参数、配置等等
 */
@@ -23,16 +25,34 @@ type Environment interface {
 
 	/**
 	订阅变更, keyPattern: 等值、正则匹配，如果为空字符串或者 * 那么表示所有，如果是个合法的正则，那么就按照正则匹配
+	@return 订阅句柄，用于取消订阅
 	*/
-	Subscribe(keyPattern string, handler func(event *KeyChangeEvent))
+	Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription
 
 	/**
 	绑定配置项到某个模型对象，注意传进来的必须是指针类型, keyPrefix key前缀，会直接和配置struct的属性直接拼接，如果有.的话要注意了
 	@param name 名称，唯一
 	@param cfgPtr 配置指针
 	@param changedListen 是否需要进行监听
+	@param options 单次绑定的选项，比如 StrictBind
+	*/
+	BindProperties(keyPrefix string, cfgPtr interface{}, changedListen bool, options ...BindOption) (beanPtr interface{}, err error)
+
+	/**
+	解除配置 Bean 的绑定，取消绑定时注册的所有配置变更监听，Bean 的属性值保持不变
+	@return 是否存在对应的绑定
+	*/
+	UnbindProperties(beanPtr interface{}) bool
+
+	/**
+	校验所有配置来源中的所有配置项，处理全部 ${...} 占位符，汇总无法识别的占位符、循环引用以及处理之后为空的配置项
 	*/
-	BindProperties(keyPrefix string, cfgPtr interface{}, changedListen bool) (beanPtr interface{}, err error)
+	Validate() *ValidationReport
+
+	/**
+	所有配置 Bean 绑定完成之后，检查已经绑定的配置 Bean 前缀下的未知配置项，参考 FailOnUnknownKeys
+	*/
+	CheckAllUnknownKeys() error
 
 	IsDev() bool
 	IsTest() bool
@@ -51,4 +71,11 @@ type Environment interface {
 	获取工作目录
 	*/
 	GetWorkDir() string
+
+	/**
+	关闭环境，关闭所有实现了 Closeable 的配置来源，并取消所有的订阅
+	*/
+	Close(ctx context.Context) error
 }
+
+var _ Environment = (*StandardEnvironment)(nil)
@@ -2,6 +2,7 @@ package xenv
 
 import (
 	"github.com/xkgo/xkit/xlog"
+	"time"
 )
 
 // 选项
@@ -38,6 +39,66 @@ type Options struct {
 	追加的profiles，会放到 原来的之后
 	*/
 	appendProfiles []string
+
+	/**
+	不添加命令行参数配置来源
+	*/
+	ignoreCommandLine bool
+
+	/**
+	不添加系统环境变量配置来源
+	*/
+	ignoreSystemEnvironment bool
+
+	/**
+	只导入这个前缀的系统环境变量，参考 SystemEnvironmentPrefix
+	*/
+	systemEnvironmentPrefix string
+
+	/**
+	系统环境变量名转换成配置 key 的规则，参考 SystemEnvironmentKeyMapper
+	*/
+	systemEnvironmentKeyMapper EnvironmentVariableMapper
+
+	/**
+	不加载配置目录下的配置文件
+	*/
+	ignoreConfigFiles bool
+
+	/**
+	不根据 xlog. 前缀的配置初始化 xlog 日志
+	*/
+	disableXlogInit bool
+
+	/**
+	配置 Bean 热更新回调的合并等待时间，默认 DefaultRebindCallbackDelay
+	*/
+	rebindCallbackDelay time.Duration
+
+	/**
+	CheckAllUnknownKeys 检查到未知配置项的时候返回异常，参考 FailOnUnknownKeys
+	*/
+	failOnUnknownKeys bool
+
+	/**
+	启动的时候校验所有配置项的占位符，存在问题则 New 的时候 panic，参考 FailOnValidationIssues
+	*/
+	failOnValidationIssues bool
+
+	/**
+	严格绑定模式，配置项转换失败的时候返回异常，参考 StrictBinding
+	*/
+	strictBinding bool
+
+	/**
+	内存中保留的审计记录数，参考 AuditLogCapacity
+	*/
+	auditLogCapacity int
+
+	/**
+	审计记录追加写入的文件，参考 AuditLogFile
+	*/
+	auditLogFile string
 }
 
 /**
@@ -103,3 +164,68 @@ func TraceIdGenerator(generator xlog.TraceIdGenerator) Option {
 		xlog.SetTraceIdGenerator(generator)
 	}
 }
+
+/**
+不添加命令行参数配置来源，一般用于测试
+*/
+func IgnoreCommandLine() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.ignoreCommandLine = true
+	}
+}
+
+/**
+不添加系统环境变量配置来源，一般用于测试
+*/
+func IgnoreSystemEnvironment() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.ignoreSystemEnvironment = true
+	}
+}
+
+/**
+只导入指定前缀的系统环境变量，去掉前缀之后转换成配置 key，比如 MYAPP_SERVER_PORT => server.port，
+避免 PATH、LS_COLORS 等无关的变量混入配置，规则参考 NewPrefixedSystemEnvironmentPropertySource
+*/
+func SystemEnvironmentPrefix(prefix string) Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.systemEnvironmentPrefix = prefix
+	}
+}
+
+/**
+指定了 SystemEnvironmentPrefix 的时候，系统环境变量名转换成配置 key 的规则，默认为 DefaultEnvironmentVariableMapper，
+需要 - 以及数组下标的话可以使用 IndexedEnvironmentVariableMapper
+*/
+func SystemEnvironmentKeyMapper(mapper EnvironmentVariableMapper) Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.systemEnvironmentKeyMapper = mapper
+	}
+}
+
+/**
+不加载配置目录下的配置文件，包括默认配置和 profile 配置，一般用于测试
+*/
+func IgnoreConfigFiles() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.ignoreConfigFiles = true
+	}
+}
+
+/**
+不根据 xlog. 前缀的配置初始化 xlog 日志，避免修改全局的日志配置
+*/
+func DisableXlogInit() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.disableXlogInit = true
+	}
+}
+
+/**
+配置 Bean 热更新回调的合并等待时间，等待时间内的多次变更只回调一次 OnPropertiesChanged、AfterPropertiesSet
+*/
+func RebindCallbackDelay(delay time.Duration) Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.rebindCallbackDelay = delay
+	}
+}
@@ -0,0 +1,212 @@
+package xenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/xkgo/xkit/xfile"
+	"sort"
+	"strings"
+)
+
+/**
+配置导出格式
+*/
+type ExportFormat string
+
+const (
+	ExportProperties ExportFormat = "properties" // key=value，按照 key 排序
+	ExportYaml       ExportFormat = "yaml"       // 按照 key 中的 . 以及 [n] 还原成嵌套结构，参考 xfile.Unflatten
+	ExportJson       ExportFormat = "json"       // 按照 key 中的 . 以及 [n] 还原成嵌套结构，参考 xfile.Unflatten
+)
+
+/**
+配置导出选项
+*/
+type ExportOption func(options *exportOptions)
+
+type exportOptions struct {
+	raw        bool     // 导出原始值，不替换占位符
+	unmasked   bool     // 不对敏感配置脱敏
+	prefixes   []string // 只导出这些前缀的配置，为空表示全部导出
+	withSource bool     // 标注配置项的来源
+}
+
+/**
+导出原始值，不替换占位符，默认导出替换占位符之后的值
+*/
+func ExportRaw() ExportOption {
+	return func(options *exportOptions) {
+		options.raw = true
+	}
+}
+
+/**
+是否对敏感配置脱敏，默认脱敏，参考 IsSecretKey
+*/
+func ExportMaskSecrets(mask bool) ExportOption {
+	return func(options *exportOptions) {
+		options.unmasked = !mask
+	}
+}
+
+/**
+只导出指定前缀的配置，可以指定多个
+*/
+func ExportPrefixes(prefixes ...string) ExportOption {
+	return func(options *exportOptions) {
+		options.prefixes = append(options.prefixes, prefixes...)
+	}
+}
+
+/**
+标注配置项的来源，properties、yaml 以注释的形式标注，json 的话配置值变成 {"value": ..., "source": ...}
+*/
+func ExportWithSource() ExportOption {
+	return func(options *exportOptions) {
+		options.withSource = true
+	}
+}
+
+/**
+导出的配置项
+*/
+type exportedProperty struct {
+	key    string
+	value  string
+	source string
+}
+
+/**
+按照指定格式导出当前生效的配置，可以用于排查问题时保存现场
+*/
+func (s *StandardEnvironment) Export(format ExportFormat, options ...ExportOption) ([]byte, error) {
+	opts := &exportOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	properties := s.exportedProperties(opts)
+	buf := &bytes.Buffer{}
+	switch format {
+	case ExportProperties:
+		kvs, comments := exportedKvs(properties, opts)
+		err := xfile.WritePropertiesWithComments(buf, kvs, comments)
+		return buf.Bytes(), err
+	case ExportYaml:
+		kvs, comments := exportedKvs(properties, opts)
+		err := xfile.WriteYamlWithComments(buf, kvs, comments)
+		return buf.Bytes(), err
+	case ExportJson:
+		return exportAsJson(properties, opts)
+	}
+	return nil, errors.New("不支持的配置导出格式：" + string(format))
+}
+
+/**
+当前生效的配置项，按照 key 排序
+*/
+func (s *StandardEnvironment) exportedProperties(opts *exportOptions) []*exportedProperty {
+	exported := make(map[string]*exportedProperty)
+	s.propertySources.Each(func(index int, source PropertySource) (pstop bool) {
+		source.Each(func(key, value string) (stop bool) {
+			// 越靠前的配置来源优先级越高
+			if _, exists := exported[key]; exists || !hasAnyPrefix(key, opts.prefixes) {
+				return false
+			}
+			exported[key] = &exportedProperty{key: key, value: value, source: propertySourceDescription(source, key)}
+			return false
+		})
+		return false
+	})
+
+	properties := make([]*exportedProperty, 0, len(exported))
+	for _, property := range exported {
+		switch {
+		case !opts.raw && !opts.unmasked:
+			// 占位符引用了敏感配置的话也要脱敏
+			property.value = s.MaskResolvedPropertyValue(property.key, property.value)
+		case !opts.raw:
+			property.value = s.resolveExportedValue(property.value)
+		case !opts.unmasked:
+			property.value = s.MaskPropertyValue(property.key, property.value)
+		}
+		properties = append(properties, property)
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		return properties[i].key < properties[j].key
+	})
+	return properties
+}
+
+/**
+替换占位符，无法替换的话保留原始值
+*/
+func (s *StandardEnvironment) resolveExportedValue(value string) string {
+	resolved, err := s.ResolvePlaceholdersE(value)
+	if err != nil {
+		return value
+	}
+	return resolved
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	if len(prefixes) < 1 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+配置来源描述，配置项有更具体的来源（比如文件）的话一起输出
+*/
+func propertySourceDescription(source PropertySource, key string) string {
+	origin := GetPropertyOrigin(source, key)
+	if origin == source.GetName() {
+		return origin
+	}
+	return source.GetName() + " (" + origin + ")"
+}
+
+/**
+配置项以及来源注释
+*/
+func exportedKvs(properties []*exportedProperty, opts *exportOptions) (kvs map[string]string, comments map[string]string) {
+	kvs = make(map[string]string, len(properties))
+	for _, property := range properties {
+		kvs[property.key] = property.value
+		if opts.withSource {
+			if comments == nil {
+				comments = make(map[string]string, len(properties))
+			}
+			comments[property.key] = "source: " + property.source
+		}
+	}
+	return
+}
+
+func exportAsJson(properties []*exportedProperty, opts *exportOptions) ([]byte, error) {
+	kvs, _ := exportedKvs(properties, opts)
+	if !opts.withSource {
+		buf := &bytes.Buffer{}
+		err := xfile.WriteJson(buf, kvs)
+		return buf.Bytes(), err
+	}
+
+	sources := make(map[string]string, len(properties))
+	for _, property := range properties {
+		sources[property.key] = property.source
+	}
+	data, err := json.MarshalIndent(xfile.UnflattenFunc(kvs, func(key, value string) interface{} {
+		return map[string]string{"value": value, "source": sources[key]}
+	}), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
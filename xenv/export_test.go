@@ -0,0 +1,91 @@
+package xenv
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStandardEnvironment_Export(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{
+		"app.name":         "demo",
+		"app.url":          "http://${app.host}:8080",
+		"app.host":         "127.0.0.1",
+		"app.db.password":  "123456",
+		"app.desc":         "a = b\nc",
+		"app.timeout":      "10s",
+		"app.timeout.unit": "s",
+		"other.key":        "true",
+	})
+	source.SetPropertyOrigin("app.name", "/config/application.yml")
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	data, err := env.Export(ExportProperties, ExportPrefixes("app."))
+	assert.Nil(t, err)
+	assert.Equal(t, "app.db.password=******\n"+
+		"app.desc=a = b\\nc\n"+
+		"app.host=127.0.0.1\n"+
+		"app.name=demo\n"+
+		"app.timeout=10s\n"+
+		"app.timeout.unit=s\n"+
+		"app.url=http://127.0.0.1:8080\n", string(data))
+
+	data, err = env.Export(ExportProperties, ExportPrefixes("app.name", "app.url"), ExportRaw(), ExportWithSource())
+	assert.Nil(t, err)
+	assert.Equal(t, "# source: test (/config/application.yml)\napp.name=demo\n"+
+		"# source: test\napp.url=http://${app.host}:8080\n", string(data))
+
+	data, err = env.Export(ExportYaml, ExportPrefixes("app.", "other."), ExportMaskSecrets(false))
+	assert.Nil(t, err)
+	assert.Equal(t, "app:\n"+
+		"  db:\n"+
+		"    password: \"123456\"\n"+
+		"  desc: \"a = b\\nc\"\n"+
+		"  host: \"127.0.0.1\"\n"+
+		"  name: demo\n"+
+		"  timeout: \"10s\"\n"+
+		"  timeout.unit: s\n"+
+		"  url: \"http://127.0.0.1:8080\"\n"+
+		"other:\n"+
+		"  key: \"true\"\n", string(data))
+
+	data, err = env.Export(ExportJson, ExportPrefixes("app.db.", "app.name"), ExportWithSource())
+	assert.Nil(t, err)
+	values := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(data, &values))
+	assert.Equal(t, map[string]interface{}{
+		"app": map[string]interface{}{
+			"db":   map[string]interface{}{"password": map[string]interface{}{"value": "******", "source": "test"}},
+			"name": map[string]interface{}{"value": "demo", "source": "test (/config/application.yml)"},
+		},
+	}, values)
+
+	_, err = env.Export("xml")
+	assert.NotNil(t, err)
+}
+
+func TestStandardEnvironment_ExportSecretReference(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", map[string]string{
+		"app.db.password": "123456",
+		"app.db.url":      "mysql://u:${app.db.password}@h",
+		"app.dsn":         "${app.db.url}",
+		"app.host":        "${app.name:127.0.0.1}",
+	}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	// 直接或者嵌套引用了敏感配置的也要脱敏
+	data, err := env.Export(ExportProperties, ExportPrefixes("app.db.url", "app.dsn", "app.host"))
+	assert.Nil(t, err)
+	assert.Equal(t, "app.db.url=******\napp.dsn=******\napp.host=127.0.0.1\n", string(data))
+
+	data, err = env.Export(ExportProperties, ExportPrefixes("app.db.url"), ExportRaw())
+	assert.Nil(t, err)
+	assert.Equal(t, "app.db.url=mysql://u:${app.db.password}@h\n", string(data))
+
+	data, err = env.Export(ExportProperties, ExportPrefixes("app.db.url"), ExportMaskSecrets(false))
+	assert.Nil(t, err)
+	assert.Equal(t, "app.db.url=mysql://u:123456@h\n", string(data))
+}
@@ -0,0 +1,170 @@
+package xenv
+
+import (
+	"encoding/json"
+	"github.com/xkgo/xkit/xfile"
+	"github.com/xkgo/xkit/xreflect"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/**
+是否是数组、切片类型的属性
+*/
+func isListType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+/**
+获取属性对应的配置值，数组、切片类型的属性支持 key[0]、key[1].name 形式以及 key 对应的 JSON 形式的配置，
+使用优先级最高的配置来源中定义的形式，同一个配置来源中两种形式都有的话使用 key[n] 形式；
+key[n] 形式的配置可以在多个配置来源中单独覆盖某个元素；
+struct 元素中存在转换失败的属性的话，返回组装好的值以及 BindErrors，由调用方合并到 Bean 的转换失败信息中
+*/
+func (s *StandardEnvironment) getFieldProperty(configKey string, fieldType reflect.Type) (string, bool, error) {
+	if isListType(fieldType) && s.isIndexedListProperty(configKey) {
+		value, exists, err := s.getListProperty(configKey, fieldType)
+		if err != nil || exists {
+			return value, exists, err
+		}
+	}
+	return s.GetPropertyE(configKey)
+}
+
+/**
+优先级最高的、定义了 key[n] 形式或者 JSON 形式（包括废弃的旧 key）配置的配置来源中是否是 key[n] 形式
+*/
+func (s *StandardEnvironment) isIndexedListProperty(configKey string) bool {
+	keys := []string{configKey}
+	for _, info := range s.deprecatedKeys.oldKeys(configKey) {
+		keys = append(keys, info.OldKey)
+	}
+	indexed := false
+	s.propertySources.Each(func(index int, source PropertySource) (stop bool) {
+		source.Each(func(key, value string) (stop bool) {
+			indexed = isListElementKey(configKey, key)
+			return indexed
+		})
+		if indexed {
+			return true
+		}
+		for _, key := range keys {
+			if _, exists := source.GetProperty(key); exists {
+				return true
+			}
+		}
+		return false
+	})
+	return indexed
+}
+
+/**
+配置 key 是否属于数组、切片属性的元素，比如 servers[0].host 属于 servers
+*/
+func isListElementKey(configKey, key string) bool {
+	return strings.HasPrefix(key, configKey+"[")
+}
+
+/**
+使用 key[n] 形式的配置组装成 JSON 数组，不存在这种形式的配置返回 false；
+元素为 struct 的话使用 key[n]. 作为前缀绑定，缺少的下标使用零值，转换失败的属性使用零值并且返回 BindErrors
+*/
+func (s *StandardEnvironment) getListProperty(configKey string, fieldType reflect.Type) (string, bool, error) {
+	indexes := s.listIndexes(configKey)
+	if len(indexes) < 1 {
+		return "", false, nil
+	}
+	size := 0
+	for index := range indexes {
+		if index+1 > size {
+			size = index + 1
+		}
+	}
+
+	t := fieldType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	elemType := t.Elem()
+	baseType := elemType
+	if baseType.Kind() == reflect.Ptr {
+		baseType = baseType.Elem()
+	}
+
+	items := make([]interface{}, size)
+	rawItems := make([]interface{}, size)
+	converted := true
+	var elemErrs BindErrors
+	for index := range indexes {
+		elemKey := configKey + "[" + strconv.Itoa(index) + "]"
+		switch {
+		case baseType.Kind() == reflect.Struct:
+			elem := reflect.New(baseType)
+			// 转换失败使用零值，最后一起返回，占位符处理失败直接返回
+			if _, err := s.doBindProperties(elemKey+".", elem.Interface(), false, nil); err != nil && !elemErrs.merge(err) {
+				return "", false, err
+			}
+			items[index] = elem.Interface()
+		case isListType(baseType):
+			value, exists, err := s.getListProperty(elemKey, baseType)
+			if err != nil && !elemErrs.merge(err) {
+				return "", false, err
+			}
+			if exists {
+				items[index] = json.RawMessage(value)
+			}
+		default:
+			value, exists, err := s.GetPropertyE(elemKey)
+			if err != nil {
+				return "", false, err
+			}
+			if !exists {
+				continue
+			}
+			rawItems[index] = value
+			elem, err := xreflect.ConvertTo(value, baseType)
+			if err != nil {
+				converted = false
+				continue
+			}
+			items[index] = elem.Interface()
+		}
+	}
+	if !converted {
+		// 存在无法转换的元素，返回原始值，转换的时候报错
+		items = rawItems
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return "", false, nil
+	}
+	return string(data), true, elemErrs.errorOrNil()
+}
+
+/**
+key[n] 形式的配置中的所有下标，超过 xfile.MaxKeyIndex 的下标不作为数组元素，避免分配过大的数组
+*/
+func (s *StandardEnvironment) listIndexes(configKey string) map[int]bool {
+	indexes := make(map[int]bool)
+	prefix := configKey + "["
+	s.EachProperty(func(key, value string) (stop bool) {
+		if !strings.HasPrefix(key, prefix) {
+			return false
+		}
+		rest := key[len(prefix):]
+		end := strings.Index(rest, "]")
+		if end < 1 {
+			return false
+		}
+		if index, err := strconv.Atoi(rest[:end]); err == nil && index >= 0 && index <= xfile.MaxKeyIndex {
+			indexes[index] = true
+		}
+		return false
+	})
+	return indexes
+}
@@ -0,0 +1,79 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ListPropertyConfig struct {
+	Servers []*ListPropertyServer `ck:"servers"`
+	Tags    []string              `ck:"tags"`
+	Ports   []int                 `ck:"ports"`
+	Matrix  [][]int               `ck:"matrix"`
+}
+
+type ListPropertyServer struct {
+	Host string `ck:"host"`
+	Port int    `ck:"port" def:"80"`
+}
+
+func TestStandardEnvironment_BindListProperties(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("high", map[string]string{
+		"app.tags":     `["x"]`,
+		"app.ports[1]": "3",
+	}))
+	sources.AddLast(NewMapPropertySource("test", map[string]string{
+		"app.servers[0].host": "127.0.0.1",
+		"app.servers[0].port": "8080",
+		"app.servers[2].host": "127.0.0.3",
+		"app.servers":         `[{"Host":"same-source"}]`,
+		"app.tags[0]":         "a",
+		"app.tags[1]":         "b",
+		"app.ports":           "[1, 2]",
+		"app.matrix[0][0]":    "1",
+		"app.matrix[1][1]":    "4",
+	}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &ListPropertyConfig{}
+	_, err := env.BindProperties("app.", cfg, false)
+	assert.Nil(t, err)
+	// 同一个配置来源中两种形式都有，使用 key[n] 形式
+	assert.Equal(t, 3, len(cfg.Servers))
+	assert.Equal(t, &ListPropertyServer{Host: "127.0.0.1", Port: 8080}, cfg.Servers[0])
+	assert.Nil(t, cfg.Servers[1])
+	assert.Equal(t, &ListPropertyServer{Host: "127.0.0.3", Port: 80}, cfg.Servers[2])
+	// 优先级高的配置来源中是 JSON 形式的配置
+	assert.Equal(t, []string{"x"}, cfg.Tags)
+	// 优先级高的配置来源中是 key[n] 形式的配置，不会和低优先级的 JSON 形式的配置合并
+	assert.Equal(t, []int{0, 3}, cfg.Ports)
+	assert.Equal(t, [][]int{{1}, {0, 4}}, cfg.Matrix)
+
+	assert.Equal(t, 0, len(env.CheckUnknownKeys("app.", cfg)))
+}
+
+func TestStandardEnvironment_BindListPropertiesStrict(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", map[string]string{
+		"app.servers[0].host":         "127.0.0.1",
+		"app.servers[0].port":         "abc",
+		"app.servers[999999999].host": "127.0.0.2",
+	}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	// 元素的转换失败信息合并到 Bean 中
+	_, err := env.BindProperties("app.", &ListPropertyConfig{}, false, StrictBind(true))
+	bindErrs, ok := err.(BindErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(bindErrs))
+	assert.Equal(t, "app.servers[0].port", bindErrs[0].Key)
+	assert.Equal(t, "abc", bindErrs[0].Value)
+
+	// 非严格模式下转换失败的属性使用零值，超过上限的下标不作为数组元素
+	cfg := &ListPropertyConfig{}
+	_, err = env.BindProperties("app.", cfg, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(cfg.Servers))
+	assert.Equal(t, &ListPropertyServer{Host: "127.0.0.1"}, cfg.Servers[0])
+}
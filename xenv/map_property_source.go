@@ -1,9 +1,11 @@
 package xenv
 
 import (
+	"context"
 	"github.com/xkgo/xkit/xcontext"
+	"github.com/xkgo/xkit/xfile"
 	"github.com/xkgo/xkit/xlog"
-	"regexp"
+	"io"
 	"sync"
 )
 
@@ -16,13 +18,58 @@ type MapPropertySource struct {
 	/**
 	配置key变更订阅列表
 	*/
-	propertyChangeListeners []*PropertyChangeListener
+	propertyChangeListeners *PropertyChangeListenerRegistry
+	origins                 *sync.Map     // 配置项的具体来源，比如所在文件，key->origin
+	pending                 pendingEvents // 已经修改但是还没有通知完监听器的变更事件
+}
+
+/**
+还没有通知完监听器的变更事件计数，变更事件是异步通知的，需要看到变更结果的时候可以先等待
+*/
+type pendingEvents struct {
+	lock  sync.Mutex
+	count int
+	done  chan struct{} // count 变成 0 的时候关闭
+}
+
+func (p *pendingEvents) add() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.count == 0 {
+		p.done = make(chan struct{})
+	}
+	p.count++
+}
+
+func (p *pendingEvents) finish() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.count--
+	if p.count == 0 {
+		close(p.done)
+	}
+}
+
+/**
+等待当前所有的变更事件通知完，不能在这个配置来源的监听器中调用
+*/
+func (p *pendingEvents) wait() {
+	p.lock.Lock()
+	if p.count == 0 {
+		p.lock.Unlock()
+		return
+	}
+	done := p.done
+	p.lock.Unlock()
+	<-done
 }
 
 func NewMapPropertySource(name string, properties map[string]string) *MapPropertySource {
 	source := &MapPropertySource{
-		name:       name,
-		properties: &sync.Map{},
+		name:                    name,
+		properties:              &sync.Map{},
+		propertyChangeListeners: NewPropertyChangeListenerRegistry(),
+		origins:                 &sync.Map{},
 	}
 
 	if len(properties) > 0 {
@@ -34,6 +81,25 @@ func NewMapPropertySource(name string, properties map[string]string) *MapPropert
 	return source
 }
 
+/**
+按照指定格式解析内容作为配置来源，可以用于读取远程配置等非本地文件的场景，支持的格式参考 xfile.RegisterFormat
+@param format 文件后缀或者格式名称，比如 yml、.properties
+@param origin 配置项的具体来源，比如远程配置的地址，为空的话使用配置来源名称
+*/
+func NewFormatPropertySource(name string, format string, r io.Reader, origin string) (*MapPropertySource, error) {
+	kvs, err := xfile.ParseAsMap(format, r)
+	if err != nil {
+		return nil, err
+	}
+	source := NewMapPropertySource(name, kvs)
+	if len(origin) > 0 {
+		for key := range kvs {
+			source.SetPropertyOrigin(key, origin)
+		}
+	}
+	return source, nil
+}
+
 func (m *MapPropertySource) GetName() string {
 	return m.name
 }
@@ -69,32 +135,34 @@ func (m *MapPropertySource) Each(consumer func(key string, value string) (stop b
 	})
 }
 
+/**
+配置来源不知道哪些 key 在环境中被标记为敏感配置，这里只输出 key，新旧值由环境脱敏之后输出
+*/
 func (m *MapPropertySource) onKeyChangeEvent(event *KeyChangeEvent) {
-	xlog.Info("["+m.name+"]配置发生了变更：key:["+event.Key+"], ov:["+event.Ov+"], nv:["+event.Nv+"], changeType:[", event.ChangeType+"]")
+	xlog.Info("["+m.name+"]配置发生了变更：key:["+event.Key+"], changeType:[", event.ChangeType+"]")
 	// 执行监听器
-	if len(m.propertyChangeListeners) > 0 {
-		for _, listener := range m.propertyChangeListeners {
-			keyPattern := listener.KeyPattern
-			handler := listener.Handler
-			if handler == nil {
-				continue
-			}
-			if keyPattern == "" || keyPattern == "*" || keyPattern == event.Key {
-				handler(event)
-				continue
-			}
-			regex, err := regexp.Compile(keyPattern)
-			if err == nil && regex.MatchString(event.Key) {
-				xcontext.Run(func() {
-					handler(event)
-				}, func(r interface{}, hadPanic bool) {
-					if hadPanic {
-						xlog.Warn("配置源["+m.name+"]执行配置变更[", listener, "]发生panic： ", r)
-					}
-				})
-			}
-		}
+	m.propertyChangeListeners.Publish(m.name, event)
+}
+
+/**
+设置配置项的具体来源，比如配置项所在的文件
+*/
+func (m *MapPropertySource) SetPropertyOrigin(key string, origin string) {
+	if m.origins == nil {
+		return
 	}
+	m.origins.Store(key, origin)
+}
+
+func (m *MapPropertySource) GetPropertyOrigin(key string) (origin string, exists bool) {
+	if m.origins == nil {
+		return "", false
+	}
+	val, exists := m.origins.Load(key)
+	if !exists {
+		return "", false
+	}
+	return val.(string), true
 }
 
 /**
@@ -118,7 +186,9 @@ func (m *MapPropertySource) Put(key string, value string) {
 	}
 	m.properties.Store(key, value)
 
+	m.pending.add()
 	xcontext.RunByGoroutine(func() {
+		defer m.pending.finish()
 		m.onKeyChangeEvent(event)
 	}, nil)
 }
@@ -160,15 +230,25 @@ func (m *MapPropertySource) Remove(keys ...string) {
 		// 删除 key
 		m.properties.Delete(key)
 
+		m.pending.add()
 		xcontext.RunByGoroutine(func() {
+			defer m.pending.finish()
 			m.onKeyChangeEvent(event)
 		})
 	}
 }
 
-func (m *MapPropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) {
+func (m *MapPropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription {
 	if m.propertyChangeListeners == nil {
-		m.propertyChangeListeners = make([]*PropertyChangeListener, 0)
+		m.propertyChangeListeners = NewPropertyChangeListenerRegistry()
 	}
-	m.propertyChangeListeners = append(m.propertyChangeListeners, NewPropertyChangeListener(keyPattern, handler))
+	return m.propertyChangeListeners.Add(keyPattern, handler)
+}
+
+/**
+关闭，取消所有的订阅
+*/
+func (m *MapPropertySource) Close(ctx context.Context) error {
+	m.propertyChangeListeners.Clear()
+	return nil
 }
@@ -3,6 +3,7 @@ package xenv
 import (
 	"errors"
 	"github.com/xkgo/xkit/xlog"
+	"sync"
 )
 
 type PropertySourcesChangeType string
@@ -10,8 +11,32 @@ type PropertySourcesChangeType string
 const (
 	PropertySourcesChangeType_Add    = "ADD"
 	PropertySourcesChangeType_Update = "UPDATE"
+	PropertySourcesChangeType_Remove = "REMOVE"
+	PropertySourcesChangeType_Move   = "MOVE"
 )
 
+const (
+	// 未实现 Ordered 的配置来源的默认顺序
+	DefaultPropertySourceOrder = 0
+)
+
+/**
+带顺序的配置来源，用于 MutablePropertySources.AddByOrder，Order 越小优先级越高
+*/
+type Ordered interface {
+	Order() int
+}
+
+/**
+获取配置来源的顺序，未实现 Ordered 的话返回 DefaultPropertySourceOrder
+*/
+func PropertySourceOrder(propertySource PropertySource) int {
+	if ordered, ok := propertySource.(Ordered); ok {
+		return ordered.Order()
+	}
+	return DefaultPropertySourceOrder
+}
+
 type PropertySources interface {
 
 	/**
@@ -45,10 +70,16 @@ type MutablePropertySources struct {
 	配置来源列表，左边的优先生效，比如同一个key在 第一第二个元素上面都存在，那么则会优先使用第一个元素上面的值，
 	不管第一个是否为空字符串都要以第一个元素为准
 	*/
-	propertySourceList []PropertySource // 配置来源列表
+	propertySourceList []PropertySource // 配置来源列表，写时复制：修改的时候生成新的列表，不会修改已有列表中的元素
+	lock               sync.RWMutex     // 保护 propertySourceList，监听器中可能会修改配置来源列表，所以通知监听器的时候不持有锁
 
 	// 监听器
-	listeners []func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource)
+	listeners    []*propertySourcesListener
+	listenerLock sync.RWMutex
+}
+
+type propertySourcesListener struct {
+	handler func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource)
 }
 
 func NewMutablePropertySources(propertySourceList ...PropertySource) *MutablePropertySources {
@@ -58,39 +89,57 @@ func NewMutablePropertySources(propertySourceList ...PropertySource) *MutablePro
 	return &MutablePropertySources{propertySourceList: propertySourceList}
 }
 
-func (s *MutablePropertySources) Subscribe(listener func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource)) {
-	if s.listeners == nil {
-		s.listeners = make([]func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource), 0)
-	}
-	s.listeners = append(s.listeners, listener)
+/**
+订阅配置来源列表的变更
+@return 订阅句柄，用于取消订阅
+*/
+func (s *MutablePropertySources) Subscribe(listener func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource)) Subscription {
+	item := &propertySourcesListener{handler: listener}
+	s.listenerLock.Lock()
+	s.listeners = append(s.listeners, item)
+	s.listenerLock.Unlock()
+
+	return NewSubscription(func() {
+		s.listenerLock.Lock()
+		defer s.listenerLock.Unlock()
+		listeners := make([]*propertySourcesListener, 0, len(s.listeners))
+		for _, l := range s.listeners {
+			if l != item {
+				listeners = append(listeners, l)
+			}
+		}
+		s.listeners = listeners
+	})
 }
 
 func (s *MutablePropertySources) onPropertySourceChanged(changeType PropertySourcesChangeType, source PropertySource) {
-	if len(s.listeners) > 0 {
-		for _, listener := range s.listeners {
-			listener(s, changeType, source)
-		}
+	s.listenerLock.RLock()
+	listeners := s.listeners
+	s.listenerLock.RUnlock()
+	for _, listener := range listeners {
+		listener.handler(s, changeType, source)
 	}
 }
 
 func (s *MutablePropertySources) Contains(name string) bool {
-	_, err := s.assertPresentAndGetIndex(name)
-	if err != nil {
-		return false
-	}
-	return true
+	_, exists := s.Get(name)
+	return exists
 }
 
 func (s *MutablePropertySources) Get(name string) (source PropertySource, exists bool) {
-	index, err := s.assertPresentAndGetIndex(name)
-	if err != nil {
+	list := s.snapshot()
+	index := indexOfPropertySource(list, name)
+	if index < 0 {
 		return nil, false
 	}
-	return s.propertySourceList[index], true
+	return list[index], true
 }
 
+/**
+遍历的是调用时的配置来源列表，consumer 中可以修改配置来源列表，不影响本次遍历
+*/
 func (s *MutablePropertySources) Each(consumer func(index int, source PropertySource) (stop bool)) {
-	list := s.getPropertySourceList()
+	list := s.snapshot()
 	for idx, item := range list {
 		if consumer(idx, item) {
 			return
@@ -99,7 +148,7 @@ func (s *MutablePropertySources) Each(consumer func(index int, source PropertySo
 }
 
 func (s *MutablePropertySources) EachRevert(consumer func(index int, source PropertySource) (stop bool)) {
-	list := s.getPropertySourceList()
+	list := s.snapshot()
 	size := len(list)
 	for i := size - 1; i >= 0; i-- {
 		if consumer(i, list[i]) {
@@ -115,14 +164,12 @@ func (s *MutablePropertySources) AddFirst(propertySource PropertySource) {
 	if xlog.IsDebugEnabled() {
 		xlog.Debug("Adding PropertySource '" + propertySource.GetName() + "' with highest search precedence")
 	}
+	s.lock.Lock()
 	// 如果已经存在，那么删除
 	s.removeIfPresent(propertySource.GetName())
 	// 添加到第一个元素
-	list := s.getPropertySourceList()
-	newList := make([]PropertySource, 0)
-	newList = append(newList, propertySource)
-	newList = append(newList, list...)
-	s.propertySourceList = newList
+	s.insertAt(0, propertySource)
+	s.lock.Unlock()
 
 	s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
 }
@@ -134,11 +181,11 @@ func (s *MutablePropertySources) AddLast(propertySource PropertySource) {
 	if xlog.IsDebugEnabled() {
 		xlog.Debug("Adding PropertySource '" + propertySource.GetName() + "' with lowest search precedence")
 	}
+	s.lock.Lock()
 	s.removeIfPresent(propertySource.GetName())
 	// 添加到最后
-	list := s.getPropertySourceList()
-	list = append(list, propertySource)
-	s.propertySourceList = list
+	s.insertAt(len(s.propertySourceList), propertySource)
+	s.lock.Unlock()
 
 	s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
 }
@@ -151,137 +198,193 @@ func (s *MutablePropertySources) AddBefore(relativePropertySourceName string, pr
 		xlog.Debug("Adding PropertySource '" + propertySource.GetName() +
 			"' with search precedence immediately higher than '" + relativePropertySourceName + "'")
 	}
+	return s.addRelative(relativePropertySourceName, propertySource, 0)
+}
+
+func (s *MutablePropertySources) AddAfter(relativePropertySourceName string, propertySource PropertySource) error {
+	if xlog.IsDebugEnabled() {
+		xlog.Debug("Adding PropertySource '" + propertySource.GetName() +
+			"' with search precedence immediately lower than '" + relativePropertySourceName + "'")
+	}
+	return s.addRelative(relativePropertySourceName, propertySource, 1)
+}
 
+func (s *MutablePropertySources) addRelative(relativePropertySourceName string, propertySource PropertySource, offset int) error {
 	if relativePropertySourceName == propertySource.GetName() {
 		return errors.New("PropertySource named '" + relativePropertySourceName + "' cannot be added relative to itself")
 	}
-	s.removeIfPresent(propertySource.GetName())
 
-	list := s.getPropertySourceList()
-	// 检查要插入到之前的那个配置来源是否存在
+	s.lock.Lock()
+	s.removeIfPresent(propertySource.GetName())
+	// 检查要插入到之前（之后）的那个配置来源是否存在
 	index, err := s.assertPresentAndGetIndex(relativePropertySourceName)
 	if err != nil {
+		s.lock.Unlock()
 		return err
 	}
+	s.insertAt(index+offset, propertySource)
+	s.lock.Unlock()
 
-	// 添加到之前
-	leftList := list[0:index]
-	rightList := list[index:]
+	s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
+	return nil
+}
 
-	newList := make([]PropertySource, 0)
-	if len(leftList) > 0 {
-		newList = append(newList, leftList...)
+func (s *MutablePropertySources) Replace(name string, propertySource PropertySource) error {
+	if xlog.IsDebugEnabled() {
+		xlog.Debug("Replacing PropertySource '" + name + "' with '" + propertySource.GetName() + "'")
 	}
-	newList = append(newList, propertySource)
-	if len(rightList) > 0 {
-		newList = append(newList, rightList...)
+
+	s.lock.Lock()
+	index, err := s.assertPresentAndGetIndex(name)
+	if err != nil {
+		s.lock.Unlock()
+		return err
 	}
+	// 复制之后替换，不修改正在被遍历的列表
+	newList := append([]PropertySource{}, s.propertySourceList...)
+	newList[index] = propertySource
 	s.propertySourceList = newList
-	s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
+	s.lock.Unlock()
+
+	s.onPropertySourceChanged(PropertySourcesChangeType_Update, propertySource)
 	return nil
 }
 
-func (s *MutablePropertySources) AddAfter(relativePropertySourceName string, propertySource PropertySource) error {
+/**
+按照 Order 插入，插入到第一个 Order 比它大的配置来源之前，Order 相同的话按照添加顺序，参考 PropertySourceOrder
+*/
+func (s *MutablePropertySources) AddByOrder(propertySource PropertySource) {
+	order := PropertySourceOrder(propertySource)
 	if xlog.IsDebugEnabled() {
-		xlog.Debug("Adding PropertySource '" + propertySource.GetName() +
-			"' with search precedence immediately lower than '" + relativePropertySourceName + "'")
+		xlog.Debug("Adding PropertySource '"+propertySource.GetName()+"' with order ", order)
 	}
-	if relativePropertySourceName == propertySource.GetName() {
-		return errors.New("PropertySource named '" + relativePropertySourceName + "' cannot be added relative to itself")
-	}
-
+	s.lock.Lock()
 	s.removeIfPresent(propertySource.GetName())
 
-	list := s.getPropertySourceList()
-	// 检查要插入到之前的那个配置来源是否存在
-	index, err := s.assertPresentAndGetIndex(relativePropertySourceName)
-	if err != nil {
-		return err
-	}
-	if index == len(list)-1 {
-		// 刚好最后一个
-		s.propertySourceList = append(list, propertySource)
-		s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
-		return nil
+	list := s.propertySourceList
+	index := len(list)
+	for i, item := range list {
+		if PropertySourceOrder(item) > order {
+			index = i
+			break
+		}
 	}
+	s.insertAt(index, propertySource)
+	s.lock.Unlock()
 
-	// 添加到之后
-	leftList := list[0 : index+1]
-	rightList := list[index+1:]
+	s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
+}
 
-	newList := make([]PropertySource, 0)
-	if len(leftList) > 0 {
-		newList = append(newList, leftList...)
+/**
+删除指定名称的配置来源
+@return 被删除的配置来源，不存在的话返回 nil
+*/
+func (s *MutablePropertySources) Remove(name string) PropertySource {
+	if xlog.IsDebugEnabled() {
+		xlog.Debug("Removing PropertySource '" + name + "'")
 	}
-	newList = append(newList, propertySource)
-	if len(rightList) > 0 {
-		newList = append(newList, rightList...)
+	s.lock.Lock()
+	removed := s.removeIfPresent(name)
+	s.lock.Unlock()
+	if removed != nil {
+		s.onPropertySourceChanged(PropertySourcesChangeType_Remove, removed)
 	}
-	s.propertySourceList = newList
+	return removed
+}
 
-	s.onPropertySourceChanged(PropertySourcesChangeType_Add, propertySource)
-	return nil
+/**
+将指定名称的配置来源移动到 relativePropertySourceName 之前，任何一个不存在则返回异常
+*/
+func (s *MutablePropertySources) MoveBefore(name string, relativePropertySourceName string) error {
+	if xlog.IsDebugEnabled() {
+		xlog.Debug("Moving PropertySource '" + name + "' with search precedence immediately higher than '" + relativePropertySourceName + "'")
+	}
+	return s.move(name, relativePropertySourceName, 0)
 }
 
-func (s *MutablePropertySources) Replace(name string, propertySource PropertySource) error {
+/**
+将指定名称的配置来源移动到 relativePropertySourceName 之后，任何一个不存在则返回异常
+*/
+func (s *MutablePropertySources) MoveAfter(name string, relativePropertySourceName string) error {
 	if xlog.IsDebugEnabled() {
-		xlog.Debug("Replacing PropertySource '" + name + "' with '" + propertySource.GetName() + "'")
+		xlog.Debug("Moving PropertySource '" + name + "' with search precedence immediately lower than '" + relativePropertySourceName + "'")
 	}
+	return s.move(name, relativePropertySourceName, 1)
+}
 
-	index, err := s.assertPresentAndGetIndex(name)
-	if err != nil {
+func (s *MutablePropertySources) move(name string, relativePropertySourceName string, offset int) error {
+	if name == relativePropertySourceName {
+		return errors.New("PropertySource named '" + relativePropertySourceName + "' cannot be moved relative to itself")
+	}
+	s.lock.Lock()
+	if _, err := s.assertPresentAndGetIndex(relativePropertySourceName); err != nil {
+		s.lock.Unlock()
 		return err
 	}
-	// 直接替换
-	s.propertySourceList[index] = propertySource
+	propertySource := s.removeIfPresent(name)
+	if propertySource == nil {
+		s.lock.Unlock()
+		return errors.New("PropertySource named '" + name + "' does not exist")
+	}
 
-	s.onPropertySourceChanged(PropertySourcesChangeType_Update, propertySource)
+	relativeIndex, _ := s.assertPresentAndGetIndex(relativePropertySourceName)
+	s.insertAt(relativeIndex+offset, propertySource)
+	s.lock.Unlock()
+
+	s.onPropertySourceChanged(PropertySourcesChangeType_Move, propertySource)
 	return nil
 }
 
+/**
+以下方法需要持有 lock 的写锁，修改的时候都生成新的列表
+*/
+func (s *MutablePropertySources) insertAt(index int, propertySource PropertySource) {
+	list := s.propertySourceList
+	newList := make([]PropertySource, 0, len(list)+1)
+	newList = append(newList, list[0:index]...)
+	newList = append(newList, propertySource)
+	newList = append(newList, list[index:]...)
+	s.propertySourceList = newList
+}
+
 func (s *MutablePropertySources) Size() int {
-	return len(s.getPropertySourceList())
+	return len(s.snapshot())
 }
 
-func (s *MutablePropertySources) getPropertySourceList() []PropertySource {
-	if s.propertySourceList == nil {
-		s.propertySourceList = make([]PropertySource, 0)
-	}
+/**
+当前的配置来源列表，写时复制，调用方不持有锁也可以安全遍历，但是不能修改
+*/
+func (s *MutablePropertySources) snapshot() []PropertySource {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return s.propertySourceList
 }
 
 func (s *MutablePropertySources) removeIfPresent(name string) PropertySource {
-	list := s.getPropertySourceList()
-	if len(list) < 1 {
+	list := s.propertySourceList
+	index := indexOfPropertySource(list, name)
+	if index < 0 {
 		return nil
 	}
-	if len(list) == 1 {
-		if list[0].GetName() == name {
-			s.propertySourceList = make([]PropertySource, 0)
-			return list[0]
-		}
-	} else {
-		var deleted PropertySource = nil
-		newList := make([]PropertySource, 0)
-		for _, item := range list {
-			if item.GetName() != name {
-				newList = append(newList, item)
-			} else{
-				deleted = item
-			}
-		}
-		s.propertySourceList = newList
-		return deleted
-	}
-	return nil
+	newList := make([]PropertySource, 0, len(list)-1)
+	newList = append(newList, list[0:index]...)
+	newList = append(newList, list[index+1:]...)
+	s.propertySourceList = newList
+	return list[index]
 }
 
 func (s *MutablePropertySources) assertPresentAndGetIndex(name string) (index int, err error) {
-	list := s.getPropertySourceList()
+	if index = indexOfPropertySource(s.propertySourceList, name); index < 0 {
+		return -1, errors.New("PropertySource named '" + name + "' does not exist")
+	}
+	return index, nil
+}
+
+func indexOfPropertySource(list []PropertySource, name string) int {
 	for index, item := range list {
 		if item.GetName() == name {
-			return index, nil
+			return index
 		}
 	}
-	return -1, errors.New("PropertySource named '" + name + "' does not exist")
+	return -1
 }
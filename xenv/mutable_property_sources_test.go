@@ -0,0 +1,109 @@
+package xenv
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type orderedMapPropertySource struct {
+	MapPropertySource
+	order int
+}
+
+func (o *orderedMapPropertySource) Order() int {
+	return o.order
+}
+
+func newOrderedMapPropertySource(name string, order int) *orderedMapPropertySource {
+	return &orderedMapPropertySource{MapPropertySource: *NewMapPropertySource(name, map[string]string{}), order: order}
+}
+
+func propertySourceNames(sources *MutablePropertySources) []string {
+	names := make([]string, 0)
+	sources.Each(func(index int, source PropertySource) (stop bool) {
+		names = append(names, source.GetName())
+		return false
+	})
+	return names
+}
+
+func TestMutablePropertySources_RemoveAndMove(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("a", nil))
+	sources.AddLast(NewMapPropertySource("b", nil))
+	sources.AddLast(NewMapPropertySource("c", nil))
+
+	changes := make([]PropertySourcesChangeType, 0)
+	sources.Subscribe(func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource) {
+		changes = append(changes, changeType)
+	})
+
+	assert.Nil(t, sources.MoveBefore("c", "a"))
+	assert.Equal(t, []string{"c", "a", "b"}, propertySourceNames(sources))
+
+	assert.Nil(t, sources.MoveAfter("c", "b"))
+	assert.Equal(t, []string{"a", "b", "c"}, propertySourceNames(sources))
+
+	assert.NotNil(t, sources.MoveAfter("c", "c"))
+	assert.NotNil(t, sources.MoveAfter("x", "a"))
+
+	assert.Equal(t, "b", sources.Remove("b").GetName())
+	assert.Nil(t, sources.Remove("b"))
+	assert.Equal(t, []string{"a", "c"}, propertySourceNames(sources))
+
+	assert.Equal(t, []PropertySourcesChangeType{PropertySourcesChangeType_Move, PropertySourcesChangeType_Move, PropertySourcesChangeType_Remove}, changes)
+}
+
+func TestMutablePropertySources_AddByOrder(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("default", nil))
+	sources.AddByOrder(newOrderedMapPropertySource("low", 100))
+	sources.AddByOrder(newOrderedMapPropertySource("high", -100))
+	sources.AddByOrder(newOrderedMapPropertySource("middle", 50))
+	sources.AddByOrder(newOrderedMapPropertySource("middle2", 50))
+
+	assert.Equal(t, []string{"high", "default", "middle", "middle2", "low"}, propertySourceNames(sources))
+}
+
+func TestMutablePropertySources_ConcurrentMutation(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("a", nil))
+	sources.AddLast(NewMapPropertySource("b", nil))
+	// 监听器中遍历配置来源列表
+	sources.Subscribe(func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource) {
+		self.Each(func(index int, source PropertySource) (stop bool) {
+			return source == nil
+		})
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		name := fmt.Sprintf("s%d", i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				sources.AddLast(NewMapPropertySource(name, nil))
+				_ = sources.MoveBefore(name, "a")
+				_ = sources.Replace(name, NewMapPropertySource(name, nil))
+				sources.AddByOrder(newOrderedMapPropertySource(name, j%3-1))
+				sources.Remove(name)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				sources.EachRevert(func(index int, source PropertySource) (stop bool) {
+					return source == nil
+				})
+				sources.Contains(name)
+				sources.Get("b")
+				sources.Size()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"a", "b"}, propertySourceNames(sources))
+}
@@ -1,10 +1,13 @@
 package xenv
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/xkgo/xkit/xcontext"
 	"github.com/xkgo/xkit/xlog"
-	"regexp"
+	"math/rand"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -31,6 +34,26 @@ func (p *PropertyReaderWrapper) ReadAll() (kvs map[string]string, err error) {
 	return p.Reader()
 }
 
+const (
+	// 默认最大退避时间
+	DefaultPollingMaxBackoff = 5 * time.Minute
+	// 默认抖动比例，即在计算出来的间隔基础上随机 ±20%
+	DefaultPollingJitter = 0.2
+	// 最小轮询间隔，小于这个值的间隔按照这个值轮询，避免误传参数导致空转
+	MinPollingInterval = 100 * time.Millisecond
+)
+
+/**
+轮询状态，可以用于健康检查
+*/
+type PollingStatus struct {
+	LastSuccessTime     time.Time // 最后一次成功读取配置的时间
+	LastErrorTime       time.Time // 最后一次读取失败的时间
+	LastError           error     // 最后一次读取失败的异常，成功读取之后清空
+	ConsecutiveFailures int       // 连续失败次数
+	Version             int64     // 当前配置版本，每次读取到的配置发生变化都会加1
+}
+
 /**
 基于轮询实现的配置来源
 */
@@ -38,30 +61,63 @@ type PollingPropertySource struct {
 	Name            string            // 名称
 	PropertyReader  PropertyReader    // 配置读取实现
 	PollingInterval int64             // 轮询间隔，单位：秒
+	Interval        time.Duration     // 轮询间隔，大于 0 的话优先于 PollingInterval 使用，最小 MinPollingInterval
+	MaxBackoff      time.Duration     // 读取失败时指数退避的最大间隔，默认 DefaultPollingMaxBackoff
+	Jitter          float64           // 抖动比例，取值 [0, 1)，默认 DefaultPollingJitter
 	kvs             map[string]string // 内存配置项， key->value
+	status          PollingStatus     // 轮询状态
+	lock            sync.RWMutex      // 保护 kvs、status
 	scheduleOnce    sync.Once
+	ctx             context.Context    // 轮询上下文，取消之后停止轮询
+	cancel          context.CancelFunc // 取消轮询
+	done            chan struct{}      // 轮询协程退出之后关闭
 	/**
 	配置key变更订阅列表
 	*/
-	propertyChangeListeners []*PropertyChangeListener
+	propertyChangeListeners PropertyChangeListenerRegistry
 }
 
 /*
 创建轮询配置源, 参数不正确的话直接抛出panic
-@param refreshInterval 刷新时间间隔，单位：秒， 小于0表示不进行轮询
+@param refreshInterval 刷新时间间隔，单位：秒， 小于等于0表示不进行轮询
 */
 func NewPollingPropertySource(name string, refreshInterval int64, reader PropertyReader) (source *PollingPropertySource, err error) {
+	return NewPollingPropertySourceWithContext(context.Background(), name, refreshInterval, reader)
+}
+
+/*
+创建轮询配置源，ctx 取消或者调用 Close 之后停止轮询, 参数不正确的话直接抛出panic
+@param refreshInterval 刷新时间间隔，单位：秒， 小于等于0表示不进行轮询
+*/
+func NewPollingPropertySourceWithContext(ctx context.Context, name string, refreshInterval int64, reader PropertyReader) (source *PollingPropertySource, err error) {
+	return newPollingPropertySource(ctx, name, refreshInterval, 0, reader)
+}
+
+/*
+创建轮询配置源，轮询间隔使用 time.Duration，可以小于 1 秒，但是不会小于 MinPollingInterval；ctx 为 nil 的话不会自动停止
+@param interval 刷新时间间隔， 小于等于0表示不进行轮询
+*/
+func NewPollingPropertySourceWithInterval(ctx context.Context, name string, interval time.Duration, reader PropertyReader) (source *PollingPropertySource, err error) {
+	return newPollingPropertySource(ctx, name, 0, interval, reader)
+}
+
+func newPollingPropertySource(ctx context.Context, name string, refreshInterval int64, interval time.Duration, reader PropertyReader) (source *PollingPropertySource, err error) {
 	if reader == nil {
-		err = errors.New("ConfigReader is required")
-		panic(err)
-		return
+		panic(errors.New("ConfigReader is required"))
 	}
 
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	source = &PollingPropertySource{
 		Name:            name,
 		PollingInterval: refreshInterval,
+		Interval:        interval,
+		MaxBackoff:      DefaultPollingMaxBackoff,
+		Jitter:          DefaultPollingJitter,
 		PropertyReader:  reader,
 	}
+	source.ctx, source.cancel = context.WithCancel(ctx)
 
 	source.Init()
 
@@ -77,16 +133,27 @@ func (p *PollingPropertySource) scheduleReload() {
 	_ = p.Reload()
 
 	p.scheduleOnce.Do(func() {
-		if p.PollingInterval < 1 {
+		if p.ctx == nil {
+			p.ctx, p.cancel = context.WithCancel(context.Background())
+		}
+		interval := p.pollingInterval()
+		if interval <= 0 {
 			return
 		}
 
 		// 调度刷新
+		p.done = make(chan struct{})
 		xcontext.RunByGoroutine(func() {
-			xlog.Info("调度刷新配置，刷新间隔：[", p.PollingInterval, "]秒")
+			defer close(p.done)
+			xlog.Info("调度刷新配置，刷新间隔：[", interval, "]")
 			for {
+				select {
+				case <-p.ctx.Done():
+					xlog.Info("配置源[" + p.Name + "]停止调度刷新配置")
+					return
+				case <-time.After(p.nextPollingDelay()):
+				}
 				_ = p.Reload()
-				time.Sleep(time.Duration(p.PollingInterval) * time.Second)
 			}
 		}, func(r interface{}, hadPanic bool) {
 			if hadPanic {
@@ -96,17 +163,96 @@ func (p *PollingPropertySource) scheduleReload() {
 	})
 }
 
+/**
+轮询间隔，Interval 优先，否则使用 PollingInterval 秒，小于 MinPollingInterval 的按照 MinPollingInterval 处理，小于等于 0 表示不轮询
+*/
+func (p *PollingPropertySource) pollingInterval() time.Duration {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Duration(p.PollingInterval) * time.Second
+	}
+	if interval > 0 && interval < MinPollingInterval {
+		interval = MinPollingInterval
+	}
+	return interval
+}
+
+/**
+计算下一次轮询的间隔，连续失败的时候按照 轮询间隔 * 2^失败次数 进行退避，最大不超过 MaxBackoff，并加上随机抖动
+*/
+func (p *PollingPropertySource) nextPollingDelay() time.Duration {
+	interval := p.pollingInterval()
+	delay := interval
+	failures := p.Status().ConsecutiveFailures
+	maxBackoff := p.MaxBackoff
+	if maxBackoff < delay {
+		maxBackoff = delay
+	}
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	if p.Jitter > 0 && p.Jitter < 1 {
+		delta := float64(delay) * p.Jitter * (rand.Float64()*2 - 1)
+		delay += time.Duration(delta)
+	}
+	if delay <= 0 {
+		delay = interval
+	}
+	return delay
+}
+
+/**
+停止轮询，并取消所有的订阅，ctx 超时之前轮询协程还没有退出的话返回 ctx.Err()，ctx 为 nil 的话一直等待轮询协程退出
+*/
+func (p *PollingPropertySource) Close(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.done != nil {
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	p.propertyChangeListeners.Clear()
+	return nil
+}
+
+/**
+获取当前轮询状态
+*/
+func (p *PollingPropertySource) Status() PollingStatus {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.status
+}
+
 /*
-重新加载配置
+重新加载配置, 读取失败或者发生 panic 的时候保留原来的配置，并返回异常
 */
 func (p *PollingPropertySource) Reload() (err error) {
 
 	defer func() {
-		defer func() {
-			if r := recover(); r != nil {
-				xlog.Error("本次配置Reload失败， error:", r)
-			}
-		}()
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reload panic: %v", r)
+		}
+		if err != nil {
+			p.lock.Lock()
+			p.status.LastError = err
+			p.status.LastErrorTime = time.Now()
+			p.status.ConsecutiveFailures++
+			failures := p.status.ConsecutiveFailures
+			p.lock.Unlock()
+			xlog.Warn("配置源["+p.Name+"]本次配置Reload失败，连续失败次数：", failures, ", error:", err)
+		}
 	}()
 
 	nkvs, err := p.PropertyReader.ReadAll()
@@ -116,17 +262,21 @@ func (p *PollingPropertySource) Reload() (err error) {
 	if nkvs == nil {
 		nkvs = make(map[string]string)
 	}
-	if p.kvs == nil {
-		p.kvs = make(map[string]string)
-	}
 
+	p.lock.Lock()
 	okvs := p.kvs
-
 	// 新的配置
 	p.kvs = nkvs
+	if okvs == nil || !reflect.DeepEqual(okvs, nkvs) {
+		p.status.Version++
+	}
+	p.status.LastSuccessTime = time.Now()
+	p.status.LastError = nil
+	p.status.ConsecutiveFailures = 0
+	p.lock.Unlock()
 
 	// 比较计算哪些属性发生变更，变化了的调用变更监听器
-	if len(p.propertyChangeListeners) < 1 || okvs == nil {
+	if p.propertyChangeListeners.Size() < 1 || okvs == nil {
 		// 首次加载
 		return
 	}
@@ -168,34 +318,12 @@ func (p *PollingPropertySource) Reload() (err error) {
 }
 
 /**
-Key 变更处理
+Key 变更处理，只输出 key，新旧值由环境脱敏之后输出
 */
 func (p *PollingPropertySource) onKeyChangeEvent(event *KeyChangeEvent) {
-	xlog.Info("["+p.Name+"]配置发生了变更：key:["+event.Key+"], ov:["+event.Ov+"], nv:["+event.Nv+"], changeType:[", event.ChangeType+"]")
+	xlog.Info("["+p.Name+"]配置发生了变更：key:["+event.Key+"], changeType:[", event.ChangeType+"]")
 	// 执行监听器
-	if len(p.propertyChangeListeners) > 0 {
-		for _, listener := range p.propertyChangeListeners {
-			keyPattern := listener.KeyPattern
-			handler := listener.Handler
-			if handler == nil {
-				continue
-			}
-			if keyPattern == "" || keyPattern == "*" || keyPattern == event.Key {
-				handler(event)
-				continue
-			}
-			regex, err := regexp.Compile(keyPattern)
-			if err == nil && regex.MatchString(event.Key) {
-				xcontext.Run(func() {
-					handler(event)
-				}, func(r interface{}, hadPanic bool) {
-					if hadPanic {
-						xlog.Warn("配置源["+p.Name+"]执行配置变更[", listener, "]发生panic： ", r)
-					}
-				})
-			}
-		}
-	}
+	p.propertyChangeListeners.Publish(p.Name, event)
 }
 
 func (p *PollingPropertySource) GetName() string {
@@ -203,28 +331,30 @@ func (p *PollingPropertySource) GetName() string {
 }
 
 func (p *PollingPropertySource) GetProperty(key string) (value string, exists bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	value, exists = p.kvs[key]
 	return
 }
 
 func (p *PollingPropertySource) GetPropertyWithDef(key string, def string) string {
-	if value, exists := p.kvs[key]; exists && len(value) > 0 {
+	if value, exists := p.GetProperty(key); exists && len(value) > 0 {
 		return value
 	}
 	return def
 }
 
 func (p *PollingPropertySource) Each(consumer func(key string, value string) (stop bool)) {
-	for k, v := range p.kvs {
+	p.lock.RLock()
+	kvs := p.kvs
+	p.lock.RUnlock()
+	for k, v := range kvs {
 		if consumer(k, v) {
 			return
 		}
 	}
 }
 
-func (p *PollingPropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) {
-	if p.propertyChangeListeners == nil {
-		p.propertyChangeListeners = make([]*PropertyChangeListener, 0)
-	}
-	p.propertyChangeListeners = append(p.propertyChangeListeners, NewPropertyChangeListener(keyPattern, handler))
+func (p *PollingPropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription {
+	return p.propertyChangeListeners.Add(keyPattern, handler)
 }
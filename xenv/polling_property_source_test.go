@@ -1,7 +1,10 @@
 package xenv
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"strconv"
 	"testing"
@@ -28,3 +31,123 @@ func TestPollingPropertySource_Subscribe(t *testing.T) {
 	}
 
 }
+
+func TestPollingPropertySource_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source, _ := NewPollingPropertySourceWithContext(ctx, "test", 1, NewPropertyReader(func() (kvs map[string]string, err error) {
+		return map[string]string{"name": "xenv"}, nil
+	}))
+	source.Subscribe("", func(event *KeyChangeEvent) {})
+
+	assert.Equal(t, "xenv", source.GetPropertyWithDef("name", ""))
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer closeCancel()
+	assert.Nil(t, source.Close(closeCtx))
+	assert.Equal(t, 0, source.propertyChangeListeners.Size())
+
+	select {
+	case <-source.done:
+	default:
+		t.Fatal("polling goroutine should be stopped")
+	}
+}
+
+func TestPollingPropertySource_CloseNilContext(t *testing.T) {
+	source, _ := NewPollingPropertySource("test", 1, NewPropertyReader(func() (kvs map[string]string, err error) {
+		return map[string]string{"name": "xenv"}, nil
+	}))
+	assert.Nil(t, source.Close(nil))
+	<-source.done
+}
+
+func TestPollingPropertySource_ContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	source, _ := NewPollingPropertySourceWithContext(ctx, "test", 1, NewPropertyReader(func() (kvs map[string]string, err error) {
+		return map[string]string{}, nil
+	}))
+	cancel()
+
+	select {
+	case <-source.done:
+	case <-time.After(time.Second):
+		t.Fatal("polling goroutine should be stopped after context canceled")
+	}
+}
+
+func TestPollingPropertySource_Status(t *testing.T) {
+	fail := true
+	source, _ := NewPollingPropertySource("test", 0, NewPropertyReader(func() (kvs map[string]string, err error) {
+		if fail {
+			return nil, errors.New("remote unavailable")
+		}
+		return map[string]string{"name": "xenv"}, nil
+	}))
+
+	status := source.Status()
+	assert.NotNil(t, status.LastError)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.Equal(t, int64(0), status.Version)
+
+	assert.NotNil(t, source.Reload())
+	assert.Equal(t, 2, source.Status().ConsecutiveFailures)
+
+	fail = false
+	assert.Nil(t, source.Reload())
+	status = source.Status()
+	assert.Nil(t, status.LastError)
+	assert.Equal(t, 0, status.ConsecutiveFailures)
+	assert.Equal(t, int64(1), status.Version)
+	assert.False(t, status.LastSuccessTime.IsZero())
+
+	// 配置没有变化，版本号不变
+	assert.Nil(t, source.Reload())
+	assert.Equal(t, int64(1), source.Status().Version)
+}
+
+func TestPollingPropertySource_NextPollingDelay(t *testing.T) {
+	source := &PollingPropertySource{
+		Interval:   100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
+	assert.Equal(t, 100*time.Millisecond, source.nextPollingDelay())
+
+	source.status.ConsecutiveFailures = 2
+	assert.Equal(t, 400*time.Millisecond, source.nextPollingDelay())
+
+	source.status.ConsecutiveFailures = 10
+	assert.Equal(t, time.Second, source.nextPollingDelay())
+
+	source.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := source.nextPollingDelay()
+		assert.True(t, delay >= 500*time.Millisecond && delay <= 1500*time.Millisecond)
+	}
+}
+
+func TestPollingPropertySource_PollingInterval(t *testing.T) {
+	source := &PollingPropertySource{PollingInterval: 5}
+	assert.Equal(t, 5*time.Second, source.pollingInterval())
+
+	source.Interval = 500 * time.Millisecond
+	assert.Equal(t, 500*time.Millisecond, source.pollingInterval())
+
+	// 过小的间隔按照最小间隔处理，避免空转
+	source.Interval = 5
+	assert.Equal(t, MinPollingInterval, source.pollingInterval())
+
+	source = &PollingPropertySource{}
+	assert.Equal(t, time.Duration(0), source.pollingInterval())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source, _ = NewPollingPropertySourceWithInterval(ctx, "test", 200*time.Millisecond, NewPropertyReader(func() (kvs map[string]string, err error) {
+		return map[string]string{"name": "xenv"}, nil
+	}))
+	assert.Equal(t, 200*time.Millisecond, source.pollingInterval())
+	assert.Equal(t, "xenv", source.GetPropertyWithDef("name", ""))
+	assert.Nil(t, source.Close(context.Background()))
+}
@@ -1,6 +1,7 @@
 package xenv
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 )
@@ -22,8 +23,11 @@ type KeyChangeEvent struct {
 	ChangeType KeyChangeType // 变更类型
 }
 
+/**
+敏感配置的新旧值会被脱敏，参考 IsSecretKey
+*/
 func (e *KeyChangeEvent) String() string {
-	return fmt.Sprintf("[%v][%s], old:[%s], new:[%s]", e.ChangeType, e.Key, e.Ov, e.Nv)
+	return fmt.Sprintf("[%v][%s], old:[%s], new:[%s]", e.ChangeType, e.Key, MaskPropertyValue(e.Key, e.Ov), MaskPropertyValue(e.Key, e.Nv))
 }
 
 type PropertyChangeListener struct {
@@ -44,6 +48,35 @@ func NewPropertyChangeListener(keyPattern string, handler func(event *KeyChangeE
 	}
 }
 
+/**
+配置 key 是否匹配
+*/
+func (l *PropertyChangeListener) Matches(key string) bool {
+	if l.KeyPattern == "" || l.KeyPattern == "*" || l.KeyPattern == key {
+		return true
+	}
+	return l.Regex != nil && l.Regex.MatchString(key)
+}
+
+/**
+可选实现，返回配置项更具体的来源，比如配置项所在的文件
+*/
+type PropertyOriginProvider interface {
+	GetPropertyOrigin(key string) (origin string, exists bool)
+}
+
+/**
+配置项的来源描述，配置来源实现了 PropertyOriginProvider 的话优先使用其返回的来源，否则使用配置来源名称
+*/
+func GetPropertyOrigin(source PropertySource, key string) string {
+	if provider, ok := source.(PropertyOriginProvider); ok {
+		if origin, exists := provider.GetPropertyOrigin(key); exists {
+			return origin
+		}
+	}
+	return source.GetName()
+}
+
 type PropertySource interface {
 	/**
 	配置源名称
@@ -70,6 +103,17 @@ type PropertySource interface {
 
 	/**
 	订阅变更, keyPattern: 等值、正则匹配，如果为空字符串或者 * 那么表示所有，如果是个合法的正则，那么就按照正则匹配
+	@return 订阅句柄，用于取消订阅
+	*/
+	Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription
+}
+
+/**
+可关闭的配置来源，比如轮询远程配置的来源，关闭之后停止轮询，并取消所有的订阅
+*/
+type Closeable interface {
+	/**
+	关闭，ctx 用于控制等待关闭完成的超时时间
 	*/
-	Subscribe(keyPattern string, handler func(event *KeyChangeEvent))
+	Close(ctx context.Context) error
 }
@@ -13,6 +13,8 @@ type PropertySourcesPropertyResolver struct {
 	ignoreUnresolvableNestedPlaceholders bool                                    // 是否忽略无法处理的占位符，如果忽略则不处理，不忽略的话，那么遇到不能解析的占位符直接 panic
 	nonStrictHelper                      *xplaceholder.PropertyPlaceholderHelper // 当遇到未定义的配置项时，不进行替换，也不会抛出异常
 	strictHelper                         *xplaceholder.PropertyPlaceholderHelper // 当遇到未定义的配置项时，直接 panic
+	deprecatedKeys                       *deprecatedKeyRegistry                  // 废弃的配置 key，新 key 不存在的时候回退使用旧 key
+	secretKeys                           *secretKeyRegistry                      // 环境中通过 secret tag 绑定的敏感配置 key，日志输出的时候脱敏
 }
 
 /**
@@ -27,68 +29,109 @@ func NewPropertySourcesPropertyResolver(propertySources PropertySources, ignoreU
 }
 
 func (p *PropertySourcesPropertyResolver) ContainsProperty(key string) bool {
+	_, _, contains := p.findProperty(key)
+	return contains
+}
+
+/**
+查找配置项的原始值，新 key 不存在的话回退查找废弃的旧 key
+@return source 配置项所在的配置来源
+*/
+func (p *PropertySourcesPropertyResolver) findProperty(key string) (value string, source PropertySource, exists bool) {
 	if nil == p.propertySources {
-		return false
+		return "", nil, false
+	}
+	value, source, exists = p.findPropertyInSources(key)
+	if exists {
+		return
 	}
-	contains := false
-	p.propertySources.Each(func(index int, source PropertySource) (stop bool) {
-		if _, ok := source.GetProperty(key); ok {
-			contains = true
+	for _, info := range p.deprecatedKeys.oldKeys(key) {
+		if value, source, exists = p.findPropertyInSources(info.OldKey); exists {
+			p.deprecatedKeys.warnOnce(info, GetPropertyOrigin(source, info.OldKey))
+			return
+		}
+	}
+	return "", nil, false
+}
+
+func (p *PropertySourcesPropertyResolver) findPropertyInSources(key string) (value string, source PropertySource, exists bool) {
+	p.propertySources.Each(func(index int, item PropertySource) (stop bool) {
+		if val, ok := item.GetProperty(key); ok {
+			value, source, exists = val, item, true
 			return true
 		}
 		return false
 	})
-	return contains
+	return
 }
 
 /**
 获取配置项
 @param resolveNestedPlaceholders 是否需要处理占位符
 */
-func (p *PropertySourcesPropertyResolver) doGetProperty(key string, resolveNestedPlaceholders bool) (value string, exists bool) {
-	if nil == p.propertySources {
-		return "", false
-	}
-	p.propertySources.Each(func(index int, source PropertySource) (stop bool) {
-		if val, ok := source.GetProperty(key); ok {
-			exists = true
-			value = val
-
-			// 找到了key，加下日志
-			if xlog.IsDebugEnabled() {
-				xlog.Debug("Found key '" + key + "' in PropertySource '" + source.GetName() + "' with value: " + value)
-			}
-
-			// 看看是否需要替换占位符, ${...}, 长度至少是4 才能构成一个占位符
-			if resolveNestedPlaceholders && len(value) > 4 {
-				value = p.resolveNestedPlaceholders(value)
-			}
-			return true
-		}
-		return false
-	})
+func (p *PropertySourcesPropertyResolver) doGetProperty(key string, resolveNestedPlaceholders bool) (value string, exists bool, err error) {
+	value, source, exists := p.findProperty(key)
+	if !exists {
+		return "", false, nil
+	}
+
+	// 找到了key，加下日志
+	if xlog.IsDebugEnabled() {
+		xlog.Debug("Found key '" + key + "' in PropertySource '" + source.GetName() + "' with value: " + p.maskPropertyValue(key, value))
+	}
+
+	// 看看是否需要替换占位符, ${...}, 长度至少是4 才能构成一个占位符
+	if resolveNestedPlaceholders && len(value) > 4 {
+		value, err = p.resolveNestedPlaceholders(value)
+	}
 	return
 }
 
+func (p *PropertySourcesPropertyResolver) maskPropertyValue(key, value string) string {
+	if p.secretKeys != nil && len(value) > 0 && p.secretKeys.matches(key) {
+		return SecretMask
+	}
+	return MaskPropertyValue(key, value)
+}
+
 func (p *PropertySourcesPropertyResolver) GetProperty(key string) (value string, exists bool) {
+	value, exists, err := p.doGetProperty(key, true)
+	if err != nil {
+		panic(err.Error())
+	}
+	return
+}
+
+/**
+获取配置项的字符串值，和 GetProperty 一样，只是占位符存在循环引用或者无法识别的时候返回异常而不是 panic，
+异常为 *xplaceholder.CircularPlaceholderError 或者 *xplaceholder.UnresolvablePlaceholderError，包含占位符链
+*/
+func (p *PropertySourcesPropertyResolver) GetPropertyE(key string) (value string, exists bool, err error) {
 	return p.doGetProperty(key, true)
 }
 
 func (p *PropertySourcesPropertyResolver) GetPropertyWithDef(key string, def string) string {
-	if value, exists := p.doGetProperty(key, true); exists {
+	if value, exists := p.GetProperty(key); exists {
 		return value
 	}
 	return def
 }
 
 func (p *PropertySourcesPropertyResolver) GetRequiredProperty(key string) string {
-	if value, exists := p.doGetProperty(key, true); exists {
+	if value, exists := p.GetProperty(key); exists {
 		return value
 	}
 	panic("Required key '" + key + "' not found")
 }
 
 func (p *PropertySourcesPropertyResolver) ResolvePlaceholders(text string) string {
+	return mustResolve(p.ResolvePlaceholdersE(text))
+}
+
+/**
+和 ResolvePlaceholders 一样，只是占位符存在循环引用的时候返回异常而不是 panic
+*/
+func (p *PropertySourcesPropertyResolver) ResolvePlaceholdersE(text string) (string, error) {
 	if p.nonStrictHelper == nil {
 		p.nonStrictHelper = p.createPlaceholderHelper(true)
 	}
@@ -96,20 +139,51 @@ func (p *PropertySourcesPropertyResolver) ResolvePlaceholders(text string) strin
 }
 
 func (p *PropertySourcesPropertyResolver) ResolveRequiredPlaceholders(text string) string {
+	return mustResolve(p.ResolveRequiredPlaceholdersE(text))
+}
+
+/**
+和 ResolveRequiredPlaceholders 一样，只是遇到无法识别或者循环引用的占位符的时候返回异常而不是 panic，适合在配置热更新等不能 panic 的场景使用
+*/
+func (p *PropertySourcesPropertyResolver) ResolveRequiredPlaceholdersE(text string) (string, error) {
 	if p.strictHelper == nil {
 		p.strictHelper = p.createPlaceholderHelper(false)
 	}
 	return p.doResolvePlaceholders(text, p.strictHelper)
 }
 
+/**
+处理占位符，无法识别的占位符保留原样，同时返回占位符引用到的所有配置项（包括嵌套引用的），用于判断是否引用了敏感配置
+*/
+func (p *PropertySourcesPropertyResolver) resolvePlaceholdersWithReferences(text string) (value string, references []string, err error) {
+	if p.nonStrictHelper == nil {
+		p.nonStrictHelper = p.createPlaceholderHelper(true)
+	}
+	value, err = p.nonStrictHelper.ReplacePlaceholdersE(text, func(key string) string {
+		references = append(references, key)
+		return p.getPropertyAsRawString(key)
+	})
+	return
+}
+
+/**
+占位符处理失败的话 panic，和旧版本一样 panic 的值为异常信息字符串
+*/
+func mustResolve(value string, err error) string {
+	if err != nil {
+		panic(err.Error())
+	}
+	return value
+}
+
 /**
 处理占位符，将占位符为 ${...} 替换掉
 */
-func (p *PropertySourcesPropertyResolver) resolveNestedPlaceholders(text string) string {
+func (p *PropertySourcesPropertyResolver) resolveNestedPlaceholders(text string) (string, error) {
 	if p.ignoreUnresolvableNestedPlaceholders {
-		return p.ResolvePlaceholders(text)
+		return p.ResolvePlaceholdersE(text)
 	} else {
-		return p.ResolveRequiredPlaceholders(text)
+		return p.ResolveRequiredPlaceholdersE(text)
 	}
 }
 
@@ -117,10 +191,14 @@ func (p *PropertySourcesPropertyResolver) createPlaceholderHelper(ignoreUnresolv
 	return xplaceholder.NewPropertyPlaceholderHelper(xplaceholder.DefaultPlaceholderPrefix, xplaceholder.DefaultPlaceholderSuffix, xplaceholder.DefaultPlaceholderValueSeparator, ignoreUnresolvablePlaceholders)
 }
 
+/**
+占位符对应配置项的原始值，嵌套的占位符由 PropertyPlaceholderHelper 继续处理，这样才能检查出跨配置项的循环引用
+*/
 func (p *PropertySourcesPropertyResolver) getPropertyAsRawString(key string) string {
-	return p.GetPropertyWithDef(key, "")
+	value, _, _ := p.findProperty(key)
+	return value
 }
 
-func (p *PropertySourcesPropertyResolver) doResolvePlaceholders(text string, helper *xplaceholder.PropertyPlaceholderHelper) string {
-	return helper.ReplacePlaceholders(text, p.getPropertyAsRawString)
+func (p *PropertySourcesPropertyResolver) doResolvePlaceholders(text string, helper *xplaceholder.PropertyPlaceholderHelper) (string, error) {
+	return helper.ReplacePlaceholdersE(text, p.getPropertyAsRawString)
 }
@@ -0,0 +1,144 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xkgo/xkit/xplaceholder"
+	"testing"
+	"time"
+)
+
+type PlaceholderServerConfig struct {
+	Url  string `ck:"url"`
+	Name string `ck:"name"`
+}
+
+type PlaceholderClusterConfig struct {
+	Nodes map[string]*PlaceholderServerConfig `ck:"nodes" expand:"true"`
+	Ports map[int]*PlaceholderServerConfig    `ck:"ports" expand:"true"`
+}
+
+func TestPropertySourcesPropertyResolver_GetPropertyE(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{
+		"a":    "a-${b}",
+		"b":    "x-${c}",
+		"c":    "${a}",
+		"host": "127.0.0.1",
+		"url":  "http://${host}:${port}",
+	})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	resolver := NewPropertySourcesPropertyResolver(sources, false)
+
+	_, exists, err := resolver.GetPropertyE("a")
+	assert.True(t, exists)
+	circularErr, ok := err.(*xplaceholder.CircularPlaceholderError)
+	assert.True(t, ok)
+	assert.Equal(t, "b", circularErr.Placeholder)
+	assert.Equal(t, []string{"b", "c", "a", "b"}, circularErr.Chain)
+	assert.Panics(t, func() { resolver.GetProperty("a") })
+
+	_, _, err = resolver.GetPropertyE("url")
+	unresolvableErr, ok := err.(*xplaceholder.UnresolvablePlaceholderError)
+	assert.True(t, ok)
+	assert.Equal(t, "port", unresolvableErr.Placeholder)
+
+	value, err := resolver.ResolveRequiredPlaceholdersE("${host}")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", value)
+	_, err = resolver.ResolveRequiredPlaceholdersE("${port}")
+	assert.NotNil(t, err)
+	// 和旧版本一样 panic 的值为异常信息字符串
+	assert.PanicsWithValue(t, err.Error(), func() { resolver.ResolveRequiredPlaceholders("${port}") })
+
+	value, err = resolver.ResolvePlaceholdersE("${host}:${port}")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:${port}", value)
+	_, err = resolver.ResolvePlaceholdersE("${c}")
+	assert.NotNil(t, err)
+
+	_, exists, err = resolver.GetPropertyE("not.exists")
+	assert.False(t, exists)
+	assert.Nil(t, err)
+}
+
+func TestStandardEnvironment_RebindPlaceholderError(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"server.url": "http://${server.host}", "server.host": "127.0.0.1", "server.name": "demo"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &PlaceholderServerConfig{}
+	_, err := env.BindProperties("server.", cfg, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://127.0.0.1", cfg.Url)
+
+	failures := make(chan *RebindFailure, 1)
+	env.OnRebindFailure(func(failure *RebindFailure) {
+		failures <- failure
+	})
+	// 热更新时占位符循环引用，拒绝更新，不会 panic
+	source.Put("server.url", "http://${server.url}")
+	select {
+	case failure := <-failures:
+		assert.Equal(t, []string{"server.url"}, failure.ChangedKeys)
+		_, ok := failure.Err.(*xplaceholder.CircularPlaceholderError)
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("未收到热更新失败通知")
+	}
+	assert.Equal(t, "http://127.0.0.1", cfg.Url)
+
+	// 绑定的时候占位符处理失败返回异常，其他属性照常绑定，并且不会保留绑定
+	failed := &PlaceholderServerConfig{}
+	_, err = env.BindProperties("server.", failed, true)
+	_, ok := err.(*xplaceholder.CircularPlaceholderError)
+	assert.True(t, ok)
+	assert.Equal(t, "", failed.Url)
+	assert.Equal(t, "demo", failed.Name)
+	assert.False(t, env.UnbindProperties(failed))
+}
+
+func TestStandardEnvironment_RebindMapPlaceholderError(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"cluster.nodes.a.url": "http://${cluster.host}", "cluster.host": "127.0.0.1"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &PlaceholderClusterConfig{}
+	_, err := env.BindProperties("cluster.", cfg, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://127.0.0.1", cfg.Nodes["a"].Url)
+
+	failures := make(chan *RebindFailure, 1)
+	env.OnRebindFailure(func(failure *RebindFailure) {
+		failures <- failure
+	})
+	awaitFailure := func(key string) error {
+		select {
+		case failure := <-failures:
+			assert.Equal(t, []string{key}, failure.ChangedKeys)
+			return failure.Err
+		case <-time.After(time.Second):
+			t.Fatal("未收到热更新失败通知")
+		}
+		return nil
+	}
+
+	// map 元素热更新时占位符循环引用，拒绝更新，不会 panic
+	source.Put("cluster.nodes.b.url", "http://${cluster.nodes.b.url}")
+	_, ok := awaitFailure("cluster.nodes.b.url").(*xplaceholder.CircularPlaceholderError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(cfg.Nodes))
+
+	// map 的 key 无法转换，同样拒绝更新
+	source.Put("cluster.ports.abc.url", "http://127.0.0.1")
+	assert.NotNil(t, awaitFailure("cluster.ports.abc.url"))
+	assert.Equal(t, 0, len(cfg.Ports))
+
+	source.Put("cluster.nodes.b.url", "http://${cluster.host}:8080")
+	deadline := time.Now().Add(time.Second)
+	for len(cfg.Nodes) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "http://127.0.0.1:8080", cfg.Nodes["b"].Url)
+}
@@ -0,0 +1,220 @@
+package xenv
+
+import (
+	"fmt"
+	"github.com/xkgo/xkit/xcontext"
+	"github.com/xkgo/xkit/xlog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// 配置变更之后，合并同一批变更的等待时间，等待时间内的变更只会回调一次
+	DefaultRebindCallbackDelay = 100 * time.Millisecond
+)
+
+/**
+配置 Bean 可选实现，配置热更新之后回调，同一批变更只回调一次，回调时所有受影响的属性都已经更新
+*/
+type PropertiesChangedListener interface {
+	/**
+	@param changedKeys 发生变更的配置 key，按照字典序排序
+	*/
+	OnPropertiesChanged(changedKeys []string)
+}
+
+/**
+配置 Bean 可选实现，绑定完成以及每一批配置热更新之后回调，可以用于校验配置、重建连接池等，
+绑定时返回异常则 BindProperties 返回异常，热更新时返回异常则通知 OnRebindFailure 注册的监听器
+*/
+type PropertiesInitializer interface {
+	AfterPropertiesSet() error
+}
+
+/**
+配置 Bean 热更新失败信息
+*/
+type RebindFailure struct {
+	KeyPrefix   string      // 绑定的配置前缀
+	Bean        interface{} // 配置 Bean 指针
+	ChangedKeys []string    // 本批次发生变更的配置 key
+	Err         error       // 失败原因
+}
+
+func (f *RebindFailure) Error() string {
+	return fmt.Sprintf("配置Bean[%T], keyPrefix:[%s] 热更新失败, changedKeys:%v, err:%v", f.Bean, f.KeyPrefix, f.ChangedKeys, f.Err)
+}
+
+/**
+热更新失败监听器注册表
+*/
+type rebindFailureListeners struct {
+	lock      sync.RWMutex
+	listeners []*rebindFailureListener
+}
+
+type rebindFailureListener struct {
+	handler func(failure *RebindFailure)
+}
+
+func (r *rebindFailureListeners) add(handler func(failure *RebindFailure)) Subscription {
+	listener := &rebindFailureListener{handler: handler}
+	r.lock.Lock()
+	r.listeners = append(r.listeners, listener)
+	r.lock.Unlock()
+	return NewSubscription(func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		listeners := make([]*rebindFailureListener, 0, len(r.listeners))
+		for _, item := range r.listeners {
+			if item != listener {
+				listeners = append(listeners, item)
+			}
+		}
+		r.listeners = listeners
+	})
+}
+
+func (r *rebindFailureListeners) publish(failure *RebindFailure) {
+	r.lock.RLock()
+	listeners := r.listeners
+	r.lock.RUnlock()
+	for _, listener := range listeners {
+		xcontext.Run(func() {
+			listener.handler(failure)
+		}, func(err interface{}, hadPanic bool) {
+			if hadPanic {
+				xlog.Warn("执行配置Bean热更新失败监听器发生panic：", err)
+			}
+		})
+	}
+}
+
+/**
+订阅配置 Bean 热更新失败，包括 AfterPropertiesSet 返回异常以及回调发生 panic
+*/
+func (s *StandardEnvironment) OnRebindFailure(handler func(failure *RebindFailure)) Subscription {
+	return s.rebindFailureListeners.add(handler)
+}
+
+func (s *StandardEnvironment) rebindCallbackDelay() time.Duration {
+	if s.options.rebindCallbackDelay > 0 {
+		return s.options.rebindCallbackDelay
+	}
+	return DefaultRebindCallbackDelay
+}
+
+/**
+绑定完成之后回调 AfterPropertiesSet
+*/
+func (s *StandardEnvironment) initializeBean(bean *boundBean) error {
+	initializer, ok := bean.beanPtr.(PropertiesInitializer)
+	if !ok {
+		return nil
+	}
+	if err := initializer.AfterPropertiesSet(); err != nil {
+		return fmt.Errorf("配置Bean[%T] AfterPropertiesSet 失败：%v", bean.beanPtr, err)
+	}
+	return nil
+}
+
+/**
+热更新配置 Bean 的属性值，然后记录发生变更的 key
+*/
+func (s *StandardEnvironment) applyBeanChange(bean *boundBean, key string, apply func()) {
+	if bean == nil {
+		apply()
+		return
+	}
+	bean.valueLock.Lock()
+	defer bean.valueLock.Unlock()
+	apply()
+	s.markBeanChanged(bean, key)
+}
+
+/**
+记录配置 Bean 发生变更的 key，等待 rebindCallbackDelay 之后合并回调
+*/
+func (s *StandardEnvironment) markBeanChanged(bean *boundBean, key string) {
+	if bean == nil {
+		return
+	}
+	if _, ok := bean.beanPtr.(PropertiesChangedListener); !ok {
+		if _, ok := bean.beanPtr.(PropertiesInitializer); !ok {
+			return
+		}
+	}
+
+	bean.lock.Lock()
+	defer bean.lock.Unlock()
+	if bean.unbound {
+		return
+	}
+	if bean.changedKeys == nil {
+		bean.changedKeys = make(map[string]bool)
+	}
+	bean.changedKeys[key] = true
+	if bean.timer == nil {
+		bean.timer = time.AfterFunc(s.rebindCallbackDelay(), func() {
+			s.flushBeanChanged(bean)
+		})
+	}
+}
+
+/**
+执行配置 Bean 的热更新回调
+*/
+func (s *StandardEnvironment) flushBeanChanged(bean *boundBean) {
+	bean.lock.Lock()
+	keys := make([]string, 0, len(bean.changedKeys))
+	for key := range bean.changedKeys {
+		keys = append(keys, key)
+	}
+	bean.changedKeys = nil
+	bean.timer = nil
+	unbound := bean.unbound
+	bean.lock.Unlock()
+
+	if unbound || len(keys) < 1 {
+		return
+	}
+	sort.Strings(keys)
+
+	var err error
+	bean.valueLock.Lock()
+	xcontext.Run(func() {
+		if listener, ok := bean.beanPtr.(PropertiesChangedListener); ok {
+			listener.OnPropertiesChanged(keys)
+		}
+		if initializer, ok := bean.beanPtr.(PropertiesInitializer); ok {
+			err = initializer.AfterPropertiesSet()
+		}
+	}, func(r interface{}, hadPanic bool) {
+		if hadPanic {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	})
+	bean.valueLock.Unlock()
+	if err == nil {
+		return
+	}
+
+	failure := &RebindFailure{KeyPrefix: bean.keyPrefix, Bean: bean.beanPtr, ChangedKeys: keys, Err: err}
+	xlog.Error(failure.Error())
+	s.rebindFailureListeners.publish(failure)
+}
+
+/**
+停止配置 Bean 未执行的热更新回调
+*/
+func (b *boundBean) stopCallbacks() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.unbound = true
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.changedKeys = nil
+}
@@ -0,0 +1,80 @@
+package xenv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type CallbackPoolConfig struct {
+	Host    string `ck:"host"`
+	MaxIdle int    `ck:"max-idle"`
+}
+
+// 绑定时会处理所有属性，所以回调通知放在 Bean 之外
+var callbackPoolConfigChanged = make(chan []string, 2)
+
+func (c *CallbackPoolConfig) OnPropertiesChanged(changedKeys []string) {
+	callbackPoolConfigChanged <- changedKeys
+}
+
+func (c *CallbackPoolConfig) AfterPropertiesSet() error {
+	if c.MaxIdle < 0 {
+		return errors.New("max-idle must not be negative")
+	}
+	return nil
+}
+
+func TestStandardEnvironment_RebindCallback(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"pool.host": "127.0.0.1", "pool.max-idle": "10"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(),
+		AdditionalPropertySources(sources), RebindCallbackDelay(100*time.Millisecond))
+
+	failures := make(chan *RebindFailure, 1)
+	env.OnRebindFailure(func(failure *RebindFailure) {
+		failures <- failure
+	})
+
+	cfg := &CallbackPoolConfig{}
+	_, err := env.BindProperties("pool.", cfg, true)
+	assert.Nil(t, err)
+
+	// 同一批变更只回调一次
+	source.PutAll(map[string]string{"pool.host": "10.0.0.1", "pool.max-idle": "20"})
+	select {
+	case keys := <-callbackPoolConfigChanged:
+		assert.Equal(t, []string{"pool.host", "pool.max-idle"}, keys)
+		assert.Equal(t, "10.0.0.1", cfg.Host)
+		assert.Equal(t, 20, cfg.MaxIdle)
+	case <-time.After(time.Second):
+		t.Fatal("未收到 OnPropertiesChanged 回调")
+	}
+
+	// AfterPropertiesSet 失败通知监听器
+	source.Put("pool.max-idle", "-1")
+	select {
+	case failure := <-failures:
+		assert.Equal(t, "pool.", failure.KeyPrefix)
+		assert.Equal(t, []string{"pool.max-idle"}, failure.ChangedKeys)
+		assert.NotNil(t, failure.Err)
+	case <-time.After(time.Second):
+		t.Fatal("未收到热更新失败通知")
+	}
+	<-callbackPoolConfigChanged
+
+	// 解除绑定之后不再回调
+	assert.True(t, env.UnbindProperties(cfg))
+	source.Put("pool.host", "10.0.0.2")
+	select {
+	case <-callbackPoolConfigChanged:
+		t.Fatal("解除绑定之后不应该回调")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// 绑定时 AfterPropertiesSet 失败返回异常
+	_, err = env.BindProperties("pool.", &CallbackPoolConfig{}, false)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,299 @@
+package xenv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xkgo/xkit/xlog"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 组件重建之后，旧组件延迟关闭的时间，给正在使用旧组件的请求留出时间
+	DefaultRefreshGracePeriod = 30 * time.Second
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+/**
+刷新作用域组件选项
+*/
+type RefreshScopeOption func(component *RefreshScopedComponent)
+
+/**
+旧组件延迟关闭的时间，小于等于 0 表示立即关闭
+*/
+func RefreshGracePeriod(gracePeriod time.Duration) RefreshScopeOption {
+	return func(component *RefreshScopedComponent) {
+		component.gracePeriod = gracePeriod
+	}
+}
+
+/**
+组件重建成功之后回调，参数为新旧组件，首次创建的时候旧组件为 nil
+*/
+func OnRefreshed(handler func(newComponent, oldComponent interface{})) RefreshScopeOption {
+	return func(component *RefreshScopedComponent) {
+		component.onRefreshed = handler
+	}
+}
+
+/**
+刷新作用域组件，根据配置 Bean 创建，前缀下的任意配置项发生变更的时候，重新绑定配置并重建组件，
+重建成功之后原子替换，旧组件如果实现了 io.Closer，延迟 gracePeriod 之后关闭；
+重建失败的话继续使用旧组件，并通知 OnRebindFailure 注册的监听器
+*/
+type RefreshScopedComponent struct {
+	env         *StandardEnvironment
+	keyPrefix   string
+	cfgType     reflect.Type  // 配置 Bean 类型，非指针
+	factory     reflect.Value // func(cfg *T) (C, error)
+	gracePeriod time.Duration
+	onRefreshed func(newComponent, oldComponent interface{})
+
+	current      atomic.Value // *refreshScopedInstance
+	refreshLock  sync.Mutex   // 串行重建
+	lock         sync.Mutex   // 保护 changedKeys、timer、closed
+	changedKeys  map[string]bool
+	timer        *time.Timer
+	closed       bool
+	subscription Subscription
+}
+
+type refreshScopedInstance struct {
+	component interface{}
+	cfg       interface{} // 创建组件时绑定的配置，未经过 factory 修改
+	version   int64
+}
+
+/**
+声明刷新作用域组件，会立即绑定配置并创建组件，创建失败则返回异常
+@param keyPrefix 配置前缀，和 BindProperties 的 keyPrefix 一致
+@param factory 组件工厂，必须是 func(cfg *T) (C, error) 形式，T 为配置 Bean 类型，C 为任意组件类型
+*/
+func (s *StandardEnvironment) RefreshScoped(keyPrefix string, factory interface{}, options ...RefreshScopeOption) (*RefreshScopedComponent, error) {
+	fv := reflect.ValueOf(factory)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 1 || ft.NumOut() != 2 || !ft.Out(1).Implements(errorType) ||
+		ft.In(0).Kind() != reflect.Ptr || ft.In(0).Elem().Kind() != reflect.Struct {
+		return nil, errors.New("RefreshScoped 组件工厂必须是 func(cfg *T) (C, error) 形式，当前类型为：" + ft.String())
+	}
+
+	component := &RefreshScopedComponent{
+		env:         s,
+		keyPrefix:   keyPrefix,
+		cfgType:     ft.In(0).Elem(),
+		factory:     fv,
+		gracePeriod: DefaultRefreshGracePeriod,
+	}
+	for _, option := range options {
+		option(component)
+	}
+
+	if err := component.Refresh(); err != nil {
+		return nil, err
+	}
+
+	component.subscription = s.Subscribe("^"+strings.Replace(keyPrefix, ".", "\\.", -1)+".*", func(event *KeyChangeEvent) {
+		component.markChanged(event.Key)
+	})
+
+	s.refreshScopesLock.Lock()
+	s.refreshScopes = append(s.refreshScopes, component)
+	s.refreshScopesLock.Unlock()
+	return component, nil
+}
+
+/**
+当前组件，需要自己转换成工厂返回的类型；每次使用的时候都应该调用 Get，不要长期持有返回值
+*/
+func (c *RefreshScopedComponent) Get() interface{} {
+	if instance, ok := c.current.Load().(*refreshScopedInstance); ok {
+		return instance.component
+	}
+	return nil
+}
+
+/**
+创建当前组件使用的配置 Bean 指针
+*/
+func (c *RefreshScopedComponent) Config() interface{} {
+	if instance, ok := c.current.Load().(*refreshScopedInstance); ok {
+		return instance.cfg
+	}
+	return nil
+}
+
+/**
+当前组件的版本，每重建一次加 1，首次创建为 1
+*/
+func (c *RefreshScopedComponent) Version() int64 {
+	if instance, ok := c.current.Load().(*refreshScopedInstance); ok {
+		return instance.version
+	}
+	return 0
+}
+
+/**
+立即重新绑定配置，配置发生了变化的话重建组件
+*/
+func (c *RefreshScopedComponent) Refresh() error {
+	c.refreshLock.Lock()
+	defer c.refreshLock.Unlock()
+
+	cfg := reflect.New(c.cfgType)
+	if _, err := c.env.doBindProperties(c.keyPrefix, cfg.Interface(), false, nil); filterBindErrors(err, c.env.options.strictBinding) != nil {
+		return err
+	}
+
+	old, _ := c.current.Load().(*refreshScopedInstance)
+	if old != nil && reflect.DeepEqual(old.cfg, cfg.Interface()) {
+		return nil
+	}
+
+	// factory 可能会修改配置，所以保存一份绑定的配置用于比较
+	bound := reflect.New(c.cfgType)
+	bound.Elem().Set(cfg.Elem())
+
+	newComponent, err := c.build(cfg)
+	if err != nil {
+		return err
+	}
+
+	instance := &refreshScopedInstance{component: newComponent, cfg: bound.Interface(), version: 1}
+	var oldComponent interface{}
+	if old != nil {
+		instance.version = old.version + 1
+		oldComponent = old.component
+	}
+	c.current.Store(instance)
+
+	if c.onRefreshed != nil {
+		c.onRefreshed(newComponent, oldComponent)
+	}
+	if old != nil && !sameComponent(oldComponent, newComponent) {
+		c.closeLater(oldComponent)
+	}
+	return nil
+}
+
+/**
+是否是同一个组件，工厂可能会直接返回旧组件，这种情况不能关闭
+*/
+func sameComponent(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func (c *RefreshScopedComponent) build(cfg reflect.Value) (component interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("刷新作用域组件[%s]创建发生panic：%v", c.keyPrefix, r)
+		}
+	}()
+	if initializer, ok := cfg.Interface().(PropertiesInitializer); ok {
+		if err = initializer.AfterPropertiesSet(); err != nil {
+			return nil, err
+		}
+	}
+	outs := c.factory.Call([]reflect.Value{cfg})
+	if !outs[1].IsNil() {
+		return nil, outs[1].Interface().(error)
+	}
+	return outs[0].Interface(), nil
+}
+
+/**
+延迟关闭旧组件
+*/
+func (c *RefreshScopedComponent) closeLater(component interface{}) {
+	closer, ok := component.(io.Closer)
+	if !ok {
+		return
+	}
+	closeFunc := func() {
+		if err := closer.Close(); err != nil {
+			xlog.Warn("刷新作用域组件["+c.keyPrefix+"]关闭旧组件异常：", err)
+		}
+	}
+	if c.gracePeriod <= 0 {
+		closeFunc()
+		return
+	}
+	time.AfterFunc(c.gracePeriod, closeFunc)
+}
+
+/**
+记录变更的 key，合并同一批变更之后重建
+*/
+func (c *RefreshScopedComponent) markChanged(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return
+	}
+	if c.changedKeys == nil {
+		c.changedKeys = make(map[string]bool)
+	}
+	c.changedKeys[key] = true
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.env.rebindCallbackDelay(), c.refreshChanged)
+	}
+}
+
+func (c *RefreshScopedComponent) refreshChanged() {
+	c.lock.Lock()
+	keys := make([]string, 0, len(c.changedKeys))
+	for key := range c.changedKeys {
+		keys = append(keys, key)
+	}
+	c.changedKeys = nil
+	c.timer = nil
+	closed := c.closed
+	c.lock.Unlock()
+	if closed {
+		return
+	}
+	sort.Strings(keys)
+
+	if err := c.Refresh(); err != nil {
+		failure := &RebindFailure{KeyPrefix: c.keyPrefix, Bean: c.Config(), ChangedKeys: keys, Err: err}
+		xlog.Error(failure.Error())
+		c.env.rebindFailureListeners.publish(failure)
+	}
+}
+
+/**
+取消配置变更监听，并立即关闭当前组件
+*/
+func (c *RefreshScopedComponent) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.lock.Unlock()
+
+	if c.subscription != nil {
+		c.subscription.Unsubscribe()
+	}
+	if closer, ok := c.Get().(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
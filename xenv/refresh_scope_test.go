@@ -0,0 +1,88 @@
+package xenv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type RefreshClientConfig struct {
+	Addr    string `ck:"addr"`
+	Timeout int    `ck:"timeout" def:"3"`
+}
+
+type refreshClient struct {
+	addr   string
+	closed int32
+}
+
+func (c *refreshClient) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *refreshClient) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func TestStandardEnvironment_RefreshScoped(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"client.addr": "127.0.0.1:80", "other": "x"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(),
+		AdditionalPropertySources(sources), RebindCallbackDelay(20*time.Millisecond))
+
+	_, err := env.RefreshScoped("client.", func(cfg RefreshClientConfig) (*refreshClient, error) {
+		return nil, nil
+	})
+	assert.NotNil(t, err)
+
+	refreshed := make(chan interface{}, 4)
+	component, err := env.RefreshScoped("client.", func(cfg *RefreshClientConfig) (*refreshClient, error) {
+		if len(cfg.Addr) < 1 {
+			return nil, errors.New("addr is required")
+		}
+		return &refreshClient{addr: cfg.Addr}, nil
+	}, RefreshGracePeriod(0), OnRefreshed(func(newComponent, oldComponent interface{}) {
+		refreshed <- newComponent
+	}))
+	assert.Nil(t, err)
+	<-refreshed
+
+	first := component.Get().(*refreshClient)
+	assert.Equal(t, "127.0.0.1:80", first.addr)
+	assert.Equal(t, 3, component.Config().(*RefreshClientConfig).Timeout)
+	assert.Equal(t, int64(1), component.Version())
+
+	// 前缀下的配置变更，重建组件并关闭旧组件
+	source.Put("client.addr", "127.0.0.1:81")
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("组件没有重建")
+	}
+	assert.Equal(t, "127.0.0.1:81", component.Get().(*refreshClient).addr)
+	assert.Equal(t, int64(2), component.Version())
+	assert.True(t, first.isClosed())
+
+	// 重建失败继续使用旧组件
+	failures := make(chan *RebindFailure, 1)
+	env.OnRebindFailure(func(failure *RebindFailure) {
+		failures <- failure
+	})
+	source.Remove("client.addr")
+	select {
+	case failure := <-failures:
+		assert.Equal(t, []string{"client.addr"}, failure.ChangedKeys)
+	case <-time.After(time.Second):
+		t.Fatal("没有收到重建失败通知")
+	}
+	assert.Equal(t, "127.0.0.1:81", component.Get().(*refreshClient).addr)
+
+	current := component.Get().(*refreshClient)
+	assert.Nil(t, env.Close(context.Background()))
+	assert.True(t, current.isClosed())
+}
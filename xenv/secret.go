@@ -0,0 +1,313 @@
+package xenv
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// 敏感配置脱敏之后显示的值
+	SecretMask = "******"
+)
+
+/**
+默认的敏感配置 key 模式，* 匹配任意字符，不区分大小写
+*/
+var DefaultSecretKeyPatterns = []string{"password", "*.password", "secret", "*.secret", "*token*"}
+
+/**
+敏感配置 key 模式注册表，日志、配置导出等场景下匹配的配置值会被脱敏
+*/
+var secretKeys = &secretKeyRegistry{}
+
+func init() {
+	AddSecretKeyPatterns(DefaultSecretKeyPatterns...)
+}
+
+type secretKeyRegistry struct {
+	lock     sync.RWMutex
+	patterns []string
+	regexes  []*regexp.Regexp
+}
+
+func (r *secretKeyRegistry) add(patterns ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) < 1 || r.contains(pattern) {
+			continue
+		}
+		r.patterns = append(r.patterns, pattern)
+		r.regexes = append(r.regexes, secretKeyRegex(pattern))
+	}
+}
+
+func (r *secretKeyRegistry) contains(pattern string) bool {
+	for _, p := range r.patterns {
+		if p == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *secretKeyRegistry) matches(key string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, regex := range r.regexes {
+		if regex.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+将 * 通配的模式转换为正则，不区分大小写
+*/
+func secretKeyRegex(pattern string) *regexp.Regexp {
+	expr := strings.Replace(regexp.QuoteMeta(pattern), "\\*", ".*", -1)
+	return regexp.MustCompile("(?i)^" + expr + "$")
+}
+
+/**
+添加敏感配置 key 模式，* 匹配任意字符，不区分大小写，例如：*.password、*token*、db.url
+*/
+func AddSecretKeyPatterns(patterns ...string) {
+	secretKeys.add(patterns...)
+}
+
+/**
+重新设置敏感配置 key 模式，会清空默认的模式，通过 secret tag 注册的 key 属于各自的环境，不受影响
+*/
+func SetSecretKeyPatterns(patterns ...string) {
+	secretKeys.lock.Lock()
+	secretKeys.patterns = nil
+	secretKeys.regexes = nil
+	secretKeys.lock.Unlock()
+	AddSecretKeyPatterns(patterns...)
+}
+
+/**
+当前所有的敏感配置 key 模式
+*/
+func SecretKeyPatterns() []string {
+	secretKeys.lock.RLock()
+	defer secretKeys.lock.RUnlock()
+	return append([]string{}, secretKeys.patterns...)
+}
+
+/**
+是否是敏感配置 key
+*/
+func IsSecretKey(key string) bool {
+	return secretKeys.matches(key)
+}
+
+/**
+对配置值进行脱敏，如果 key 不是敏感配置则原样返回，空值不脱敏
+*/
+func MaskPropertyValue(key, value string) string {
+	if len(value) < 1 || !IsSecretKey(key) {
+		return value
+	}
+	return SecretMask
+}
+
+/**
+是否是敏感配置 key，包括全局的敏感配置 key 模式以及当前环境中通过 secret tag 绑定的配置 key
+*/
+func (s *StandardEnvironment) IsSecretKey(key string) bool {
+	return IsSecretKey(key) || s.secretKeys.matches(key)
+}
+
+/**
+对配置值进行脱敏，参考 IsSecretKey
+*/
+func (s *StandardEnvironment) MaskPropertyValue(key, value string) string {
+	if len(value) < 1 || !s.IsSecretKey(key) {
+		return value
+	}
+	return SecretMask
+}
+
+/**
+输出变更事件，新旧值按照当前环境的敏感配置脱敏，日志中不要直接输出 KeyChangeEvent
+*/
+func (s *StandardEnvironment) describeEvent(event *KeyChangeEvent) string {
+	return fmt.Sprintf("[%v][%s], old:[%s], new:[%s]", event.ChangeType, event.Key,
+		s.MaskPropertyValue(event.Key, event.Ov), s.MaskPropertyValue(event.Key, event.Nv))
+}
+
+/**
+处理占位符之后再脱敏：除了 key 本身是敏感配置，占位符（包括嵌套的）引用了敏感配置的话也要脱敏，
+比如 db.url=mysql://u:${db.password}@h；占位符处理失败的话使用原始值
+*/
+func (s *StandardEnvironment) MaskResolvedPropertyValue(key, value string) string {
+	s.InitPropertyResolver()
+	resolved, references, err := s.propertyResolver.resolvePlaceholdersWithReferences(value)
+	if err != nil {
+		return s.MaskPropertyValue(key, value)
+	}
+	for _, reference := range references {
+		if s.IsSecretKey(reference) && len(resolved) > 0 {
+			return SecretMask
+		}
+	}
+	return s.MaskPropertyValue(key, resolved)
+}
+
+/**
+对配置项进行脱敏，返回新的 map
+*/
+func MaskProperties(properties map[string]string) map[string]string {
+	masked := make(map[string]string, len(properties))
+	for key, value := range properties {
+		masked[key] = MaskPropertyValue(key, value)
+	}
+	return masked
+}
+
+/**
+返回配置 Bean 脱敏之后的副本，用于日志输出，不会修改原来的 Bean。
+属性有 secret:"true" tag 或者配置 key 是敏感配置的话会被脱敏，配置 key 的规则和 BindProperties 一致
+@param keyPrefix 配置前缀，和 BindProperties 的 keyPrefix 一致
+@param bean 配置 Bean 指针或者配置 Bean
+*/
+func MaskSecretBean(keyPrefix string, bean interface{}) interface{} {
+	v := reflect.ValueOf(bean)
+	if !v.IsValid() {
+		return bean
+	}
+	return maskBeanValue(keyPrefix, v, map[reflect.Type]bool{}).Interface()
+}
+
+/**
+属性是否是敏感配置
+*/
+func isSecretField(tfield reflect.StructField, configKey string) bool {
+	return "true" == tfield.Tag.Get("secret") || IsSecretKey(configKey)
+}
+
+func maskBeanValue(keyPrefix string, v reflect.Value, visiting map[reflect.Type]bool) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v
+		}
+		copied := reflect.New(v.Elem().Type())
+		copied.Elem().Set(maskBeanValue(keyPrefix, v.Elem(), visiting))
+		return copied
+	case reflect.Struct:
+		t := v.Type()
+		if visiting[t] {
+			return v
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		copied := reflect.New(t).Elem()
+		copied.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			tfield := t.Field(i)
+			fv := copied.Field(i)
+			if !fv.CanSet() {
+				continue
+			}
+			configKey := keyPrefix + configFieldSubKey(tfield)
+			if isSecretField(tfield, configKey) {
+				fv.Set(maskFieldValue(fv))
+				continue
+			}
+			if "true" == tfield.Tag.Get("expand") {
+				fv.Set(maskExpandValue(configKey, fv, visiting))
+			}
+		}
+		return copied
+	}
+	return v
+}
+
+/**
+脱敏展开的 struct、map 属性
+*/
+func maskExpandValue(configKey string, v reflect.Value, visiting map[reflect.Type]bool) reflect.Value {
+	mv := v
+	if mv.Kind() == reflect.Ptr {
+		if mv.IsNil() {
+			return v
+		}
+		mv = mv.Elem()
+	}
+	switch mv.Kind() {
+	case reflect.Struct:
+		return maskBeanValue(configKey+".", v, visiting)
+	case reflect.Map:
+		if mv.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(mv.Type(), mv.Len())
+		iter := mv.MapRange()
+		for iter.Next() {
+			itemKey := configKey + "." + fmt.Sprint(iter.Key().Interface())
+			item := iter.Value()
+			if item.Kind() == reflect.String && IsSecretKey(itemKey) {
+				item = maskFieldValue(item)
+			} else {
+				item = maskBeanValue(itemKey+".", item, visiting)
+			}
+			copied.SetMapIndex(iter.Key(), item)
+		}
+		if v.Kind() == reflect.Ptr {
+			ptr := reflect.New(mv.Type())
+			ptr.Elem().Set(copied)
+			return ptr
+		}
+		return copied
+	}
+	return v
+}
+
+/**
+脱敏单个属性值，字符串类型的替换为 SecretMask，其他无法表示的类型置为零值
+*/
+func maskFieldValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() < 1 {
+			return v
+		}
+		return reflect.ValueOf(SecretMask).Convert(v.Type())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().Set(maskFieldValue(v.Elem()))
+		return ptr
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(maskFieldValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), maskFieldValue(iter.Value()))
+		}
+		return copied
+	}
+	return reflect.Zero(v.Type())
+}
@@ -0,0 +1,143 @@
+package xenv
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkgo/xkit/xlog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type SecretDbConfig struct {
+	Url      string `ck:"url"`
+	Username string `ck:"username"`
+	Password string `ck:"password"`
+}
+
+type SecretConfig struct {
+	Name     string            `ck:"name"`
+	ApiKey   string            `ck:"api-key" secret:"true"`
+	Tokens   []string          `ck:"tokens"`
+	Db       *SecretDbConfig   `ck:"db" expand:"true"`
+	Accounts map[string]string `ck:"accounts" expand:"true"`
+}
+
+func TestIsSecretKey(t *testing.T) {
+	assert.True(t, IsSecretKey("db.password"))
+	assert.True(t, IsSecretKey("app.Secret"))
+	assert.True(t, IsSecretKey("oauth.accessToken.value"))
+	assert.True(t, IsSecretKey("password"))
+	assert.True(t, IsSecretKey("SECRET"))
+	assert.False(t, IsSecretKey("passwords"))
+	assert.False(t, IsSecretKey("db.url"))
+
+	assert.Equal(t, SecretMask, MaskPropertyValue("db.password", "123456"))
+	assert.Equal(t, "", MaskPropertyValue("db.password", ""))
+	assert.Equal(t, "jdbc", MaskPropertyValue("db.url", "jdbc"))
+
+	event := &KeyChangeEvent{Key: "db.password", Ov: "111111", Nv: "222222", ChangeType: PropertyUpdate}
+	assert.Equal(t, "[UPDATE][db.password], old:[******], new:[******]", event.String())
+}
+
+func TestMaskSecretBean(t *testing.T) {
+	cfg := &SecretConfig{
+		Name:     "app",
+		ApiKey:   "key",
+		Tokens:   []string{"t1", "t2"},
+		Db:       &SecretDbConfig{Url: "jdbc", Username: "root", Password: "123456"},
+		Accounts: map[string]string{"password": "p", "user": "u"},
+	}
+
+	data, err := json.Marshal(MaskSecretBean("app.", cfg))
+	assert.Nil(t, err)
+	text := string(data)
+	assert.Contains(t, text, `"ApiKey":"******"`)
+	assert.Contains(t, text, `"Tokens":["******","******"]`)
+	assert.Contains(t, text, `"Password":"******"`)
+	assert.Contains(t, text, `"Url":"jdbc"`)
+	assert.Contains(t, text, `"password":"******"`)
+	assert.Contains(t, text, `"user":"u"`)
+
+	// 原来的 Bean 不会被修改
+	assert.Equal(t, "key", cfg.ApiKey)
+	assert.Equal(t, "123456", cfg.Db.Password)
+	assert.Equal(t, "p", cfg.Accounts["password"])
+}
+
+func TestStandardEnvironment_MaskedProperties(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", map[string]string{
+		"app.name":    "app",
+		"app.api-key": "key",
+		"db.password": "123456",
+	}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &SecretConfig{}
+	_, err := env.BindProperties("app.", cfg, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "key", cfg.ApiKey)
+
+	properties := env.Properties()
+	assert.Equal(t, "app", properties["app.name"])
+	assert.Equal(t, SecretMask, properties["app.api-key"])
+	assert.Equal(t, SecretMask, properties["db.password"])
+	assert.Equal(t, "123456", env.RawProperties()["db.password"])
+
+	// secret tag 绑定的 key 只对当前环境生效，不影响全局以及其他环境
+	assert.True(t, env.IsSecretKey("app.api-key"))
+	assert.False(t, IsSecretKey("app.api-key"))
+	other := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+	assert.Equal(t, "key", other.Properties()["app.api-key"])
+}
+
+func TestStandardEnvironment_MaskSecretTagInLogs(t *testing.T) {
+	var lock sync.Mutex
+	var logs []string
+	xlog.SetAfterLogHandler(func(ctx *context.Context, traceId string, logText string, level xlog.Level) {
+		lock.Lock()
+		defer lock.Unlock()
+		logs = append(logs, logText)
+	})
+	defer xlog.SetAfterLogHandler(func(ctx *context.Context, traceId string, logText string, level xlog.Level) {})
+
+	source := NewMapPropertySource("test", map[string]string{"app.api-key": "key-v1"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &SecretConfig{}
+	_, err := env.BindProperties("app.", cfg, true)
+	assert.Nil(t, err)
+	assert.False(t, IsSecretKey("app.api-key"))
+
+	// 配置来源中的变更以及配置来源新增导致的生效值变更都会输出日志
+	source.Put("app.api-key", "key-v2")
+	awaitApiKey(t, cfg, "key-v2")
+	env.GetPropertySources().AddFirst(NewMapPropertySource("high", map[string]string{"app.api-key": "key-v3"}))
+	awaitApiKey(t, cfg, "key-v3")
+
+	lock.Lock()
+	defer lock.Unlock()
+	found := false
+	for _, text := range logs {
+		for _, value := range []string{"key-v1", "key-v2", "key-v3"} {
+			assert.False(t, strings.Contains(text, value), text)
+		}
+		found = found || strings.Contains(text, "app.api-key")
+	}
+	assert.True(t, found)
+}
+
+func awaitApiKey(t *testing.T, cfg *SecretConfig, expected string) {
+	deadline := time.Now().Add(3 * time.Second)
+	for cfg.ApiKey != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待配置更新超时，期望：%s，实际：%s", expected, cfg.ApiKey)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,235 @@
+package xenv
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/xkgo/xkit/xcodec"
+	"github.com/xkgo/xkit/xlog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+配置快照，远程配置读取成功之后保存到本地，用于远程配置不可用时兜底
+*/
+type PropertySnapshot struct {
+	Name        string            `json:"name"`        // 配置来源名称
+	Timestamp   time.Time         `json:"timestamp"`   // 快照时间，即最后一次读取到的远程配置发生变化的时间
+	LastSuccess time.Time         `json:"lastSuccess"` // 最后一次成功读取远程配置的时间，配置没有变化也会更新，参考 SnapshotPropertyReader
+	Checksum    string            `json:"checksum"`    // 配置内容校验和，参考 PropertiesChecksum
+	Properties  map[string]string `json:"properties"`  // 配置项
+}
+
+/**
+距离最后一次成功读取远程配置多久，可用于判断配置是否过旧进行告警；配置长时间没有变化不会被认为是过旧。
+旧版本的快照没有 LastSuccess，使用 Timestamp
+*/
+func (s *PropertySnapshot) Age() time.Duration {
+	if s.LastSuccess.IsZero() {
+		return time.Since(s.Timestamp)
+	}
+	return time.Since(s.LastSuccess)
+}
+
+/**
+计算配置内容的校验和，按照 key 排序之后计算，和 map 遍历顺序无关
+*/
+func PropertiesChecksum(kvs map[string]string) string {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	builder := strings.Builder{}
+	for _, key := range keys {
+		builder.WriteString(key)
+		builder.WriteString("=")
+		builder.WriteString(kvs[key])
+		builder.WriteString("\n")
+	}
+	return xcodec.MD5(builder.String())
+}
+
+/**
+原子写入快照文件，先写入同目录下的临时文件，然后重命名
+*/
+func WritePropertySnapshot(file string, snapshot *PropertySnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	err = os.Rename(tmpName, file)
+	return err
+}
+
+/**
+读取快照文件，校验和不一致的话返回异常
+*/
+func ReadPropertySnapshot(file string) (*PropertySnapshot, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &PropertySnapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Properties == nil {
+		snapshot.Properties = make(map[string]string)
+	}
+	if snapshot.Checksum != PropertiesChecksum(snapshot.Properties) {
+		return nil, errors.New("配置快照[" + file + "]校验和不一致，文件可能已经损坏")
+	}
+	return snapshot, nil
+}
+
+/**
+配置内容没有变化的时候，最多间隔这么久重新写入一次快照文件，只更新 LastSuccess
+*/
+const snapshotLastSuccessInterval = time.Minute
+
+/**
+带本地快照的配置读取，实现 PropertyReader：
+	> 成功读取并且配置内容和上一次写入的快照不同的时候，将结果原子写入到本地快照文件
+	> 配置内容没有变化的话，最多每分钟重新写入一次，更新快照中的 LastSuccess，避免正常但是长时间没有变化的配置被认为是过旧的
+	> 首次读取失败的时候，加载本地快照作为配置，之后的读取失败直接返回异常，由上层保留最后一次成功的配置
+*/
+type SnapshotPropertyReader struct {
+	name         string         // 配置来源名称
+	reader       PropertyReader // 实际的配置读取
+	snapshotFile string         // 快照文件路径
+	lock         sync.Mutex
+	firstRead    bool              // 是否已经读取过
+	snapshot     *PropertySnapshot // 当前正在使用的快照，为 nil 表示使用的是实时读取的配置
+	lastChecksum string            // 最后一次写入快照文件的配置校验和，没有变化的话不重复写入
+	changedAt    time.Time         // 最后一次读取到的配置发生变化的时间
+	lastWritten  time.Time         // 最后一次写入快照文件的时间
+	lastSuccess  time.Time         // 最后一次成功读取的时间
+}
+
+/**
+创建带本地快照的配置读取
+@param name 配置来源名称，会记录到快照中
+@param reader 实际的配置读取
+@param snapshotFile 快照文件路径
+*/
+func NewSnapshotPropertyReader(name string, reader PropertyReader, snapshotFile string) *SnapshotPropertyReader {
+	if reader == nil {
+		panic(errors.New("PropertyReader is required"))
+	}
+	return &SnapshotPropertyReader{
+		name:         name,
+		reader:       reader,
+		snapshotFile: snapshotFile,
+	}
+}
+
+func (r *SnapshotPropertyReader) ReadAll() (kvs map[string]string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	firstRead := !r.firstRead
+	r.firstRead = true
+
+	kvs, err = r.reader.ReadAll()
+	if err == nil {
+		if kvs == nil {
+			kvs = make(map[string]string)
+		}
+		r.snapshot = nil
+		now := time.Now()
+		r.lastSuccess = now
+		checksum := PropertiesChecksum(kvs)
+		if checksum == r.lastChecksum {
+			if now.Sub(r.lastWritten) < snapshotLastSuccessInterval {
+				return kvs, nil
+			}
+		} else {
+			r.changedAt = now
+		}
+		snapshot := &PropertySnapshot{
+			Name:        r.name,
+			Timestamp:   r.changedAt,
+			LastSuccess: now,
+			Checksum:    checksum,
+			Properties:  kvs,
+		}
+		if werr := WritePropertySnapshot(r.snapshotFile, snapshot); werr != nil {
+			xlog.Warn("配置来源["+r.name+"]写入配置快照["+r.snapshotFile+"]失败：", werr)
+		} else {
+			r.lastChecksum = checksum
+			r.lastWritten = now
+		}
+		return kvs, nil
+	}
+
+	if !firstRead {
+		return nil, err
+	}
+
+	snapshot, serr := ReadPropertySnapshot(r.snapshotFile)
+	if serr != nil {
+		xlog.Error("配置来源["+r.name+"]首次读取失败，并且无法加载配置快照["+r.snapshotFile+"]：", serr)
+		return nil, err
+	}
+	xlog.Warn("配置来源["+r.name+"]首次读取失败，使用配置快照[", r.snapshotFile, "]，快照时间：", snapshot.Timestamp, "，最后一次成功读取时间：", snapshot.LastSuccess, ", error:", err)
+	r.snapshot = snapshot
+	return snapshot.Properties, nil
+}
+
+/**
+当前正在使用的快照，为 nil 表示当前使用的是实时读取的配置
+*/
+func (r *SnapshotPropertyReader) Snapshot() *PropertySnapshot {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.snapshot
+}
+
+/**
+最后一次成功读取的时间，还没有成功读取过的话为零值
+*/
+func (r *SnapshotPropertyReader) LastSuccess() time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.lastSuccess
+}
+
+/**
+当前是否正在使用本地快照
+*/
+func (r *SnapshotPropertyReader) UsingSnapshot() bool {
+	return r.Snapshot() != nil
+}
@@ -0,0 +1,127 @@
+package xenv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotPropertyReader_ReadAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenv-snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "remote.json")
+
+	fail := false
+	remote := NewPropertyReader(func() (kvs map[string]string, err error) {
+		if fail {
+			return nil, errors.New("remote unavailable")
+		}
+		return map[string]string{"db.url": "mysql://remote"}, nil
+	})
+
+	// 成功读取之后写入快照
+	kvs, err := NewSnapshotPropertyReader("remote", remote, file).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql://remote", kvs["db.url"])
+
+	snapshot, err := ReadPropertySnapshot(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "remote", snapshot.Name)
+	assert.Equal(t, PropertiesChecksum(kvs), snapshot.Checksum)
+
+	// 重新启动，首次读取失败，使用快照
+	fail = true
+	reader := NewSnapshotPropertyReader("remote", remote, file)
+	kvs, err = reader.ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql://remote", kvs["db.url"])
+	assert.True(t, reader.UsingSnapshot())
+
+	// 之后的失败直接返回异常
+	_, err = reader.ReadAll()
+	assert.NotNil(t, err)
+
+	// 恢复之后不再使用快照
+	fail = false
+	_, err = reader.ReadAll()
+	assert.Nil(t, err)
+	assert.False(t, reader.UsingSnapshot())
+}
+
+func TestSnapshotPropertyReader_WriteOnlyOnChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenv-snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "remote.json")
+
+	url := "mysql://remote"
+	reader := NewSnapshotPropertyReader("remote", NewPropertyReader(func() (kvs map[string]string, err error) {
+		return map[string]string{"db.url": url}, nil
+	}), file)
+	_, err = reader.ReadAll()
+	assert.Nil(t, err)
+	assert.FileExists(t, file)
+
+	// 配置没有变化，不重复写入
+	assert.Nil(t, os.Remove(file))
+	_, err = reader.ReadAll()
+	assert.Nil(t, err)
+	assert.NoFileExists(t, file)
+
+	url = "mysql://remote2"
+	_, err = reader.ReadAll()
+	assert.Nil(t, err)
+	snapshot, err := ReadPropertySnapshot(file)
+	assert.Nil(t, err)
+	assert.Equal(t, url, snapshot.Properties["db.url"])
+}
+
+func TestSnapshotPropertyReader_LastSuccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenv-snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "remote.json")
+
+	reader := NewSnapshotPropertyReader("remote", NewPropertyReader(func() (kvs map[string]string, err error) {
+		return map[string]string{"db.url": "mysql://remote"}, nil
+	}), file)
+	_, err = reader.ReadAll()
+	assert.Nil(t, err)
+
+	// 配置一个小时没有变化，仍然在正常读取，快照不应该被认为是过旧的
+	hourAgo := time.Now().Add(-time.Hour)
+	reader.changedAt = hourAgo
+	reader.lastWritten = hourAgo
+	_, err = reader.ReadAll()
+	assert.Nil(t, err)
+	assert.True(t, time.Since(reader.LastSuccess()) < time.Minute)
+
+	snapshot, err := ReadPropertySnapshot(file)
+	assert.Nil(t, err)
+	assert.True(t, snapshot.Timestamp.Equal(hourAgo))
+	assert.True(t, snapshot.Age() < time.Minute)
+
+	// 旧版本的快照没有 LastSuccess，使用 Timestamp
+	snapshot.LastSuccess = time.Time{}
+	assert.True(t, snapshot.Age() >= time.Hour)
+}
+
+func TestReadPropertySnapshot_ChecksumMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenv-snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "remote.json")
+
+	assert.Nil(t, WritePropertySnapshot(file, &PropertySnapshot{
+		Name:       "remote",
+		Checksum:   "invalid",
+		Properties: map[string]string{"a": "b"},
+	}))
+	_, err = ReadPropertySnapshot(file)
+	assert.NotNil(t, err)
+}
@@ -1,17 +1,23 @@
 package xenv
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/xkgo/xkit/xfile"
 	"github.com/xkgo/xkit/xjson"
 	"github.com/xkgo/xkit/xlog"
+	"github.com/xkgo/xkit/xplaceholder"
 	"github.com/xkgo/xkit/xreflect"
 	"github.com/xkgo/xkit/xstr"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -59,7 +65,7 @@ type StandardEnvironment struct {
 	> 将命令行参数 作为优先级最高的 propertySource， --------- 之后每次向 propertySources 添加元素，都要重新进行日志配置，这样子才能每次应用最新配置
 	> 添加系统环境变量
 	> 自动解析当前运行环境相关属性： 环境(dev,test,fat,prod), set(分组：可能以全球大区、机房等来区分部署集群等等，将这个抽象即可)，将环境相关组成 propertySource ，然后添加进去 propertySources
-	> 读取默认配置文件 application.properties|yml|toml, 然后添加到 propertySources 的 命令行之后，从 propertySources 中读取 xenv-profile-include，作为 activeProfiles
+	> 读取默认配置文件 application.properties|yml|ini|hcl 以及 xfile.RegisterFormat 注册的格式, 然后添加到 propertySources 的 命令行之后，从 propertySources 中读取 xenv-profile-include，作为 activeProfiles
 	> 获取 profileDirs 下的所有配置文件，按照profile 分组，然后按照顺序依次加载配置文件，最后按顺序添加到 propertySources 的默认配置文件之后
 	> profileDirs 都加载完成后， 将 additionalPropertySources 添加到 propertySources 之后
 	> 添加系统环境变量到 propertySources 最后面
@@ -69,17 +75,85 @@ type StandardEnvironment struct {
 	/**
 	配置解析器，读取配置、处理占位符
 	*/
-	propertyResolver PropertyResolver
+	propertyResolver *PropertySourcesPropertyResolver
 
 	/**
 	配置key变更订阅列表
 	*/
-	propertyChangeListeners []*PropertyChangeListener
+	propertyChangeListeners PropertyChangeListenerRegistry
 
 	/**
 	Beans
 	*/
 	bindBeans map[reflect.Type]interface{}
+
+	/**
+	通过 BindProperties 绑定的配置 Bean，按照绑定顺序
+	*/
+	boundBeans []*boundBean
+
+	/**
+	已经订阅了配置变更的配置来源，配置来源名称 -> 订阅，配置来源不一定是可比较的类型，所以按照名称索引
+	*/
+	listenedSources map[string]*listenedSource
+
+	/**
+	当前生效的配置项原始值快照，配置来源列表变更的时候用于对比哪些配置项的生效值发生了变化
+	*/
+	effectiveProperties map[string]string
+	effectiveLock       sync.Mutex
+
+	/**
+	配置 Bean 热更新失败监听器
+	*/
+	rebindFailureListeners rebindFailureListeners
+
+	/**
+	刷新作用域组件，参考 RefreshScoped
+	*/
+	refreshScopes     []*RefreshScopedComponent
+	refreshScopesLock sync.Mutex
+
+	/**
+	日志刷新作用域组件，xlog. 前缀的配置绑定失败的时候为 nil，参考 subscribeAndOverrideXlogProperties
+	*/
+	xlogScope     *RefreshScopedComponent
+	xlogScopeLock sync.Mutex
+
+	/**
+	废弃的配置 key，参考 DeprecatedKey
+	*/
+	deprecatedKeys *deprecatedKeyRegistry
+
+	// 通过 secret tag 绑定的敏感配置 key，只对当前环境生效，参考 IsSecretKey
+	secretKeys *secretKeyRegistry
+
+	/**
+	配置变更审计记录，参考 AuditLog
+	*/
+	auditLog             *auditLog
+	runtimeOverridesLock sync.Mutex
+}
+
+/**
+绑定的配置 Bean
+*/
+type boundBean struct {
+	keyPrefix     string
+	beanPtr       interface{}
+	subscriptions Subscriptions // 绑定时注册的配置变更监听
+	lock          sync.Mutex
+	valueLock     sync.Mutex      // 热更新属性值和执行回调互斥，保证回调时看到的是完整的属性值
+	changedKeys   map[string]bool // 等待回调的变更 key
+	timer         *time.Timer     // 热更新回调定时器
+	unbound       bool            // 是否已经解除绑定
+	strict        bool            // 是否是严格绑定模式，参考 StrictBinding
+}
+
+func (b *boundBean) addSubscription(sub Subscription) {
+	if b != nil {
+		b.subscriptions = append(b.subscriptions, sub)
+	}
 }
 
 func (s *StandardEnvironment) IsDev() bool {
@@ -106,14 +180,71 @@ func (s *StandardEnvironment) GetSet() string {
 	return s.runInfo.Set
 }
 
+func (s *StandardEnvironment) GetWorkDir() string {
+	return s.runInfo.WorkDir
+}
+
+/**
+关闭环境，按照优先级顺序关闭所有实现了 Closeable 的配置来源，并取消环境上的所有订阅，
+某个配置来源关闭失败不影响其他配置来源的关闭，返回第一个关闭失败的异常
+*/
+func (s *StandardEnvironment) Close(ctx context.Context) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.GetPropertySources().Each(func(index int, source PropertySource) (stop bool) {
+		closeable, ok := source.(Closeable)
+		if !ok {
+			return false
+		}
+		if cerr := closeable.Close(ctx); cerr != nil {
+			xlog.Warn("关闭配置来源["+source.GetName()+"]异常：", cerr)
+			if err == nil {
+				err = cerr
+			}
+		}
+		return false
+	})
+	s.refreshScopesLock.Lock()
+	refreshScopes := s.refreshScopes
+	s.refreshScopes = nil
+	s.refreshScopesLock.Unlock()
+	for _, component := range refreshScopes {
+		if cerr := component.Close(); cerr != nil {
+			xlog.Warn("关闭刷新作用域组件["+component.keyPrefix+"]异常：", cerr)
+			if err == nil {
+				err = cerr
+			}
+		}
+	}
+
+	s.propertyChangeListeners.Clear()
+
+	s.effectiveLock.Lock()
+	for name, listened := range s.listenedSources {
+		listened.subscription.Unsubscribe()
+		delete(s.listenedSources, name)
+	}
+	s.effectiveLock.Unlock()
+
+	if s.auditLog != nil {
+		if cerr := s.auditLog.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
 /**
 新建环境
 */
 func New(options ...Option) *StandardEnvironment {
 	env := &StandardEnvironment{
-		options:                 &Options{},
-		propertyChangeListeners: make([]*PropertyChangeListener, 0),
-		bindBeans:               make(map[reflect.Type]interface{}),
+		options:         &Options{auditLogCapacity: DefaultAuditLogCapacity},
+		bindBeans:       make(map[reflect.Type]interface{}),
+		listenedSources: make(map[string]*listenedSource),
+		deprecatedKeys:  newDeprecatedKeyRegistry(),
+		secretKeys:      &secretKeyRegistry{},
 	}
 
 	// 设置选项
@@ -123,9 +254,9 @@ func New(options ...Option) *StandardEnvironment {
 		}
 	}
 
+	env.auditLog = newAuditLog(env.options.auditLogCapacity, env.options.auditLogFile)
+
 	env.propertySources = NewMutablePropertySources()
-	// 订阅并更新日志信息
-	env.subscribeAndOverrideXlogProperties()
 
 	// 订阅数据源变更，然后循环检查 xenv.profile.include, 然后导入数据源
 	env.subscribeAndAddIncludeProfiles()
@@ -133,16 +264,23 @@ func New(options ...Option) *StandardEnvironment {
 	// 添加运行时信息
 	addRunInfo(env)
 
-	// 计算 configDir
-	env.configDir = env.resolveConfigDir()
-	xlog.Infof("配置文件目录为：%v", env.configDir)
+	// 订阅并更新日志信息，依赖运行时信息判断是否是开发环境，所以要在 addRunInfo 之后
+	if !env.options.disableXlogInit {
+		env.subscribeAndOverrideXlogProperties()
+	}
 
-	// 追加默认配置 application.properties|yaml|yml
-	env.addDefaultApplicationPropertySource()
+	if !env.options.ignoreConfigFiles {
+		// 计算 configDir
+		env.configDir = env.resolveConfigDir()
+		xlog.Infof("配置文件目录为：%v", env.configDir)
+
+		// 追加默认配置 application.properties|yaml|yml|ini|hcl
+		env.addDefaultApplicationPropertySource()
+	}
 
 	// 将 additionalPropertySources 添加到 propertySources 之后
 	additionalPropertySources := env.options.additionalPropertySources
-	if nil != additionalPropertySources && len(additionalPropertySources.propertySourceList) > 0 {
+	if nil != additionalPropertySources && additionalPropertySources.Size() > 0 {
 		additionalPropertySources.Each(func(index int, source PropertySource) (stop bool) {
 			if !env.propertySources.Contains(source.GetName()) {
 				env.propertySources.AddLast(source)
@@ -154,24 +292,55 @@ func New(options ...Option) *StandardEnvironment {
 	// 刷新、初始化
 	env.refresh()
 
+	// 校验所有配置项的占位符
+	env.validateOnStartup()
+
 	return env
 }
 
+/**
+日志作为刷新作用域组件，xlog. 前缀的配置变更之后重新初始化日志；
+环境初始化过程中还没有配置变更事件，所以配置来源列表变更的时候也要主动刷新；
+配置绑定失败只记录日志，继续使用原来的日志配置，xlog. 前缀的配置修正之后再重新创建
+*/
 func (s *StandardEnvironment) subscribeAndOverrideXlogProperties() {
-	var logProp *xlog.Properties = nil
+	s.refreshXlog()
 	s.propertySources.Subscribe(func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource) {
-		prop := &xlog.Properties{}
-		_, _ = s.doBindProperties("xlog.", prop, false)
+		s.refreshXlog()
+	})
+	s.Subscribe("^xlog\\..*", func(event *KeyChangeEvent) {
+		s.xlogScopeLock.Lock()
+		created := s.xlogScope != nil
+		s.xlogScopeLock.Unlock()
+		// 组件创建成功之后由组件自己订阅配置变更
+		if !created {
+			s.refreshXlog()
+		}
+	})
+}
+
+func (s *StandardEnvironment) refreshXlog() {
+	s.xlogScopeLock.Lock()
+	defer s.xlogScopeLock.Unlock()
+	if s.xlogScope != nil {
+		if err := s.xlogScope.Refresh(); err != nil {
+			xlog.Error("重新初始化日志失败：", err)
+		}
+		return
+	}
+	component, err := s.RefreshScoped("xlog.", func(prop *xlog.Properties) (*xlog.Properties, error) {
 		if s.runInfo != nil && s.runInfo.IsDev() {
 			prop.ConsoleLog = true // 开发环境下强制开启 console log
 		}
-		if prop.Equals(logProp) {
-			return
-		}
-		logProp = prop
-		xlog.Info("property source changed, will reset xlog: ", xjson.ToJsonStringWithoutError(prop))
+		xlog.Info("property source changed, will reset xlog: ", xjson.ToJsonStringWithoutError(MaskSecretBean("xlog.", prop)))
 		xlog.InitLogger(prop)
+		return prop, nil
 	})
+	if err != nil {
+		xlog.Error("初始化日志失败，继续使用原来的日志配置：", err)
+		return
+	}
+	s.xlogScope = component
 }
 
 /**
@@ -187,7 +356,12 @@ func (s *StandardEnvironment) subscribeAndAddIncludeProfiles() {
 		if !ok || len(sInclude) < 1 {
 			return
 		}
-		sInclude = xstr.Trim(s.ResolvePlaceholders(sInclude))
+		sInclude, err := s.ResolvePlaceholdersE(sInclude)
+		if err != nil {
+			xlog.Error("处理配置来源["+source.GetName()+"]中的 xenv.profile.include 占位符失败，忽略：", err)
+			return
+		}
+		sInclude = xstr.Trim(sInclude)
 		profiles := xstr.SplitByRegex(sInclude, "[,，;；\\s]+")
 
 		size := len(profiles)
@@ -203,7 +377,7 @@ func (s *StandardEnvironment) subscribeAndAddIncludeProfiles() {
 				if fileInfo.IsDir() {
 					return false
 				}
-				if !strings.HasPrefix(fileInfo.Name(), "application-"+profile+".") {
+				if !strings.HasPrefix(fileInfo.Name(), "application-"+profile+".") || !xfile.IsSupportedFormatFile(fileInfo.Name()) {
 					return false
 				}
 
@@ -222,7 +396,11 @@ func (s *StandardEnvironment) subscribeAndAddIncludeProfiles() {
 
 				activeProfiles = append(activeProfiles, profile)
 				// 添加
-				self.AddFirst(NewMapPropertySource(sName, kvs))
+				profileSource := NewMapPropertySource(sName, kvs)
+				for k := range kvs {
+					profileSource.SetPropertyOrigin(k, configFile)
+				}
+				self.AddFirst(profileSource)
 				return true
 			}, 1)
 		}
@@ -240,33 +418,40 @@ func (s *StandardEnvironment) subscribeAndAddIncludeProfiles() {
 }
 
 /**
-追加默认配置 application.properties|yaml|yml，目前仅支持这两种模式
+追加默认配置 application.properties|yaml|yml|ini|hcl，其他格式可以通过 xfile.RegisterFormat 注册
 */
 func (s *StandardEnvironment) addDefaultApplicationPropertySource() {
 	r, _ := regexp.Compile("(?i)(app|application)\\.[^\\\\.]+$")
 
 	properties := make(map[string]string)
+	origins := make(map[string]string) // 配置项所在的文件
 	// 遍历配置目录下的文
 	xfile.ListDirFiles(s.configDir, func(pdir string, fileInfo os.FileInfo) bool {
 		if fileInfo.IsDir() {
 			return false
 		}
 		filename := strings.ToLower(fileInfo.Name())
-		if !r.MatchString(filename) {
+		if !r.MatchString(filename) || !xfile.IsSupportedFormatFile(filename) {
 			return false
 		}
 
-		kvs, err := xfile.ReadAsMap(pdir + "/" + fileInfo.Name())
+		configFile := pdir + "/" + fileInfo.Name()
+		kvs, err := xfile.ReadAsMap(configFile)
 		if nil == err && len(kvs) > 0 {
 			for k, v := range kvs {
 				properties[k] = v
+				origins[k] = configFile
 			}
 		}
 		return true
 	}, 1)
 
 	// 添加一个空的默认配置来源
-	s.propertySources.AddFirst(NewMapPropertySource(DefaultApplicationEnvironmentPropertySourceName, properties))
+	source := NewMapPropertySource(DefaultApplicationEnvironmentPropertySourceName, properties)
+	for k, origin := range origins {
+		source.SetPropertyOrigin(k, origin)
+	}
+	s.propertySources.AddFirst(source)
 }
 
 /**
@@ -283,9 +468,17 @@ func addRunInfo(env *StandardEnvironment) {
 	}
 
 	// 将命令行参数作为最高优先级的属性来源
-	env.propertySources.AddFirst(NewCommandLinePropertySource(env.options.appendCommandLine))
+	if !env.options.ignoreCommandLine {
+		env.propertySources.AddFirst(NewCommandLinePropertySource(env.options.appendCommandLine))
+	}
 	// 添加系统环境变量
-	env.propertySources.AddLast(NewSystemEnvironmentPropertySource())
+	if !env.options.ignoreSystemEnvironment {
+		if prefix := env.options.systemEnvironmentPrefix; len(prefix) > 0 {
+			env.propertySources.AddLast(NewMappedSystemEnvironmentPropertySource(prefix, env.options.systemEnvironmentKeyMapper))
+		} else {
+			env.propertySources.AddLast(NewSystemEnvironmentPropertySource())
+		}
+	}
 
 	if env.runInfo == nil {
 		// 添加部署信息到配置来源
@@ -301,7 +494,12 @@ func addRunInfo(env *StandardEnvironment) {
 	env.runInfo.Properties[RunInfoEnvKey] = string(env.runInfo.Env)
 	env.runInfo.Properties[RunInfoSetKey] = env.runInfo.Set
 	env.runInfo.Properties[RunInfoWorkDirKey] = env.runInfo.WorkDir
-	env.propertySources.AddAfter(CommandLineEnvironmentPropertySourceName, NewMapPropertySource(RunInfoEnvironmentPropertySourceName, env.runInfo.Properties))
+	runInfoSource := NewMapPropertySource(RunInfoEnvironmentPropertySourceName, env.runInfo.Properties)
+	if env.propertySources.Contains(CommandLineEnvironmentPropertySourceName) {
+		_ = env.propertySources.AddAfter(CommandLineEnvironmentPropertySourceName, runInfoSource)
+	} else {
+		env.propertySources.AddFirst(runInfoSource)
+	}
 }
 
 /**
@@ -328,6 +526,8 @@ func (s *StandardEnvironment) InitPropertyResolver() {
 		s.propertyResolver = &PropertySourcesPropertyResolver{
 			propertySources:                      s.propertySources,
 			ignoreUnresolvableNestedPlaceholders: s.ignoreUnresolvableNestedPlaceholders,
+			deprecatedKeys:                       s.deprecatedKeys,
+			secretKeys:                           s.secretKeys,
 		}
 	}
 }
@@ -347,6 +547,14 @@ func (s *StandardEnvironment) GetPropertyWithDef(key string, def string) string
 	return s.propertyResolver.GetPropertyWithDef(key, def)
 }
 
+/**
+和 GetProperty 一样，只是占位符存在循环引用或者无法识别的时候返回异常而不是 panic，参考 PropertySourcesPropertyResolver.GetPropertyE
+*/
+func (s *StandardEnvironment) GetPropertyE(key string) (value string, exists bool, err error) {
+	s.InitPropertyResolver()
+	return s.propertyResolver.GetPropertyE(key)
+}
+
 func (s *StandardEnvironment) GetRequiredProperty(key string) string {
 	s.InitPropertyResolver()
 	return s.propertyResolver.GetRequiredProperty(key)
@@ -357,11 +565,27 @@ func (s *StandardEnvironment) ResolvePlaceholders(text string) string {
 	return s.propertyResolver.ResolvePlaceholders(text)
 }
 
+/**
+和 ResolvePlaceholders 一样，只是占位符存在循环引用的时候返回异常而不是 panic
+*/
+func (s *StandardEnvironment) ResolvePlaceholdersE(text string) (string, error) {
+	s.InitPropertyResolver()
+	return s.propertyResolver.ResolvePlaceholdersE(text)
+}
+
 func (s *StandardEnvironment) ResolveRequiredPlaceholders(text string) string {
 	s.InitPropertyResolver()
 	return s.propertyResolver.ResolveRequiredPlaceholders(text)
 }
 
+/**
+和 ResolveRequiredPlaceholders 一样，只是占位符无法识别或者循环引用的时候返回异常而不是 panic
+*/
+func (s *StandardEnvironment) ResolveRequiredPlaceholdersE(text string) (string, error) {
+	s.InitPropertyResolver()
+	return s.propertyResolver.ResolveRequiredPlaceholdersE(text)
+}
+
 func (s *StandardEnvironment) GetActiveProfiles() []string {
 	return s.activeProfiles
 }
@@ -403,11 +627,8 @@ func (s *StandardEnvironment) Merge(parent Environment) {
 	}
 }
 
-func (s *StandardEnvironment) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) {
-	if s.propertyChangeListeners == nil {
-		s.propertyChangeListeners = make([]*PropertyChangeListener, 0)
-	}
-	s.propertyChangeListeners = append(s.propertyChangeListeners, NewPropertyChangeListener(keyPattern, handler))
+func (s *StandardEnvironment) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription {
+	return s.propertyChangeListeners.Add(keyPattern, handler)
 }
 
 func (s *StandardEnvironment) refresh() {
@@ -417,14 +638,169 @@ func (s *StandardEnvironment) refresh() {
 func (s *StandardEnvironment) initPropertySourceListen() {
 	// 执行所有配置来源的监听
 	s.propertySources.Each(func(index int, source PropertySource) (stop bool) {
-		source.Subscribe("*", func() func(event *KeyChangeEvent) {
-			return func(event *KeyChangeEvent) {
-				xlog.Info("收到配置来源["+source.GetName()+"]的配置变更事件：", event)
-				s.onKeyChangeEvent(source, event)
-			}
-		}())
+		s.listenPropertySource(source, false)
 		return false
 	})
+
+	s.effectiveLock.Lock()
+	s.effectiveProperties = s.RawProperties()
+	s.effectiveLock.Unlock()
+
+	// 之后配置来源列表的变更，需要对生效值发生变化的配置项发送变更事件
+	s.propertySources.Subscribe(func(self *MutablePropertySources, changeType PropertySourcesChangeType, source PropertySource) {
+		if changeType == PropertySourcesChangeType_Add || changeType == PropertySourcesChangeType_Update {
+			// 同名的配置来源可能已经被替换掉了，重新订阅
+			s.listenPropertySource(source, true)
+		}
+		s.unlistenRemovedPropertySources()
+		s.publishEffectivePropertyChanges(source, changeType)
+	})
+}
+
+/**
+已经订阅的配置来源
+*/
+type listenedSource struct {
+	subscription Subscription
+}
+
+/**
+订阅配置来源的配置变更，同名的配置来源只订阅一次
+@param resubscribe 已经订阅过同名配置来源的话，是否取消原来的订阅重新订阅
+*/
+func (s *StandardEnvironment) listenPropertySource(source PropertySource, resubscribe bool) {
+	s.effectiveLock.Lock()
+	defer s.effectiveLock.Unlock()
+	name := source.GetName()
+	if old, listened := s.listenedSources[name]; listened {
+		if !resubscribe {
+			return
+		}
+		old.subscription.Unsubscribe()
+	}
+
+	listened := &listenedSource{}
+	s.listenedSources[name] = listened
+	listened.subscription = source.Subscribe("*", func(event *KeyChangeEvent) {
+		// 已经被删除或者替换掉的配置来源，不再处理
+		if !s.isListening(name, listened) {
+			return
+		}
+		xlog.Info("收到配置来源["+source.GetName()+"]的配置变更事件：", s.describeEvent(event))
+		s.audit(source, event, s.updateEffectiveProperty(event.Key))
+		s.onKeyChangeEvent(source, event)
+	})
+}
+
+func (s *StandardEnvironment) isListening(name string, listened *listenedSource) bool {
+	s.effectiveLock.Lock()
+	defer s.effectiveLock.Unlock()
+	return s.listenedSources[name] == listened && s.propertySources.Contains(name)
+}
+
+/**
+取消已经被删除的配置来源的订阅
+*/
+func (s *StandardEnvironment) unlistenRemovedPropertySources() {
+	s.effectiveLock.Lock()
+	defer s.effectiveLock.Unlock()
+	for name, listened := range s.listenedSources {
+		if !s.propertySources.Contains(name) {
+			listened.subscription.Unsubscribe()
+			delete(s.listenedSources, name)
+		}
+	}
+}
+
+/**
+更新单个配置项的生效值快照
+@return 生效值的变更，没有变化的话返回 nil
+*/
+func (s *StandardEnvironment) updateEffectiveProperty(key string) *KeyChangeEvent {
+	value, exists := s.rawProperty(key)
+
+	s.effectiveLock.Lock()
+	defer s.effectiveLock.Unlock()
+	if s.effectiveProperties == nil {
+		return nil
+	}
+	ov, oexists := s.effectiveProperties[key]
+	if exists {
+		s.effectiveProperties[key] = value
+	} else {
+		delete(s.effectiveProperties, key)
+	}
+	return diffProperty(key, ov, oexists, value, exists)
+}
+
+/**
+配置项的原始值，不处理占位符，优先级最高的配置来源中的值生效
+*/
+func (s *StandardEnvironment) rawProperty(key string) (value string, exists bool) {
+	s.propertySources.Each(func(index int, source PropertySource) (stop bool) {
+		value, exists = source.GetProperty(key)
+		return exists
+	})
+	return
+}
+
+/**
+配置来源新增、删除、替换、移动之后，对比生效值的快照，对于生效值发生变化的配置项发送变更事件
+*/
+func (s *StandardEnvironment) publishEffectivePropertyChanges(source PropertySource, changeType PropertySourcesChangeType) {
+	current := s.RawProperties()
+
+	s.effectiveLock.Lock()
+	previous := s.effectiveProperties
+	s.effectiveProperties = current
+	s.effectiveLock.Unlock()
+
+	for _, event := range diffProperties(previous, current) {
+		xlog.Info("配置来源["+source.GetName()+"]["+string(changeType)+"]导致配置生效值变更：", s.describeEvent(event))
+		s.audit(source, nil, event)
+		s.onKeyChangeEvent(source, event)
+	}
+}
+
+/**
+对比两份配置，返回变更事件，按照 key 排序
+*/
+func diffProperties(previous, current map[string]string) []*KeyChangeEvent {
+	keys := make([]string, 0)
+	for key := range previous {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, exists := previous[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	events := make([]*KeyChangeEvent, 0)
+	for _, key := range keys {
+		ov, oexists := previous[key]
+		nv, nexists := current[key]
+		if event := diffProperty(key, ov, oexists, nv, nexists); event != nil {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+/**
+对比单个配置项，没有变化的话返回 nil
+*/
+func diffProperty(key string, ov string, oexists bool, nv string, nexists bool) *KeyChangeEvent {
+	switch {
+	case !oexists && nexists:
+		return &KeyChangeEvent{Key: key, Nv: nv, ChangeType: PropertyAdd}
+	case oexists && !nexists:
+		return &KeyChangeEvent{Key: key, Ov: ov, ChangeType: PropertyDel}
+	case oexists && ov != nv:
+		return &KeyChangeEvent{Key: key, Ov: ov, Nv: nv, ChangeType: PropertyUpdate}
+	}
+	return nil
 }
 
 /**
@@ -432,30 +808,61 @@ Key 变更处理
 */
 func (s *StandardEnvironment) onKeyChangeEvent(source PropertySource, event *KeyChangeEvent) {
 	// 执行监听器
-	if len(s.propertyChangeListeners) > 0 {
-		for _, listener := range s.propertyChangeListeners {
-			keyPattern := listener.KeyPattern
-			handler := listener.Handler
-			if handler == nil {
-				continue
-			}
-			if keyPattern == "" || keyPattern == "*" || keyPattern == event.Key {
-				handler(event)
-				continue
-			}
-			regex, err := regexp.Compile(keyPattern)
-			if err == nil && regex.MatchString(event.Key) {
-				handler(event)
-			}
-		}
+	s.propertyChangeListeners.Publish(source.GetName(), event)
+
+	// 废弃的旧 key 发生变更，通知新 key 的监听器
+	for _, deprecatedEvent := range s.deprecatedKeyEvents(event) {
+		s.propertyChangeListeners.Publish(source.GetName(), deprecatedEvent)
+	}
+}
+
+func (s *StandardEnvironment) BindProperties(keyPrefix string, cfgPtr interface{}, changedListen bool, options ...BindOption) (beanPtr interface{}, err error) {
+	// 重复绑定同一个 Bean 的话，先取消之前的监听
+	s.UnbindProperties(cfgPtr)
+
+	bindOpts := &bindOptions{strict: s.options.strictBinding}
+	for _, option := range options {
+		option(bindOpts)
+	}
+
+	bean := &boundBean{keyPrefix: keyPrefix, beanPtr: cfgPtr, strict: bindOpts.strict}
+	beanPtr, err = s.doBindProperties(keyPrefix, cfgPtr, changedListen, bean)
+	err = filterBindErrors(err, bean.strict)
+	if err == nil {
+		err = s.initializeBean(bean)
+	}
+	if err != nil {
+		bean.subscriptions.Unsubscribe()
+		return nil, err
 	}
+	s.bindBeans[reflect.TypeOf(cfgPtr).Elem()] = beanPtr
+	s.boundBeans = append(s.boundBeans, bean)
+	return
 }
 
-func (s *StandardEnvironment) BindProperties(keyPrefix string, cfgPtr interface{}, changedListen bool) (beanPtr interface{}, err error) {
-	return s.doBindProperties(keyPrefix, cfgPtr, changedListen)
+func (s *StandardEnvironment) UnbindProperties(beanPtr interface{}) bool {
+	for i, bean := range s.boundBeans {
+		if bean.beanPtr != beanPtr {
+			continue
+		}
+		bean.subscriptions.Unsubscribe()
+		bean.stopCallbacks()
+		s.boundBeans = append(s.boundBeans[:i:i], s.boundBeans[i+1:]...)
+
+		beanType := reflect.TypeOf(beanPtr).Elem()
+		if s.bindBeans[beanType] == beanPtr {
+			delete(s.bindBeans, beanType)
+		}
+		return true
+	}
+	return false
 }
 
-func (s *StandardEnvironment) doBindProperties(keyPrefix string, cfgPtr interface{}, listen bool) (beanPtr interface{}, err error) {
+/**
+绑定配置 Bean
+@param bean 注册的监听会记录到 bean 中，用于解除绑定，为 nil 的话不记录
+*/
+func (s *StandardEnvironment) doBindProperties(keyPrefix string, cfgPtr interface{}, listen bool, bean *boundBean) (beanPtr interface{}, err error) {
 	// 反射解析所有属性
 	t := reflect.TypeOf(cfgPtr)
 	if t.Kind() == reflect.Ptr {
@@ -469,20 +876,20 @@ func (s *StandardEnvironment) doBindProperties(keyPrefix string, cfgPtr interfac
 		v = v.Elem()
 	}
 
+	// 转换失败、占位符处理失败的属性，不影响其他属性的绑定，最后一起返回
+	var bindErrs BindErrors
+	var resolveErr error
 	for i := 0; i < t.NumField(); i++ {
 		tfield := t.Field(i)
 		vfield := v.Field(i)
 
-		fieldName := tfield.Name
+		// 默认是首字母小写，和 ResolveConfigFields 保持一致
+		subKey := configFieldSubKey(tfield)
+		configKey := keyPrefix + subKey
 
-		// 默认是首字母小写
-		configKey := keyPrefix + xstr.FirstLetterLower(fieldName)
-		subKey := tfield.Tag.Get("ck")
-		if len(subKey) < 1 {
-			subKey = tfield.Tag.Get("sk")
-		}
-		if len(subKey) > 0 {
-			configKey = keyPrefix + subKey
+		if "true" == tfield.Tag.Get("secret") {
+			// 标记为当前环境中的敏感配置，配置导出、审计记录等也需要脱敏
+			s.secretKeys.add(configKey, configKey+".*")
 		}
 
 		// 是否需要展开
@@ -491,8 +898,8 @@ func (s *StandardEnvironment) doBindProperties(keyPrefix string, cfgPtr interfac
 		if expand {
 			// Map
 			if tfield.Type.Kind() == reflect.Map || (tfield.Type.Kind() == reflect.Ptr && tfield.Type.Elem().Kind() == reflect.Map) {
-				_, err := s.doBindSubMapField(t, keyPrefix, tfield, vfield, subKey, true)
-				if err != nil {
+				_, err := s.doBindSubMapField(t, keyPrefix, tfield, vfield, subKey, listen, bean)
+				if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
 					return nil, err
 				}
 				continue
@@ -503,8 +910,8 @@ func (s *StandardEnvironment) doBindProperties(keyPrefix string, cfgPtr interfac
 				if t == tfield.Type || (tfield.Type.Kind() == reflect.Ptr && t == tfield.Type.Elem()) {
 					panic("[" + t.Name() + "." + tfield.Name + "] 属性是expand 类型的，不允许嵌套，不能是[" + t.Name() + "]类型")
 				}
-				_, err := s.doBindSubStructField(keyPrefix, tfield, vfield, subKey, listen)
-				if err != nil {
+				_, err := s.doBindSubStructField(keyPrefix, tfield, vfield, subKey, listen, bean)
+				if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
 					return nil, err
 				}
 				continue
@@ -516,37 +923,103 @@ func (s *StandardEnvironment) doBindProperties(keyPrefix string, cfgPtr interfac
 		initVal := tfield.Tag.Get("def")
 
 		// 获取配置的值
-		value, exists := s.GetProperty(configKey)
-		if !exists {
-			value = s.ResolvePlaceholders(initVal)
+		value, exists, err := s.getFieldProperty(configKey, tfield.Type)
+		if elemErrs, ok := err.(BindErrors); ok {
+			// 数组、切片中 struct 元素的转换失败信息，组装好的值照常回写
+			bindErrs = append(bindErrs, elemErrs...)
+			err = nil
+		}
+		if err == nil && !exists {
+			value, err = s.ResolvePlaceholdersE(initVal)
+		}
+		if err != nil {
+			// 占位符循环引用或者无法识别，属性保持原值，继续绑定其他属性
+			if resolveErr == nil {
+				resolveErr = err
+			}
+		} else {
+			if bindErr := s.checkBeanPropertyValue(configKey, tfield, value); bindErr != nil {
+				bindErrs = append(bindErrs, bindErr)
+			}
+			// 反射进行配置回写
+			s.applyBeanPropertyValue(t, tfield, vfield, configKey, initVal, value, PropertyUpdate)
 		}
-		// 反射进行配置回写
-		s.applyBeanPropertyValue(t, tfield, vfield, initVal, value, PropertyUpdate)
 
 		if listen {
 			// 注册监听器, 占位符问题，每次变更的话，都需要重新检查占位符，当占位符变化这个也要变化
-			s.Subscribe(strings.Replace(configKey, ".", "\\.", -1)+".*", func() func(event *KeyChangeEvent) {
+			bean.addSubscription(s.Subscribe(strings.Replace(configKey, ".", "\\.", -1)+".*", func() func(event *KeyChangeEvent) {
 				return func(event *KeyChangeEvent) {
-					nv, _ := s.GetProperty(event.Key)
-					s.applyBeanPropertyValue(t, tfield, vfield, initVal, nv, event.ChangeType)
+					if event.Key != configKey && !isListElementKey(configKey, event.Key) {
+						return
+					}
+					// 其他配置来源中可能还存在这个配置项，所以要重新获取当前生效的值，都不存在的话才使用默认值
+					nv, exists, err := s.getFieldProperty(configKey, tfield.Type)
+					elemErrs, _ := err.(BindErrors)
+					if elemErrs != nil {
+						err = nil
+					}
+					if err == nil && !exists {
+						nv, err = s.ResolvePlaceholdersE(initVal)
+					}
+					if err != nil {
+						// 占位符处理失败，无论是否严格模式都拒绝本次更新，保持原来的值，避免在监听协程中 panic
+						s.rejectBeanChange(bean, configKey, err)
+						return
+					}
+					if bean != nil && bean.strict {
+						// 严格模式下转换失败拒绝本次更新，保持原来的值
+						if bindErr := s.checkBeanPropertyValue(configKey, tfield, nv); bindErr != nil {
+							elemErrs = append(elemErrs, bindErr)
+						}
+						if len(elemErrs) > 0 {
+							s.rejectBeanChange(bean, configKey, elemErrs)
+							return
+						}
+					}
+					s.applyBeanChange(bean, configKey, func() {
+						s.applyBeanPropertyValue(t, tfield, vfield, configKey, initVal, nv, PropertyUpdate)
+					})
 				}
-			}())
+			}()))
 		}
 	}
-	jsonText, err := json.Marshal(cfgPtr)
+	jsonText, err := json.Marshal(MaskSecretBean(keyPrefix, cfgPtr))
 	if err != nil {
 		return nil, err
 	}
 	xlog.Info("绑定配置Bean["+t.Name()+"] => ", string(jsonText))
-	return cfgPtr, nil
+	if resolveErr != nil {
+		return cfgPtr, resolveErr
+	}
+	return cfgPtr, bindErrs.errorOrNil()
+}
+
+/**
+合并子属性绑定的异常：转换失败合并到 bindErrs，占位符处理失败只保留第一个，其他异常原样返回，需要终止绑定
+*/
+func collectBindError(err error, bindErrs *BindErrors, resolveErr *error) error {
+	if err == nil || bindErrs.merge(err) {
+		return nil
+	}
+	switch err.(type) {
+	case *xplaceholder.CircularPlaceholderError, *xplaceholder.UnresolvablePlaceholderError:
+		if *resolveErr == nil {
+			*resolveErr = err
+		}
+		return nil
+	}
+	return err
 }
 
-func (s *StandardEnvironment) doBindSubStructField(keyPrefix string, tfield reflect.StructField, vfield reflect.Value, subKey string, changeListen bool) (interface{}, error) {
+func (s *StandardEnvironment) doBindSubStructField(keyPrefix string, tfield reflect.StructField, vfield reflect.Value, subKey string, changeListen bool, bean *boundBean) (interface{}, error) {
+	// 转换失败、占位符处理失败不影响其他属性的绑定，最后一起返回
+	var bindErrs BindErrors
+	var resolveErr error
 	if vfield.Type().Kind() == reflect.Ptr {
 		if vfield.IsNil() {
 			nValue := reflect.New(vfield.Type().Elem())
-			_, err := s.doBindProperties(keyPrefix+subKey+".", nValue.Interface(), true)
-			if nil != err {
+			_, err := s.doBindProperties(keyPrefix+subKey+".", nValue.Interface(), changeListen, bean)
+			if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
 				return nil, err
 			}
 			err = xreflect.SetFieldValueByField(tfield, vfield, nValue)
@@ -554,18 +1027,21 @@ func (s *StandardEnvironment) doBindSubStructField(keyPrefix string, tfield refl
 				return nil, err
 			}
 		} else {
-			_, err := s.doBindProperties(keyPrefix+subKey+".", vfield.Interface(), changeListen)
-			if nil != err {
+			_, err := s.doBindProperties(keyPrefix+subKey+".", vfield.Interface(), changeListen, bean)
+			if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
 				return nil, err
 			}
 		}
 	} else {
-		_, err := s.doBindProperties(keyPrefix+subKey+".", vfield.Addr().Interface(), changeListen)
-		if nil != err {
+		_, err := s.doBindProperties(keyPrefix+subKey+".", vfield.Addr().Interface(), changeListen, bean)
+		if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
 			return nil, err
 		}
 	}
-	return nil, nil
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	return nil, bindErrs.errorOrNil()
 }
 
 /**
@@ -587,7 +1063,7 @@ func (s *StandardEnvironment) GetProperties(typeTemplate interface{}) (beanPtr i
 	return nil
 }
 
-func (s *StandardEnvironment) applyBeanPropertyValue(beanType reflect.Type, tfield reflect.StructField, vfield reflect.Value, initVal string, value string, changeType KeyChangeType) {
+func (s *StandardEnvironment) applyBeanPropertyValue(beanType reflect.Type, tfield reflect.StructField, vfield reflect.Value, configKey string, initVal string, value string, changeType KeyChangeType) {
 	if PropertyDel == changeType {
 		// 删除，设置回原来的初始值
 		value = initVal
@@ -597,10 +1073,10 @@ func (s *StandardEnvironment) applyBeanPropertyValue(beanType reflect.Type, tfie
 
 	defer func() {
 		if r := recover(); r != nil {
-			xlog.Error("配置转换异常：panic,Property:["+beanType.Name()+"."+tfield.Name+":"+tfield.Type.Name()+"], newVal:["+value+"]", r)
+			xlog.Error("配置转换异常：panic,Property:["+beanType.Name()+"."+tfield.Name+":"+tfield.Type.Name()+"], newVal:["+s.MaskPropertyValue(configKey, value)+"]", r)
 		} else {
 			if cerr != nil {
-				xlog.Error("配置转换失败,Property:["+beanType.Name()+"."+tfield.Name+":"+tfield.Type.Name()+"], newVal:["+value+"]", cerr)
+				xlog.Error("配置转换失败,Property:["+beanType.Name()+"."+tfield.Name+":"+tfield.Type.Name()+"], newVal:["+s.MaskPropertyValue(configKey, value)+"]", cerr)
 			}
 		}
 	}()
@@ -608,11 +1084,28 @@ func (s *StandardEnvironment) applyBeanPropertyValue(beanType reflect.Type, tfie
 	cerr = xreflect.SetFieldValueByField(tfield, vfield, value)
 }
 
+/**
+所有生效的配置项，敏感配置会被脱敏，用于日志输出、配置展示等，需要原始值的话使用 RawProperties
+*/
 func (s *StandardEnvironment) Properties() map[string]string {
+	properties := s.RawProperties()
+	for key, value := range properties {
+		properties[key] = s.MaskPropertyValue(key, value)
+	}
+	return properties
+}
+
+/**
+所有生效的配置项原始值，不做脱敏
+*/
+func (s *StandardEnvironment) RawProperties() map[string]string {
 	properties := make(map[string]string)
 	s.propertySources.Each(func(index int, source PropertySource) (pstop bool) {
 		source.Each(func(key, value string) (stop bool) {
-			properties[key] = value
+			// 越靠前的配置来源优先级越高
+			if _, exists := properties[key]; !exists {
+				properties[key] = value
+			}
 			return false
 		})
 		return false
@@ -624,7 +1117,7 @@ func (s *StandardEnvironment) Properties() map[string]string {
 遍历所有的配置项&值, consumer 处理过程中如果返回 stop=true则停止遍历
 */
 func (s *StandardEnvironment) EachProperty(consumer func(key, value string) (stop bool)) {
-	properties := s.Properties()
+	properties := s.RawProperties()
 	if properties == nil || len(properties) < 1 {
 		return
 	}
@@ -636,7 +1129,7 @@ func (s *StandardEnvironment) EachProperty(consumer func(key, value string) (sto
 	}
 }
 
-func (s *StandardEnvironment) doBindSubMapField(t reflect.Type, keyPrefix string, tfield reflect.StructField, vfield reflect.Value, subKey string, listen bool) (interface{}, error) {
+func (s *StandardEnvironment) doBindSubMapField(t reflect.Type, keyPrefix string, tfield reflect.StructField, vfield reflect.Value, subKey string, listen bool, bean *boundBean) (interface{}, error) {
 	// MAP 类型， 要求key必须是 int 或者 string 类型
 	keyTypeName := tfield.Type.Key().Name()
 	if !strings.HasPrefix(keyTypeName, "int") && keyTypeName != "string" {
@@ -644,6 +1137,26 @@ func (s *StandardEnvironment) doBindSubMapField(t reflect.Type, keyPrefix string
 	}
 	configKey := keyPrefix + subKey + "."
 
+	nMap, err := s.buildSubMapValue(tfield, configKey)
+	if !nMap.IsValid() {
+		return nil, err
+	}
+	vfield.Set(nMap)
+
+	if listen {
+		bean.addSubscription(s.doListenMapField(configKey, t, keyPrefix, tfield, vfield, subKey, bean))
+	}
+
+	return vfield.Interface(), err
+}
+
+/**
+根据配置构造 map 属性的值，转换失败、占位符处理失败的元素不影响其他元素，和 doBindSubStructField 一样最后一起返回；
+map 的 key 无法转换的话返回无效的 reflect.Value 以及异常
+*/
+func (s *StandardEnvironment) buildSubMapValue(tfield reflect.StructField, configKey string) (reflect.Value, error) {
+	var bindErrs BindErrors
+	var resolveErr error
 	// key 类型
 	kType := tfield.Type.Key()
 	// 元素类型
@@ -654,6 +1167,10 @@ func (s *StandardEnvironment) doBindSubMapField(t reflect.Type, keyPrefix string
 		if strings.HasPrefix(key, configKey) { // 前缀
 			key = strings.Replace(key, configKey, "", 1)
 			index1 := strings.Index(key, ".")
+			if index1 < 0 {
+				// map 的元素是结构体，没有下一级 key 的配置项不处理，避免监听协程中 panic
+				return false
+			}
 			fieldKey := key[0:index1]
 			keys[fieldKey] = true
 		}
@@ -665,44 +1182,48 @@ func (s *StandardEnvironment) doBindSubMapField(t reflect.Type, keyPrefix string
 	for fieldKey, _ := range keys {
 		kValue, err := xreflect.ConvertTo(fieldKey, kType)
 		if nil != err {
-			panic("Map属性[" + fieldKey + "]无法转换成[" + kType.Name() + "]")
+			return reflect.Value{}, fmt.Errorf("Map属性[%s%s]的 key 无法转换成[%s]：%v", configKey, fieldKey, kType.Name(), err)
 		}
 
 		if vType.Kind() == reflect.Ptr {
 			vValue := reflect.New(vType.Elem())
 			// 注入
-			_, err = s.doBindProperties(configKey+fieldKey+".", vValue.Interface(), false)
-			if nil != err {
-				panic("Map属性处理失败, keyPrefix: " + configKey + fieldKey + ".")
-				return nil, err
+			_, err = s.doBindProperties(configKey+fieldKey+".", vValue.Interface(), false, nil)
+			if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
+				return reflect.Value{}, err
 			}
 			nMap.SetMapIndex(kValue, vValue)
 		} else {
 			vValue := reflect.New(vType)
 			// 注入
-			_, err = s.doBindProperties(configKey+fieldKey+".", vValue.Interface(), false)
-			if nil != err {
-				panic("Map属性处理失败, keyPrefix: " + configKey + fieldKey + ".")
-				return nil, err
+			_, err = s.doBindProperties(configKey+fieldKey+".", vValue.Interface(), false, nil)
+			if err = collectBindError(err, &bindErrs, &resolveErr); err != nil {
+				return reflect.Value{}, err
 			}
 			nMap.SetMapIndex(kValue, vValue.Elem())
 		}
 	}
-
-	vfield.Set(nMap)
-
-	if listen {
-		s.doListenMapField(configKey, t, keyPrefix, tfield, vfield, subKey)
+	if resolveErr != nil {
+		return nMap, resolveErr
 	}
-
-	return vfield.Interface(), nil
+	return nMap, bindErrs.errorOrNil()
 }
 
-func (s *StandardEnvironment) doListenMapField(configKey string, t reflect.Type, keyPrefix string, tfield reflect.StructField, vfield reflect.Value, subKey string) {
+func (s *StandardEnvironment) doListenMapField(configKey string, t reflect.Type, keyPrefix string, tfield reflect.StructField, vfield reflect.Value, subKey string, bean *boundBean) Subscription {
 	// 注册监听器, 占位符问题，每次变更的话，都需要重新检查占位符，当占位符变化这个也要变化
-	s.Subscribe(strings.Replace(configKey, ".", "\\.", -1)+".*", func() func(event *KeyChangeEvent) {
+	return s.Subscribe(strings.Replace(configKey, ".", "\\.", -1)+".*", func() func(event *KeyChangeEvent) {
 		return func(event *KeyChangeEvent) {
-			_, _ = s.doBindSubMapField(t, keyPrefix, tfield, vfield, subKey, false)
+			nMap, err := s.buildSubMapValue(tfield, configKey)
+			if err != nil {
+				// 占位符处理失败、key 无法转换的话无论是否严格模式都拒绝本次更新，严格模式下转换失败也拒绝，保持原来的值
+				if _, ok := err.(BindErrors); !ok || (bean != nil && bean.strict) {
+					s.rejectBeanChange(bean, event.Key, err)
+					return
+				}
+			}
+			s.applyBeanChange(bean, event.Key, func() {
+				vfield.Set(nMap)
+			})
 		}
 	}())
 }
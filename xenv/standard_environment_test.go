@@ -6,6 +6,10 @@ import (
 	"github.com/xkgo/xkit/xcontext"
 	"github.com/xkgo/xkit/xjson"
 	"github.com/xkgo/xkit/xlog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -27,7 +31,7 @@ func NewFixedPublishEventMapPropertySource(name string, properties map[string]st
 	return source
 }
 
-func (m *FixedPublishEventMapPropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) {
+func (m *FixedPublishEventMapPropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription {
 	go func() {
 		looptimes := 0
 		for {
@@ -46,6 +50,7 @@ func (m *FixedPublishEventMapPropertySource) Subscribe(keyPattern string, handle
 			time.Sleep(time.Duration(2) * time.Second)
 		}
 	}()
+	return NewSubscription(nil)
 }
 
 func (m *FixedPublishEventMapPropertySource) init() {
@@ -246,6 +251,40 @@ func TestStandardEnvironment_BindxlogProperties(t *testing.T) {
 	fmt.Println(props)
 }
 
+func TestStandardEnvironment_XlogRefreshScope(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"xlog.max-size": "abc"})
+	xlogScope := func(env *StandardEnvironment) *RefreshScopedComponent {
+		env.xlogScopeLock.Lock()
+		defer env.xlogScopeLock.Unlock()
+		return env.xlogScope
+	}
+
+	// 日志配置绑定失败不能 panic
+	var env *StandardEnvironment
+	assert.NotPanics(t, func() {
+		env = New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), StrictBinding(),
+			CustomRunInfo(&RunInfo{Env: Dev}), AdditionalPropertySources(NewMutablePropertySources(source)))
+	})
+	if !assert.NotNil(t, xlogScope(env)) {
+		return
+	}
+	prop := func() *xlog.Properties {
+		return xlogScope(env).Get().(*xlog.Properties)
+	}
+	// 创建的时候已经有运行时信息，开发环境强制开启 console log
+	assert.Equal(t, 500, prop().MaxSize)
+	assert.True(t, prop().ConsoleLog)
+
+	// 配置修正之后重新初始化日志
+	source.Put("xlog.max-size", "100")
+	deadline := time.Now().Add(2 * time.Second)
+	for prop().MaxSize != 100 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 100, prop().MaxSize)
+	assert.True(t, prop().ConsoleLog)
+}
+
 func TestStandardEnvironment_MultiInclude(t *testing.T) {
 	env := New(
 		ConfigDirs(map[Env]string{Dev: "./testdata"}),
@@ -277,3 +316,252 @@ func TestStandardEnvironment_BindPropertiesListen(t *testing.T) {
 	assert.Equal(t, int64(0), config.PageSize)
 
 }
+
+func TestStandardEnvironment_PropertySourcesChangeEvent(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("base", map[string]string{"app.name": "base", "app.port": "8080"}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	events := make(map[string]*KeyChangeEvent)
+	env.Subscribe("app\\..*", func(event *KeyChangeEvent) {
+		events[event.Key] = event
+	})
+
+	cfg := &struct {
+		Name string `ck:"name"`
+		Port int    `ck:"port"`
+	}{}
+	_, err := env.BindProperties("app.", cfg, true)
+	assert.Nil(t, err)
+
+	// 添加高优先级的配置来源，只有生效值变化的 key 会收到事件
+	env.GetPropertySources().AddFirst(NewMapPropertySource("override", map[string]string{"app.name": "override", "app.port": "8080"}))
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "base", events["app.name"].Ov)
+	assert.Equal(t, "override", events["app.name"].Nv)
+	assert.Equal(t, "override", cfg.Name)
+
+	// 移动到最后，恢复原来的值
+	assert.Nil(t, env.GetPropertySources().MoveAfter("override", "base"))
+	assert.Equal(t, "base", cfg.Name)
+
+	// 删除之后，配置项不存在
+	env.GetPropertySources().Remove("base")
+	assert.Equal(t, "override", cfg.Name)
+	env.GetPropertySources().Remove("override")
+	assert.Equal(t, PropertyDel, events["app.port"].ChangeType)
+	assert.Equal(t, 0, cfg.Port)
+}
+
+func TestStandardEnvironment_BindPropertiesListenFallback(t *testing.T) {
+	high := NewMapPropertySource("high", map[string]string{"app.port": "9090"})
+	low := NewMapPropertySource("low", map[string]string{"app.port": "8080"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(high)
+	sources.AddLast(low)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &struct {
+		Port int `ck:"port" def:"7070"`
+	}{}
+	_, err := env.BindProperties("app.", cfg, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 9090, cfg.Port)
+
+	awaitPort := func(port int) {
+		deadline := time.Now().Add(3 * time.Second)
+		for cfg.Port != port && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, port, cfg.Port)
+	}
+
+	// 只响应完全相同的 key，app.portal 不会影响 app.port
+	events := make(chan *KeyChangeEvent, 1)
+	sub := env.Subscribe("app.portal", func(event *KeyChangeEvent) {
+		events <- event
+	})
+	high.Put("app.portal", "1")
+	<-events
+	sub.Unsubscribe()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 9090, cfg.Port)
+
+	// 删除之后使用其他配置来源中的值，都不存在的话使用默认值
+	high.Remove("app.port")
+	awaitPort(8080)
+	low.Remove("app.port")
+	awaitPort(7070)
+}
+
+func TestStandardEnvironment_RawProperties(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("high", map[string]string{"app.name": "high"}))
+	sources.AddLast(NewMapPropertySource("low", map[string]string{"app.name": "low", "app.port": "8080"}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	// 同一个 key 使用优先级最高的配置来源中的值，和 GetProperty 一致
+	properties := env.RawProperties()
+	assert.Equal(t, "high", properties["app.name"])
+	assert.Equal(t, "8080", properties["app.port"])
+	env.EachProperty(func(key, value string) (stop bool) {
+		if key == "app.name" {
+			assert.Equal(t, "high", value)
+		}
+		return false
+	})
+}
+
+/**
+值类型的配置来源，包含 map，不能作为 map 的 key
+*/
+type valuePropertySource struct {
+	name      string
+	kvs       map[string]string
+	listeners *PropertyChangeListenerRegistry
+}
+
+func (v valuePropertySource) GetName() string {
+	return v.name
+}
+
+func (v valuePropertySource) GetProperty(key string) (value string, exists bool) {
+	value, exists = v.kvs[key]
+	return
+}
+
+func (v valuePropertySource) GetPropertyWithDef(key string, def string) string {
+	if value, exists := v.kvs[key]; exists {
+		return value
+	}
+	return def
+}
+
+func (v valuePropertySource) Each(consumer func(key, value string) (stop bool)) {
+	for key, value := range v.kvs {
+		if consumer(key, value) {
+			return
+		}
+	}
+}
+
+func (v valuePropertySource) Subscribe(keyPattern string, handler func(event *KeyChangeEvent)) Subscription {
+	return v.listeners.Add(keyPattern, handler)
+}
+
+func TestStandardEnvironment_NonComparablePropertySource(t *testing.T) {
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit())
+	source := valuePropertySource{name: "value", kvs: map[string]string{"app.name": "a"}, listeners: NewPropertyChangeListenerRegistry()}
+	assert.NotPanics(t, func() {
+		env.GetPropertySources().AddLast(source)
+	})
+	assert.Equal(t, "a", env.GetPropertyWithDef("app.name", ""))
+
+	events := make(chan *KeyChangeEvent, 1)
+	env.Subscribe("app.name", func(event *KeyChangeEvent) {
+		events <- event
+	})
+	source.kvs["app.name"] = "b"
+	source.listeners.Publish(source.name, &KeyChangeEvent{Key: "app.name", Ov: "a", Nv: "b", ChangeType: PropertyUpdate})
+	select {
+	case event := <-events:
+		assert.Equal(t, "b", event.Nv)
+	case <-time.After(time.Second):
+		t.Fatal("未收到配置变更事件")
+	}
+
+	// 替换之后旧的配置来源的事件不再处理
+	replaced := valuePropertySource{name: "value", kvs: map[string]string{"app.name": "c"}, listeners: NewPropertyChangeListenerRegistry()}
+	assert.NotPanics(t, func() {
+		assert.Nil(t, env.GetPropertySources().Replace("value", replaced))
+	})
+	select {
+	case event := <-events:
+		assert.Equal(t, "c", event.Nv)
+	case <-time.After(time.Second):
+		t.Fatal("未收到配置变更事件")
+	}
+	assert.Equal(t, 0, source.listeners.Size())
+	assert.Equal(t, 1, replaced.listeners.Size())
+	assert.NotPanics(t, func() {
+		env.GetPropertySources().Remove("value")
+	})
+	assert.Equal(t, 0, replaced.listeners.Size())
+}
+
+func TestStandardEnvironment_UnbindProperties(t *testing.T) {
+	source := NewMapPropertySource("test", map[string]string{"app.name": "a"})
+	sources := NewMutablePropertySources()
+	sources.AddLast(source)
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	cfg := &struct {
+		Name string `ck:"name"`
+	}{}
+	_, err := env.BindProperties("app.", cfg, true)
+	assert.Nil(t, err)
+	// 重复绑定不会重复注册监听
+	_, err = env.BindProperties("app.", cfg, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, env.propertyChangeListeners.Size())
+
+	events := make(chan *KeyChangeEvent, 1)
+	sub := env.Subscribe("app.name", func(event *KeyChangeEvent) {
+		events <- event
+	})
+
+	source.Put("app.name", "b")
+	<-events
+	assert.Equal(t, "b", cfg.Name)
+
+	assert.True(t, env.UnbindProperties(cfg))
+	assert.False(t, env.UnbindProperties(cfg))
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	assert.Equal(t, 0, env.propertyChangeListeners.Size())
+
+	// 等待事件分发完成之后，再确认一段时间内 Bean 的属性都没有变化
+	sub = env.Subscribe("app.name", func(event *KeyChangeEvent) {
+		events <- event
+	})
+	defer sub.Unsubscribe()
+	source.Put("app.name", "c")
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("未收到配置变更事件")
+	}
+	assert.Equal(t, "c", env.GetPropertyWithDef("app.name", ""))
+	for deadline := time.Now().Add(50 * time.Millisecond); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		assert.Equal(t, "b", cfg.Name)
+	}
+}
+
+func TestStandardEnvironment_IniAndHclConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xenv-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "application.ini"), []byte(
+		"[server]\nport = 8080 ; 默认端口\nname = \"demo\"\n[xenv.profile]\ninclude = legacy\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "application-legacy.hcl"), []byte(
+		"server {\n  port = 9090\n}\n"), 0644))
+
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), DisableXlogInit(),
+		ConfigDirs(map[Env]string{Dev: dir, Test: dir, Fat: dir, Prod: dir}))
+	assert.Equal(t, []string{"legacy"}, env.GetActiveProfiles())
+	assert.Equal(t, "9090", env.GetPropertyWithDef("server.port", ""))
+	assert.Equal(t, "demo", env.GetPropertyWithDef("server.name", ""))
+}
+
+func TestNewFormatPropertySource(t *testing.T) {
+	source, err := NewFormatPropertySource("remote", "ini", strings.NewReader("[server]\nport = 8080\n"), "http://config-center/app.ini")
+	assert.Nil(t, err)
+	value, ok := source.GetProperty("server.port")
+	assert.True(t, ok)
+	assert.Equal(t, "8080", value)
+	assert.Equal(t, "http://config-center/app.ini", GetPropertyOrigin(source, "server.port"))
+
+	_, err = NewFormatPropertySource("remote", "bak", strings.NewReader(""), "")
+	assert.NotNil(t, err)
+}
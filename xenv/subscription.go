@@ -0,0 +1,132 @@
+package xenv
+
+import (
+	"github.com/xkgo/xkit/xcontext"
+	"github.com/xkgo/xkit/xlog"
+	"sync"
+)
+
+/**
+订阅句柄，调用 Unsubscribe 取消订阅，可以重复调用
+*/
+type Subscription interface {
+	Unsubscribe()
+}
+
+type subscription struct {
+	once   sync.Once
+	cancel func()
+}
+
+func (s *subscription) Unsubscribe() {
+	s.once.Do(s.cancel)
+}
+
+/**
+创建订阅句柄，cancel 只会被执行一次
+*/
+func NewSubscription(cancel func()) Subscription {
+	if cancel == nil {
+		cancel = func() {}
+	}
+	return &subscription{cancel: cancel}
+}
+
+/**
+多个订阅句柄，Unsubscribe 的时候取消所有订阅
+*/
+type Subscriptions []Subscription
+
+func (s Subscriptions) Unsubscribe() {
+	for _, sub := range s {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+	}
+}
+
+/**
+配置变更监听器注册表，并发安全，零值可以直接使用
+*/
+type PropertyChangeListenerRegistry struct {
+	lock      sync.RWMutex
+	listeners []*PropertyChangeListener
+}
+
+func NewPropertyChangeListenerRegistry() *PropertyChangeListenerRegistry {
+	return &PropertyChangeListenerRegistry{}
+}
+
+/**
+注册监听器，keyPattern 规则参考 PropertyChangeListener
+*/
+func (r *PropertyChangeListenerRegistry) Add(keyPattern string, handler func(event *KeyChangeEvent)) Subscription {
+	listener := NewPropertyChangeListener(keyPattern, handler)
+	r.lock.Lock()
+	r.listeners = append(r.listeners, listener)
+	r.lock.Unlock()
+	return NewSubscription(func() {
+		r.remove(listener)
+	})
+}
+
+func (r *PropertyChangeListenerRegistry) remove(listener *PropertyChangeListener) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, item := range r.listeners {
+		if item == listener {
+			listeners := make([]*PropertyChangeListener, 0, len(r.listeners)-1)
+			listeners = append(listeners, r.listeners[:i]...)
+			r.listeners = append(listeners, r.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+/**
+当前所有的监听器
+*/
+func (r *PropertyChangeListenerRegistry) Listeners() []*PropertyChangeListener {
+	if r == nil {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.listeners
+}
+
+func (r *PropertyChangeListenerRegistry) Size() int {
+	return len(r.Listeners())
+}
+
+/**
+清空所有监听器
+*/
+func (r *PropertyChangeListenerRegistry) Clear() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	r.listeners = nil
+	r.lock.Unlock()
+}
+
+/**
+发布变更事件，执行所有匹配的监听器，监听器发生 panic 不影响其他监听器的执行
+@param name 发布者名称，用于日志
+*/
+func (r *PropertyChangeListenerRegistry) Publish(name string, event *KeyChangeEvent) {
+	for _, listener := range r.Listeners() {
+		if listener.Handler == nil || !listener.Matches(event.Key) {
+			continue
+		}
+		handler := listener.Handler
+		xcontext.Run(func() {
+			handler(event)
+		}, func(err interface{}, hadPanic bool) {
+			if hadPanic {
+				xlog.Warn("配置源["+name+"]执行配置变更[", listener.KeyPattern, "]发生panic： ", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestPropertyChangeListenerRegistry(t *testing.T) {
+	registry := NewPropertyChangeListenerRegistry()
+
+	counts := make(map[string]int)
+	lock := sync.Mutex{}
+	handler := func(name string) func(event *KeyChangeEvent) {
+		return func(event *KeyChangeEvent) {
+			lock.Lock()
+			defer lock.Unlock()
+			counts[name]++
+		}
+	}
+
+	all := registry.Add("*", handler("all"))
+	registry.Add("app.name", handler("equal"))
+	registry.Add("app\\..*", handler("regex"))
+	registry.Add("panic", func(event *KeyChangeEvent) {
+		panic("listener panic")
+	})
+
+	registry.Publish("test", &KeyChangeEvent{Key: "app.name"})
+	registry.Publish("test", &KeyChangeEvent{Key: "panic"})
+	assert.Equal(t, map[string]int{"all": 2, "equal": 1, "regex": 1}, counts)
+
+	all.Unsubscribe()
+	all.Unsubscribe()
+	assert.Equal(t, 3, registry.Size())
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			registry.Add("*", handler("concurrent")).Unsubscribe()
+			registry.Publish("test", &KeyChangeEvent{Key: "app.port"})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 3, registry.Size())
+	assert.Equal(t, 11, counts["regex"])
+
+	registry.Clear()
+	assert.Equal(t, 0, registry.Size())
+}
@@ -1,8 +1,8 @@
 package xenv
 
 import (
-	"github.com/xkgo/xkit/xstr"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -16,21 +16,142 @@ const (
 */
 type SystemEnvironmentPropertySource struct {
 	MapPropertySource
+	prefix string // 只导入这个前缀的系统环境变量，为空表示导入所有的系统环境变量
 }
 
+/**
+系统环境变量名（去掉前缀之后）转换成配置 key 的规则，参考 DefaultEnvironmentVariableMapper、IndexedEnvironmentVariableMapper
+*/
+type EnvironmentVariableMapper func(name string) string
+
+/**
+默认的转换规则：转成小写，__ 以及 _ 都转成 .，比如 MYAPP_SERVER_PORT、MYAPP_SERVER__PORT => server.port
+*/
+func DefaultEnvironmentVariableMapper(name string) string {
+	key := strings.Replace(strings.ToLower(name), "__", ".", -1)
+	return strings.Replace(key, "_", ".", -1)
+}
+
+/**
+需要 - 以及数组下标的话使用的转换规则，需要通过 SystemEnvironmentKeyMapper 显式指定：
+	> 转成小写，__ 转成 .，单个 _ 转成 -，比如 MYAPP_SERVER__MAX_SIZE => server.max-size
+	> __ 之间全是数字的话作为数组下标，比如 MYAPP_SERVERS__0__HOST => servers[0].host
+*/
+func IndexedEnvironmentVariableMapper(name string) string {
+	buf := strings.Builder{}
+	for i, segment := range strings.Split(strings.ToLower(name), "__") {
+		if i > 0 && isDigits(segment) {
+			buf.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			buf.WriteString(".")
+		}
+		buf.WriteString(strings.Replace(segment, "_", "-", -1))
+	}
+	return buf.String()
+}
+
+func isDigits(text string) bool {
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(text) > 0
+}
+
+/**
+导入所有的系统环境变量，变量名不做转换
+*/
 func NewSystemEnvironmentPropertySource() *SystemEnvironmentPropertySource {
-	source := &SystemEnvironmentPropertySource{}
+	return newSystemEnvironmentPropertySource(os.Environ(), "", nil)
+}
+
+/**
+只导入指定前缀的系统环境变量，去掉前缀之后按照 DefaultEnvironmentVariableMapper 转换成配置 key，
+比如前缀为 MYAPP_ 的时候 MYAPP_SERVER_PORT => server.port；
+转换之后的 key 都是小写，获取配置的时候忽略大小写，所以 MYAPP_APP_MINVERSION 也可以绑定到 app.minVersion。
+配置项的来源为原始的变量名，参考 GetPropertyOrigin
+*/
+func NewPrefixedSystemEnvironmentPropertySource(prefix string) *SystemEnvironmentPropertySource {
+	return newSystemEnvironmentPropertySource(os.Environ(), prefix, DefaultEnvironmentVariableMapper)
+}
+
+/**
+只导入指定前缀的系统环境变量，去掉前缀之后按照 mapper 转换成配置 key，mapper 为 nil 的话使用 DefaultEnvironmentVariableMapper，
+其他参考 NewPrefixedSystemEnvironmentPropertySource
+*/
+func NewMappedSystemEnvironmentPropertySource(prefix string, mapper EnvironmentVariableMapper) *SystemEnvironmentPropertySource {
+	return newSystemEnvironmentPropertySource(os.Environ(), prefix, mapper)
+}
+
+func newSystemEnvironmentPropertySource(environ []string, prefix string, mapper EnvironmentVariableMapper) *SystemEnvironmentPropertySource {
+	source := &SystemEnvironmentPropertySource{prefix: prefix}
 	source.name = SystemEnvironmentPropertySourceName
 	source.properties = &sync.Map{}
+	source.propertyChangeListeners = NewPropertyChangeListenerRegistry()
+	source.origins = &sync.Map{}
+	if mapper == nil {
+		mapper = DefaultEnvironmentVariableMapper
+	}
 
-	envs := os.Environ()
-	for _, kv := range envs {
-		kvs := xstr.SplitByRegex(kv, "\\s*=\\s*")
-		if len(kvs) == 1 {
-			source.properties.Store(xstr.Trim(kvs[0]), "")
-		} else if len(kvs) == 2 {
-			source.properties.Store(xstr.Trim(kvs[0]), xstr.Trim(kvs[1]))
+	for _, kv := range environ {
+		name, value := parseEnvironmentVariable(kv)
+		if len(name) < 1 {
+			continue
+		}
+		if len(prefix) < 1 {
+			source.properties.Store(name, value)
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
 		}
+		key := mapper(name[len(prefix):])
+		source.properties.Store(key, value)
+		source.SetPropertyOrigin(key, name)
 	}
 	return source
 }
+
+/**
+指定了前缀的话，key 不存在的时候忽略大小写再查找一次，环境变量转换之后的 key 都是小写，这样驼峰形式的 key 也能获取到
+*/
+func (s *SystemEnvironmentPropertySource) GetProperty(key string) (value string, exists bool) {
+	if value, exists = s.MapPropertySource.GetProperty(key); exists || len(s.prefix) < 1 {
+		return
+	}
+	return s.MapPropertySource.GetProperty(strings.ToLower(key))
+}
+
+func (s *SystemEnvironmentPropertySource) GetPropertyWithDef(key string, def string) string {
+	if value, exists := s.GetProperty(key); exists {
+		return value
+	}
+	return def
+}
+
+func (s *SystemEnvironmentPropertySource) GetPropertyOrigin(key string) (origin string, exists bool) {
+	if origin, exists = s.MapPropertySource.GetPropertyOrigin(key); exists || len(s.prefix) < 1 {
+		return
+	}
+	return s.MapPropertySource.GetPropertyOrigin(strings.ToLower(key))
+}
+
+/**
+解析 os.Environ() 中的 name=value，按照第一个 = 拆分，值中可以包含 =，变量名以及值去掉首尾空白，和之前的版本保持一致；
+Windows 下存在 =C:=C:\ 这种 = 开头的变量，所以从第二个字符开始查找
+*/
+func parseEnvironmentVariable(kv string) (name string, value string) {
+	index := -1
+	if len(kv) > 1 {
+		if i := strings.Index(kv[1:], "="); i >= 0 {
+			index = i + 1
+		}
+	}
+	if index < 0 {
+		return strings.TrimSpace(kv), ""
+	}
+	return strings.TrimSpace(kv[:index]), strings.TrimSpace(kv[index+1:])
+}
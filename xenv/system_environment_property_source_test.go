@@ -2,6 +2,8 @@ package xenv
 
 import (
 	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
 )
 
@@ -14,3 +16,103 @@ func TestNewSystemEnvironmentPropertySource(t *testing.T) {
 		return false
 	})
 }
+
+func TestParseEnvironmentVariable(t *testing.T) {
+	name, value := parseEnvironmentVariable("JAVA_OPTS=-Da=b -Dc=d")
+	assert.Equal(t, "JAVA_OPTS", name)
+	assert.Equal(t, "-Da=b -Dc=d", value)
+
+	name, value = parseEnvironmentVariable("EMPTY=")
+	assert.Equal(t, "EMPTY", name)
+	assert.Equal(t, "", value)
+
+	name, value = parseEnvironmentVariable("=C:=C:\\")
+	assert.Equal(t, "=C:", name)
+	assert.Equal(t, "C:\\", value)
+
+	name, value = parseEnvironmentVariable("NO_VALUE")
+	assert.Equal(t, "NO_VALUE", name)
+	assert.Equal(t, "", value)
+
+	// 变量名以及值去掉首尾空白
+	name, value = parseEnvironmentVariable(" PADDED = a=b ")
+	assert.Equal(t, "PADDED", name)
+	assert.Equal(t, "a=b", value)
+}
+
+func TestNewPrefixedSystemEnvironmentPropertySource(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"MYAPP_SERVER_PORT=8080",
+		"MYAPP_DB__URL=mysql://host/db?a=b",
+		"MYAPP_APP_MINVERSION=1.2",
+		"MYAPP_SERVERS__0__HOST=a",
+		"MYAPP_SERVER__MAX_SIZE=10",
+		"MYAPP_=ignored",
+	}
+	kvsOf := func(source PropertySource) map[string]string {
+		kvs := make(map[string]string)
+		source.Each(func(key string, value string) (stop bool) {
+			kvs[key] = value
+			return false
+		})
+		return kvs
+	}
+
+	source := newSystemEnvironmentPropertySource(environ, "MYAPP_", nil)
+	assert.Equal(t, map[string]string{
+		"server.port":     "8080",
+		"db.url":          "mysql://host/db?a=b",
+		"app.minversion":  "1.2",
+		"servers.0.host":  "a",
+		"server.max.size": "10",
+	}, kvsOf(source))
+	assert.Equal(t, "MYAPP_SERVER_PORT", GetPropertyOrigin(source, "server.port"))
+	// 获取配置的时候忽略大小写
+	value, exists := source.GetProperty("app.minVersion")
+	assert.True(t, exists)
+	assert.Equal(t, "1.2", value)
+	assert.Equal(t, "MYAPP_APP_MINVERSION", GetPropertyOrigin(source, "app.minVersion"))
+
+	// 显式指定支持 - 以及数组下标的转换规则
+	source = newSystemEnvironmentPropertySource(environ, "MYAPP_", IndexedEnvironmentVariableMapper)
+	assert.Equal(t, map[string]string{
+		"server-port":     "8080",
+		"db.url":          "mysql://host/db?a=b",
+		"app-minversion":  "1.2",
+		"servers[0].host": "a",
+		"server.max-size": "10",
+	}, kvsOf(source))
+
+	source = newSystemEnvironmentPropertySource([]string{"PATH=/usr/bin", "A=b=c"}, "", nil)
+	value, _ = source.GetProperty("A")
+	assert.Equal(t, "b=c", value)
+	value, _ = source.GetProperty("PATH")
+	assert.Equal(t, "/usr/bin", value)
+	_, exists = source.GetProperty("path")
+	assert.False(t, exists)
+}
+
+type SystemEnvironmentConfig struct {
+	Port       int    `ck:"port"`
+	MinVersion string `ck:"minVersion"`
+}
+
+func TestStandardEnvironment_SystemEnvironmentPrefix(t *testing.T) {
+	assert.Nil(t, os.Setenv("XENVTEST_SERVER_PORT", "9090"))
+	defer os.Unsetenv("XENVTEST_SERVER_PORT")
+	assert.Nil(t, os.Setenv("XENVTEST_SERVER_MINVERSION", "1.2"))
+	defer os.Unsetenv("XENVTEST_SERVER_MINVERSION")
+
+	env := New(IgnoreCommandLine(), IgnoreConfigFiles(), DisableXlogInit(), SystemEnvironmentPrefix("XENVTEST_"))
+	assert.Equal(t, "9090", env.GetPropertyWithDef("server.port", ""))
+	assert.False(t, env.ContainsProperty("PATH"))
+	cfg := &SystemEnvironmentConfig{}
+	_, err := env.BindProperties("server.", cfg, false)
+	assert.Nil(t, err)
+	assert.Equal(t, &SystemEnvironmentConfig{Port: 9090, MinVersion: "1.2"}, cfg)
+
+	env = New(IgnoreCommandLine(), IgnoreConfigFiles(), DisableXlogInit(), SystemEnvironmentPrefix("XENVTEST_"),
+		SystemEnvironmentKeyMapper(IndexedEnvironmentVariableMapper))
+	assert.Equal(t, "9090", env.GetPropertyWithDef("server-port", ""))
+}
@@ -0,0 +1,282 @@
+package xenv
+
+import (
+	"bytes"
+	"github.com/xkgo/xkit/xlog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// 每个未知配置项最多给出的建议数
+	maxUnknownKeySuggestions = 3
+)
+
+/**
+前缀下没有匹配到任何配置 Bean 属性的配置项，一般是配置项拼写错误
+*/
+type UnknownKey struct {
+	Key         string   // 配置 key
+	Origin      string   // 配置来源，参考 GetPropertyOrigin
+	Suggestions []string // 编辑距离最近的已知配置 key，可能为空
+}
+
+func (k *UnknownKey) String() string {
+	text := "未知配置项[" + k.Key + "], 来源：[" + k.Origin + "]"
+	if len(k.Suggestions) > 0 {
+		text += ", 是否是：" + strings.Join(k.Suggestions, ", ")
+	}
+	return text
+}
+
+/**
+已经绑定的配置 Bean 前缀下存在未知配置项，FailOnUnknownKeys 的时候 CheckAllUnknownKeys 返回这个异常
+*/
+type UnknownKeysError struct {
+	KeyPrefixes []string // 存在未知配置项的配置 Bean 前缀，按照字典序排序
+	UnknownKeys []*UnknownKey
+}
+
+func (e *UnknownKeysError) Error() string {
+	buf := bytes.Buffer{}
+	buf.WriteString("配置前缀[" + strings.Join(e.KeyPrefixes, ", ") + "]下存在未知配置项：")
+	for _, key := range e.UnknownKeys {
+		buf.WriteString("\n\t" + key.String())
+	}
+	return buf.String()
+}
+
+/**
+所有配置 Bean 绑定完成之后调用 CheckAllUnknownKeys，存在未知配置项的话返回 UnknownKeysError，
+默认只打印告警日志；可以用于启动的时候发现拼写错误的配置项
+*/
+func FailOnUnknownKeys() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.failOnUnknownKeys = true
+	}
+}
+
+/**
+检查配置前缀下没有匹配到配置 Bean 任何属性的配置项，按照 key 排序；
+前缀下属于其他已经绑定的配置 Bean 的配置项不算未知配置项，比如分别绑定在 app. 和 app.db. 下的两个配置 Bean
+@param keyPrefix 配置前缀，和 BindProperties 的 keyPrefix 一致，为空的话不检查
+@param cfg 配置 Bean 指针、配置 Bean 或者 reflect.Type
+*/
+func (s *StandardEnvironment) CheckUnknownKeys(keyPrefix string, cfg interface{}) []*UnknownKey {
+	unknownKeys := make([]*UnknownKey, 0)
+	if len(keyPrefix) < 1 {
+		return unknownKeys
+	}
+
+	knownKeys, patterns := s.knownConfigKeys(keyPrefix, cfg)
+	for _, bean := range s.boundBeans {
+		if !strings.HasPrefix(bean.keyPrefix, keyPrefix) && !strings.HasPrefix(keyPrefix, bean.keyPrefix) {
+			continue
+		}
+		beanKeys, beanPatterns := s.knownConfigKeys(bean.keyPrefix, bean.beanPtr)
+		for key := range beanKeys {
+			knownKeys[key] = true
+		}
+		patterns = append(patterns, beanPatterns...)
+	}
+	s.propertySources.Each(func(index int, source PropertySource) (stop bool) {
+		source.Each(func(key, value string) (stop bool) {
+			if !strings.HasPrefix(key, keyPrefix) || knownKeys[key] {
+				return false
+			}
+			for _, pattern := range patterns {
+				if pattern.MatchString(key) {
+					return false
+				}
+			}
+			// 同一个 key 只记录优先级最高的来源
+			knownKeys[key] = true
+			unknownKeys = append(unknownKeys, &UnknownKey{Key: key, Origin: GetPropertyOrigin(source, key)})
+			return false
+		})
+		return false
+	})
+
+	candidates := ConfigFieldKeys(keyPrefix, cfg)
+	for _, unknownKey := range unknownKeys {
+		unknownKey.Suggestions = suggestKeys(unknownKey.Key, candidates)
+	}
+	sort.Slice(unknownKeys, func(i, j int) bool {
+		return unknownKeys[i].Key < unknownKeys[j].Key
+	})
+	return unknownKeys
+}
+
+/**
+配置 Bean 能够匹配的配置 key，包括废弃的旧 key；展开的 map 无法确定具体 key，返回匹配的正则
+*/
+func (s *StandardEnvironment) knownConfigKeys(keyPrefix string, cfg interface{}) (knownKeys map[string]bool, patterns []*regexp.Regexp) {
+	knownKeys = make(map[string]bool)
+	patterns = make([]*regexp.Regexp, 0)
+	addKey := func(key string) {
+		if strings.Contains(key, MapKeyPlaceholder) {
+			expr := strings.Replace(regexp.QuoteMeta(key), regexp.QuoteMeta(MapKeyPlaceholder), "[^.]+", -1)
+			patterns = append(patterns, regexp.MustCompile("^"+expr+"$"))
+			return
+		}
+		knownKeys[key] = true
+		for _, info := range s.deprecatedKeys.oldKeys(key) {
+			knownKeys[info.OldKey] = true
+		}
+	}
+
+	var walk func(fields []*ConfigField)
+	walk = func(fields []*ConfigField) {
+		for _, field := range fields {
+			if field.IsMap && len(field.Children) < 1 {
+				// map 的 value 不是 struct，前缀下的所有配置项都属于这个 map
+				patterns = append(patterns, regexp.MustCompile("^"+regexp.QuoteMeta(field.Key+".")))
+				continue
+			}
+			if field.IsLeaf() {
+				addKey(field.Key)
+				if isListType(field.Type) {
+					// 数组、切片支持 key[0]、key[0].name 形式的配置
+					patterns = append(patterns, regexp.MustCompile("^"+regexp.QuoteMeta(field.Key)+`\[\d+\]`))
+				}
+				continue
+			}
+			walk(field.Children)
+		}
+	}
+	walk(ResolveConfigFields(keyPrefix, cfg))
+	return
+}
+
+/**
+根据编辑距离给出建议的配置 key，距离不超过 key 长度的三分之一（至少为 2），按照距离、字典序排序
+*/
+func suggestKeys(key string, candidates []string) []string {
+	maxDistance := len(key) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	type suggestion struct {
+		key      string
+		distance int
+	}
+	suggestions := make([]suggestion, 0)
+	for _, candidate := range candidates {
+		if strings.Contains(candidate, MapKeyPlaceholder) {
+			if candidate = fillMapKeyPlaceholder(candidate, key); len(candidate) < 1 {
+				continue
+			}
+		}
+		if distance := editDistance(key, candidate); distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{key: candidate, distance: distance})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].key < suggestions[j].key
+	})
+
+	keys := make([]string, 0)
+	for i := 0; i < len(suggestions) && i < maxUnknownKeySuggestions; i++ {
+		keys = append(keys, suggestions[i].key)
+	}
+	return keys
+}
+
+/**
+使用 key 中对应位置的 map key 替换候选 key 中的 MapKeyPlaceholder，层级数不一致的话返回空
+*/
+func fillMapKeyPlaceholder(candidate, key string) string {
+	candidateParts := strings.Split(candidate, ".")
+	keyParts := strings.Split(key, ".")
+	if len(candidateParts) != len(keyParts) {
+		return ""
+	}
+	for i, part := range candidateParts {
+		if part == MapKeyPlaceholder {
+			candidateParts[i] = keyParts[i]
+		}
+	}
+	return strings.Join(candidateParts, ".")
+}
+
+/**
+Levenshtein 编辑距离，忽略大小写
+*/
+func editDistance(a, b string) int {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
+
+/**
+检查所有已经绑定的配置 Bean 前缀下的未知配置项，需要在所有配置 Bean 绑定完成之后调用，结果和绑定顺序无关，
+比如先绑定 app. 再绑定 app.db. 的时候，app.db. 下的配置项不会被认为是 app. 下的未知配置项；
+同一个未知配置项只记录一次，建议使用前缀最长的配置 Bean 给出的建议。
+存在未知配置项的话，FailOnUnknownKeys 返回 UnknownKeysError，否则打印告警日志并返回 nil
+*/
+func (s *StandardEnvironment) CheckAllUnknownKeys() error {
+	beans := append([]*boundBean{}, s.boundBeans...)
+	sort.SliceStable(beans, func(i, j int) bool {
+		return len(beans[i].keyPrefix) > len(beans[j].keyPrefix)
+	})
+
+	prefixes := make(map[string]bool)
+	found := make(map[string]bool)
+	unknownKeys := make([]*UnknownKey, 0)
+	for _, bean := range beans {
+		for _, unknownKey := range s.CheckUnknownKeys(bean.keyPrefix, bean.beanPtr) {
+			prefixes[bean.keyPrefix] = true
+			if !found[unknownKey.Key] {
+				found[unknownKey.Key] = true
+				unknownKeys = append(unknownKeys, unknownKey)
+			}
+		}
+	}
+	if len(unknownKeys) < 1 {
+		return nil
+	}
+
+	err := &UnknownKeysError{KeyPrefixes: make([]string, 0, len(prefixes)), UnknownKeys: unknownKeys}
+	for prefix := range prefixes {
+		err.KeyPrefixes = append(err.KeyPrefixes, prefix)
+	}
+	sort.Strings(err.KeyPrefixes)
+	sort.Slice(unknownKeys, func(i, j int) bool {
+		return unknownKeys[i].Key < unknownKeys[j].Key
+	})
+	if s.options.failOnUnknownKeys {
+		return err
+	}
+	xlog.Warn(err.Error())
+	return nil
+}
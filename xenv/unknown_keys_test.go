@@ -0,0 +1,146 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type UnknownKeysConfig struct {
+	MaxSize  int                        `ck:"max-size"`
+	Path     string                     `ck:"path"`
+	Backends map[string]*UnknownBackend `ck:"backends" expand:"true"`
+}
+
+type UnknownBackend struct {
+	Host string `ck:"host"`
+}
+
+type UnknownAppConfig struct {
+	Name string `ck:"name"`
+}
+
+type UnknownDbConfig struct {
+	Host string `ck:"host"`
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("max-size", "MAX-SIZE"))
+	assert.Equal(t, 1, editDistance("max-sise", "max-size"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.Equal(t, 4, editDistance("", "path"))
+}
+
+func TestStandardEnvironment_CheckUnknownKeys(t *testing.T) {
+	newEnv := func(options ...Option) *StandardEnvironment {
+		sources := NewMutablePropertySources()
+		sources.AddLast(NewMapPropertySource("test", map[string]string{
+			"app.max-sise":        "100",
+			"app.path":            "/tmp",
+			"app.backends.a.host": "127.0.0.1",
+			"app.backends.a.hots": "127.0.0.2",
+			"app.old-path":        "/var",
+			"other.max-sise":      "1",
+		}))
+		options = append(options, IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(),
+			AdditionalPropertySources(sources), DeprecatedKey("app.old-path", "app.path", "1.0.0"))
+		return New(options...)
+	}
+
+	env := newEnv()
+	unknownKeys := env.CheckUnknownKeys("app.", &UnknownKeysConfig{})
+	assert.Equal(t, 2, len(unknownKeys))
+	assert.Equal(t, "app.backends.a.hots", unknownKeys[0].Key)
+	assert.Equal(t, []string{"app.backends.a.host"}, unknownKeys[0].Suggestions)
+	assert.Equal(t, "app.max-sise", unknownKeys[1].Key)
+	assert.Equal(t, "test", unknownKeys[1].Origin)
+	assert.Equal(t, []string{"app.max-size"}, unknownKeys[1].Suggestions)
+
+	// 默认只告警
+	cfg := &UnknownKeysConfig{}
+	_, err := env.BindProperties("app.", cfg, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp", cfg.Path)
+	assert.Nil(t, env.CheckAllUnknownKeys())
+
+	// 严格模式返回异常
+	env = newEnv(FailOnUnknownKeys())
+	_, err = env.BindProperties("app.", &UnknownKeysConfig{}, false)
+	assert.Nil(t, err)
+	err = env.CheckAllUnknownKeys()
+	assert.NotNil(t, err)
+	unknownKeysError, ok := err.(*UnknownKeysError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"app."}, unknownKeysError.KeyPrefixes)
+	assert.Equal(t, 2, len(unknownKeysError.UnknownKeys))
+	assert.Contains(t, err.Error(), "未知配置项[app.max-sise], 来源：[test], 是否是：app.max-size")
+}
+
+func TestStandardEnvironment_CheckUnknownKeysOverlappingPrefix(t *testing.T) {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", map[string]string{
+		"app.name":    "demo",
+		"app.db.host": "127.0.0.1",
+		"app.db.hots": "127.0.0.2",
+	}))
+	env := New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+
+	dbCfg := &UnknownDbConfig{}
+	_, err := env.BindProperties("app.db.", dbCfg, false)
+	assert.Nil(t, err)
+	appCfg := &UnknownAppConfig{}
+	_, err = env.BindProperties("app.", appCfg, false)
+	assert.Nil(t, err)
+
+	// app.db. 下的配置项属于另外一个配置 Bean，只有拼写错误的才是未知配置项
+	unknownKeys := env.CheckUnknownKeys("app.", appCfg)
+	assert.Equal(t, 1, len(unknownKeys))
+	assert.Equal(t, "app.db.hots", unknownKeys[0].Key)
+	unknownKeys = env.CheckUnknownKeys("app.db.", dbCfg)
+	assert.Equal(t, 1, len(unknownKeys))
+	assert.Equal(t, "app.db.hots", unknownKeys[0].Key)
+	assert.Equal(t, []string{"app.db.host"}, unknownKeys[0].Suggestions)
+
+	// 解除绑定之后就不再属于其他配置 Bean 了
+	env.UnbindProperties(dbCfg)
+	assert.Equal(t, 2, len(env.CheckUnknownKeys("app.", appCfg)))
+}
+
+func TestStandardEnvironment_CheckAllUnknownKeysBindOrder(t *testing.T) {
+	newEnv := func(properties map[string]string) *StandardEnvironment {
+		sources := NewMutablePropertySources()
+		sources.AddLast(NewMapPropertySource("test", properties))
+		return New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(),
+			AdditionalPropertySources(sources), FailOnUnknownKeys())
+	}
+	bind := func(env *StandardEnvironment, dbFirst bool) {
+		prefixes := []string{"app.", "app.db."}
+		if dbFirst {
+			prefixes = []string{"app.db.", "app."}
+		}
+		for _, prefix := range prefixes {
+			var cfg interface{} = &UnknownAppConfig{}
+			if prefix == "app.db." {
+				cfg = &UnknownDbConfig{}
+			}
+			_, err := env.BindProperties(prefix, cfg, false)
+			assert.Nil(t, err)
+		}
+	}
+
+	// 两种绑定顺序的结果一致
+	for _, dbFirst := range []bool{true, false} {
+		env := newEnv(map[string]string{"app.name": "demo", "app.db.host": "127.0.0.1"})
+		bind(env, dbFirst)
+		assert.Nil(t, env.CheckAllUnknownKeys(), "dbFirst: %v", dbFirst)
+
+		env = newEnv(map[string]string{"app.name": "demo", "app.db.host": "127.0.0.1", "app.db.hots": "127.0.0.2"})
+		bind(env, dbFirst)
+		err := env.CheckAllUnknownKeys()
+		unknownKeysError, ok := err.(*UnknownKeysError)
+		assert.True(t, ok, "dbFirst: %v", dbFirst)
+		assert.Equal(t, []string{"app.", "app.db."}, unknownKeysError.KeyPrefixes)
+		assert.Equal(t, 1, len(unknownKeysError.UnknownKeys))
+		assert.Equal(t, "app.db.hots", unknownKeysError.UnknownKeys[0].Key)
+		assert.Equal(t, []string{"app.db.host"}, unknownKeysError.UnknownKeys[0].Suggestions)
+	}
+}
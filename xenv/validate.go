@@ -0,0 +1,159 @@
+package xenv
+
+import (
+	"bytes"
+	"github.com/xkgo/xkit/xlog"
+	"github.com/xkgo/xkit/xplaceholder"
+	"strings"
+)
+
+/**
+配置校验发现的问题
+*/
+type ValidationIssue struct {
+	Key    string   // 配置 key
+	Origin string   // 配置来源，参考 GetPropertyOrigin
+	Value  string   // 配置原始值，敏感配置脱敏
+	Cycle  []string // 循环引用的完整路径，首尾相同，比如 [a b a]，只有循环引用才有
+	Err    error    // *xplaceholder.UnresolvablePlaceholderError 或者 *xplaceholder.CircularPlaceholderError，值为空的话为 nil
+}
+
+func (i *ValidationIssue) String() string {
+	text := "配置项[" + i.Key + "]的值[" + i.Value + "], 来源：[" + i.Origin + "]"
+	if len(i.Cycle) > 0 {
+		return text + ", 循环引用：" + strings.Join(i.Cycle, " -> ")
+	}
+	if i.Err != nil {
+		return text + ", err:" + i.Err.Error()
+	}
+	return text
+}
+
+/**
+配置校验报告，汇总所有配置来源中的问题，参考 Validate
+*/
+type ValidationReport struct {
+	Unresolvable []*ValidationIssue // 无法识别的占位符，即占位符对应的配置项不存在并且没有默认值
+	Circular     []*ValidationIssue // 循环引用，同一个循环只报告一次
+	Empty        []*ValidationIssue // 包含占位符，处理之后为空的配置项
+}
+
+/**
+是否存在问题
+*/
+func (r *ValidationReport) HasIssues() bool {
+	return len(r.Unresolvable)+len(r.Circular)+len(r.Empty) > 0
+}
+
+func (r *ValidationReport) Error() string {
+	buf := bytes.Buffer{}
+	buf.WriteString("配置校验失败：")
+	writeIssues := func(title string, issues []*ValidationIssue) {
+		if len(issues) < 1 {
+			return
+		}
+		buf.WriteString("\n" + title + "：")
+		for _, issue := range issues {
+			buf.WriteString("\n\t" + issue.String())
+		}
+	}
+	writeIssues("无法识别的占位符", r.Unresolvable)
+	writeIssues("占位符循环引用", r.Circular)
+	writeIssues("配置值为空", r.Empty)
+	return buf.String()
+}
+
+/**
+New 的时候校验所有配置项，存在问题则 panic，panic 的值为 *ValidationReport；
+默认不校验，可以在启动的时候通过 Validate 自行校验
+*/
+func FailOnValidationIssues() Option {
+	return func(environment *StandardEnvironment) {
+		environment.options.failOnValidationIssues = true
+	}
+}
+
+/**
+校验所有配置来源中的所有配置项（包括被覆盖的配置项），处理全部 ${...} 占位符，汇总无法识别的占位符、循环引用以及处理之后为空的配置项；
+没有指定前缀的系统环境变量配置来源包含大量和应用无关的变量，不校验，参考 SystemEnvironmentPrefix；
+报告中的配置项按照配置来源的优先级以及遍历顺序排列
+*/
+func (s *StandardEnvironment) Validate() *ValidationReport {
+	report := &ValidationReport{
+		Unresolvable: make([]*ValidationIssue, 0),
+		Circular:     make([]*ValidationIssue, 0),
+		Empty:        make([]*ValidationIssue, 0),
+	}
+	cycles := make(map[string]bool)
+	s.propertySources.Each(func(index int, source PropertySource) (stop bool) {
+		if systemEnvironment, ok := source.(*SystemEnvironmentPropertySource); ok && len(systemEnvironment.prefix) < 1 {
+			return false
+		}
+		source.Each(func(key, value string) (stop bool) {
+			issue := &ValidationIssue{Key: key, Origin: GetPropertyOrigin(source, key), Value: s.MaskPropertyValue(key, value)}
+			resolved, err := s.ResolveRequiredPlaceholdersE(value)
+			switch e := err.(type) {
+			case *xplaceholder.CircularPlaceholderError:
+				issue.Cycle = placeholderCycle(e)
+				if cycle := canonicalPlaceholderCycle(issue.Cycle); !cycles[cycle] {
+					cycles[cycle] = true
+					issue.Err = e
+					report.Circular = append(report.Circular, issue)
+				}
+			case nil:
+				// 原始值为空一般是有意为之，只报告占位符处理之后为空的
+				if resolved != value && len(strings.TrimSpace(resolved)) < 1 {
+					report.Empty = append(report.Empty, issue)
+				}
+			default:
+				issue.Err = e
+				report.Unresolvable = append(report.Unresolvable, issue)
+			}
+			return false
+		})
+		return false
+	})
+	return report
+}
+
+/**
+从占位符链中截取循环的部分，比如 [x a b a] 截取为 [a b a]
+*/
+func placeholderCycle(err *xplaceholder.CircularPlaceholderError) []string {
+	for i, placeholder := range err.Chain {
+		if placeholder == err.Placeholder {
+			return err.Chain[i:]
+		}
+	}
+	return err.Chain
+}
+
+/**
+循环的唯一标识，从不同的配置项开始得到的同一个循环标识相同，比如 [a b a] 和 [b a b]
+*/
+func canonicalPlaceholderCycle(cycle []string) string {
+	if len(cycle) < 2 {
+		return strings.Join(cycle, "\n")
+	}
+	nodes := cycle[:len(cycle)-1]
+	start := 0
+	for i, node := range nodes {
+		if node < nodes[start] {
+			start = i
+		}
+	}
+	return strings.Join(append(append([]string{}, nodes[start:]...), nodes[:start]...), "\n")
+}
+
+/**
+New 的时候根据 FailOnValidationIssues 校验所有配置项
+*/
+func (s *StandardEnvironment) validateOnStartup() {
+	if !s.options.failOnValidationIssues {
+		return
+	}
+	if report := s.Validate(); report.HasIssues() {
+		xlog.Error(report.Error())
+		panic(report)
+	}
+}
@@ -0,0 +1,97 @@
+package xenv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xkgo/xkit/xplaceholder"
+	"testing"
+)
+
+func newValidateTestEnvironment(properties map[string]string, options ...Option) *StandardEnvironment {
+	sources := NewMutablePropertySources()
+	sources.AddLast(NewMapPropertySource("test", properties))
+	options = append(options, IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources))
+	return New(options...)
+}
+
+func TestStandardEnvironment_Validate(t *testing.T) {
+	env := newValidateTestEnvironment(map[string]string{
+		"server.host":     "127.0.0.1",
+		"server.url":      "http://${server.host}:${server.port}",
+		"server.name":     "${app.name:demo}",
+		"app.a":           "a-${app.b}",
+		"app.b":           "b-${app.c}",
+		"app.c":           "${app.a}",
+		"app.self":        "${app.self}",
+		"app.description": "${app.blank:}",
+		"app.password":    "${app.secret}",
+	})
+
+	report := env.Validate()
+	assert.True(t, report.HasIssues())
+
+	unresolvable := make(map[string]*ValidationIssue)
+	for _, issue := range report.Unresolvable {
+		unresolvable[issue.Key] = issue
+	}
+	assert.Equal(t, 2, len(unresolvable))
+	assert.Equal(t, "server.port", unresolvable["server.url"].Err.(*xplaceholder.UnresolvablePlaceholderError).Placeholder)
+	assert.Equal(t, "test", unresolvable["server.url"].Origin)
+	assert.Equal(t, "******", unresolvable["app.password"].Value)
+
+	// 同一个循环只报告一次
+	assert.Equal(t, 2, len(report.Circular))
+	cycles := make([][]string, 0)
+	for _, issue := range report.Circular {
+		cycles = append(cycles, issue.Cycle)
+	}
+	assert.Contains(t, cycles, []string{"app.self", "app.self"})
+	for _, cycle := range cycles {
+		if len(cycle) == 4 {
+			assert.Equal(t, cycle[0], cycle[3])
+			assert.ElementsMatch(t, []string{"app.a", "app.b", "app.c"}, cycle[:3])
+		}
+	}
+
+	assert.Equal(t, 1, len(report.Empty))
+	assert.Equal(t, "app.description", report.Empty[0].Key)
+	assert.Contains(t, report.Error(), "app.self -> app.self")
+
+	report = newValidateTestEnvironment(map[string]string{"server.url": "http://${server.host}", "server.host": "127.0.0.1"}).Validate()
+	assert.False(t, report.HasIssues())
+}
+
+func TestFailOnValidationIssues(t *testing.T) {
+	assert.NotPanics(t, func() {
+		newValidateTestEnvironment(map[string]string{"server.url": "http://${server.host}"})
+	})
+	defer func() {
+		report, ok := recover().(*ValidationReport)
+		assert.True(t, ok)
+		assert.Equal(t, "server.url", report.Unresolvable[0].Key)
+	}()
+	newValidateTestEnvironment(map[string]string{"server.url": "http://${server.host}"}, FailOnValidationIssues())
+	t.Fatal("存在无法识别的占位符，应该 panic")
+}
+
+func TestStandardEnvironment_ValidateSystemEnvironment(t *testing.T) {
+	newEnv := func(prefix string) *StandardEnvironment {
+		sources := NewMutablePropertySources()
+		sources.AddLast(newSystemEnvironmentPropertySource([]string{"LESSOPEN=| ${LESSPIPE} %s", "MYAPP_SERVER_URL=${server.host}"}, prefix, nil))
+		return New(IgnoreCommandLine(), IgnoreSystemEnvironment(), IgnoreConfigFiles(), DisableXlogInit(), AdditionalPropertySources(sources), FailOnValidationIssues())
+	}
+
+	// 没有指定前缀的系统环境变量不校验
+	assert.NotPanics(t, func() {
+		assert.False(t, newEnv("").Validate().HasIssues())
+	})
+
+	// 指定了前缀的系统环境变量都是应用的配置，需要校验
+	defer func() {
+		report, ok := recover().(*ValidationReport)
+		assert.True(t, ok)
+		assert.Equal(t, "server.url", report.Unresolvable[0].Key)
+		assert.Equal(t, "MYAPP_SERVER_URL", report.Unresolvable[0].Origin)
+	}()
+	newEnv("MYAPP_")
+	t.Fatal("存在无法识别的占位符，应该 panic")
+}
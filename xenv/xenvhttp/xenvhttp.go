@@ -0,0 +1,404 @@
+/**
+xenvhttp 提供查看配置、运行时覆盖配置的管理接口，路径前缀默认为 /env：
+	> GET    /env                当前生效的所有配置项，敏感配置脱敏，可以通过 ?prefix= 过滤
+	> GET    /env/{key}          配置项的生效值、来源，以及被覆盖的其他配置来源中的值
+	> GET    /env/sources        按照优先级排序的配置来源
+	> GET    /env/overrides      运行时覆盖的配置项
+	> POST   /env/overrides      覆盖配置项，body 为 {"key": "value"}
+	> DELETE /env/overrides      删除覆盖的配置项，?key= 可以指定多个，删除全部需要显式指定 ?all=true
+	> POST   /env/overrides/rollback?seq=  将覆盖的配置项回滚到审计记录 seq 对应的时间点，参考 xenv.StandardEnvironment.RollbackRuntimeOverrides
+	> GET    /env/audit          配置变更审计记录，参考 xenv.StandardEnvironment.AuditLog
+覆盖的配置项保存在优先级最高的 OverridePropertySourceName 配置来源中，会触发真实的配置变更事件，
+请求头中的 TraceIdHeader 会作为调用链 ID 记录到审计记录中；所有接口默认都需要通过 Authorize 设置鉴权钩子才能使用，
+只读接口可以通过 AllowUnauthenticatedReads 显式开放；
+sources、overrides、audit 为保留路径，无法通过 /env/{key} 查询同名的配置项
+*/
+package xenvhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/xkgo/xkit/xcontext"
+	"github.com/xkgo/xkit/xenv"
+	"github.com/xkgo/xkit/xlog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 运行时覆盖配置的配置来源名称，优先级最高，和 xenv.StandardEnvironment.RuntimeOverrides 使用同一个配置来源
+	OverridePropertySourceName = xenv.RuntimeOverridePropertySourceName
+	// 修改配置的请求中携带调用链 ID 的请求头
+	TraceIdHeader = "X-Trace-Id"
+	// 默认的路径前缀
+	DefaultPathPrefix = "/env"
+)
+
+/**
+鉴权钩子，返回 error 的话拒绝请求，响应 403
+*/
+type Authorizer func(r *http.Request) error
+
+/**
+支持审计以及回滚的环境，xenv.StandardEnvironment 实现了这个接口
+*/
+type AuditableEnvironment interface {
+	AuditLog() []*xenv.AuditEntry
+	RollbackRuntimeOverrides(seq int64) ([]string, error)
+}
+
+/**
+Handler 选项
+*/
+type Option func(h *Handler)
+
+/**
+路径前缀，默认为 DefaultPathPrefix
+*/
+func PathPrefix(prefix string) Option {
+	return func(h *Handler) {
+		h.prefix = "/" + strings.Trim(prefix, "/")
+	}
+}
+
+/**
+设置鉴权钩子，所有请求都会先经过鉴权，可以根据 r.Method 区分只读和修改操作；
+没有设置鉴权钩子的话所有请求一律响应 403，参考 AllowUnauthenticatedReads
+*/
+func Authorize(authorizer Authorizer) Option {
+	return func(h *Handler) {
+		h.authorizer = authorizer
+	}
+}
+
+/**
+没有设置鉴权钩子的时候允许 GET、HEAD 请求，查看的配置会脱敏，但是仍然会暴露配置 key、配置来源等信息，
+只应该在管理端口不对外开放的时候使用；修改配置的请求仍然需要鉴权钩子
+*/
+func AllowUnauthenticatedReads() Option {
+	return func(h *Handler) {
+		h.allowUnauthenticatedReads = true
+	}
+}
+
+/**
+配置管理接口
+*/
+type Handler struct {
+	env                       xenv.Environment
+	prefix                    string
+	authorizer                Authorizer
+	allowUnauthenticatedReads bool // 没有鉴权钩子的时候是否允许只读请求
+	overrides                 *xenv.MapPropertySource
+}
+
+/**
+创建配置管理接口，环境中不存在 OverridePropertySourceName 配置来源的话添加到最前面；
+同名的配置来源不是 *xenv.MapPropertySource 的话 panic
+*/
+func NewHandler(env xenv.Environment, options ...Option) *Handler {
+	h := &Handler{env: env, prefix: DefaultPathPrefix}
+	for _, option := range options {
+		option(h)
+	}
+
+	if provider, ok := env.(interface {
+		RuntimeOverrides() *xenv.MapPropertySource
+	}); ok {
+		h.overrides = provider.RuntimeOverrides()
+		return h
+	}
+	sources := env.GetPropertySources()
+	if source, exists := sources.Get(OverridePropertySourceName); exists {
+		overrides, ok := source.(*xenv.MapPropertySource)
+		if !ok {
+			panic("配置来源[" + OverridePropertySourceName + "]必须是 *xenv.MapPropertySource 类型，实际为：" + reflect.TypeOf(source).String())
+		}
+		h.overrides = overrides
+	} else {
+		h.overrides = xenv.NewMapPropertySource(OverridePropertySourceName, nil)
+		sources.AddFirst(h.overrides)
+	}
+	return h
+}
+
+/**
+运行时覆盖配置的配置来源
+*/
+func (h *Handler) Overrides() *xenv.MapPropertySource {
+	return h.overrides
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path != h.prefix && !strings.HasPrefix(path, h.prefix+"/") {
+		writeError(w, http.StatusNotFound, errors.New("not found: "+r.URL.Path))
+		return
+	}
+	if h.authorizer != nil {
+		if err := h.authorizer(r); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+	} else if !h.allowUnauthenticatedReads || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		writeError(w, http.StatusForbidden, errors.New("没有设置鉴权钩子，不允许访问，参考 Authorize、AllowUnauthenticatedReads"))
+		return
+	}
+
+	switch subPath := strings.TrimPrefix(strings.TrimPrefix(path, h.prefix), "/"); subPath {
+	case "":
+		h.allowMethods(w, r, map[string]http.HandlerFunc{http.MethodGet: h.handleProperties})
+	case "sources":
+		h.allowMethods(w, r, map[string]http.HandlerFunc{http.MethodGet: h.handleSources})
+	case "overrides":
+		h.allowMethods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:    h.handleGetOverrides,
+			http.MethodPost:   h.withTraceId(h.handlePutOverrides),
+			http.MethodDelete: h.withTraceId(h.handleDeleteOverrides),
+		})
+	case "overrides/rollback":
+		h.allowMethods(w, r, map[string]http.HandlerFunc{http.MethodPost: h.withTraceId(h.handleRollback)})
+	case "audit":
+		h.allowMethods(w, r, map[string]http.HandlerFunc{http.MethodGet: h.handleAudit})
+	default:
+		h.allowMethods(w, r, map[string]http.HandlerFunc{http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+			h.handleProperty(w, r, subPath)
+		}})
+	}
+}
+
+func (h *Handler) allowMethods(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	if handler, ok := handlers[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed: "+r.Method))
+}
+
+/**
+配置项的值以及来源
+*/
+type PropertyValue struct {
+	Source string `json:"source"`           // 配置来源名称
+	Origin string `json:"origin,omitempty"` // 配置项的具体来源，比如所在文件，参考 xenv.GetPropertyOrigin
+	Value  string `json:"value"`            // 替换占位符之后的值，敏感配置脱敏
+}
+
+/**
+GET /env/{key} 的响应
+*/
+type PropertyDetail struct {
+	Key string `json:"key"`
+	PropertyValue
+	RawValue string           `json:"rawValue"` // 原始值，敏感配置脱敏
+	Shadowed []*PropertyValue `json:"shadowed"` // 优先级更低的配置来源中被覆盖的值，按照优先级排序
+}
+
+/**
+GET /env/sources 的响应元素
+*/
+type SourceInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Size int    `json:"size"` // 配置项数量
+}
+
+func (h *Handler) handleProperties(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	properties := make(map[string]*PropertyValue)
+	h.env.GetPropertySources().Each(func(index int, source xenv.PropertySource) (stop bool) {
+		source.Each(func(key, value string) (stop bool) {
+			// 越靠前的配置来源优先级越高
+			if _, exists := properties[key]; exists || !strings.HasPrefix(key, prefix) {
+				return false
+			}
+			properties[key] = h.propertyValue(source, key, value)
+			return false
+		})
+		return false
+	})
+	writeJson(w, http.StatusOK, properties)
+}
+
+func (h *Handler) handleProperty(w http.ResponseWriter, r *http.Request, key string) {
+	var detail *PropertyDetail
+	h.env.GetPropertySources().Each(func(index int, source xenv.PropertySource) (stop bool) {
+		value, exists := source.GetProperty(key)
+		if !exists {
+			return false
+		}
+		if detail == nil {
+			detail = &PropertyDetail{Key: key, PropertyValue: *h.propertyValue(source, key, value),
+				RawValue: h.maskPropertyValue(key, value), Shadowed: make([]*PropertyValue, 0)}
+		} else {
+			detail.Shadowed = append(detail.Shadowed, h.propertyValue(source, key, value))
+		}
+		return false
+	})
+	if detail == nil {
+		writeError(w, http.StatusNotFound, errors.New("配置项不存在："+key))
+		return
+	}
+	writeJson(w, http.StatusOK, detail)
+}
+
+func (h *Handler) handleSources(w http.ResponseWriter, r *http.Request) {
+	sources := make([]*SourceInfo, 0)
+	h.env.GetPropertySources().Each(func(index int, source xenv.PropertySource) (stop bool) {
+		size := 0
+		source.Each(func(key, value string) (stop bool) {
+			size++
+			return false
+		})
+		sources = append(sources, &SourceInfo{Name: source.GetName(), Type: reflect.TypeOf(source).String(), Size: size})
+		return false
+	})
+	writeJson(w, http.StatusOK, sources)
+}
+
+func (h *Handler) handleGetOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides := make(map[string]string)
+	h.overrides.Each(func(key, value string) (stop bool) {
+		overrides[key] = h.maskPropertyValue(key, value)
+		return false
+	})
+	writeJson(w, http.StatusOK, overrides)
+}
+
+func (h *Handler) handlePutOverrides(w http.ResponseWriter, r *http.Request) {
+	kvs := make(map[string]string)
+	if err := json.NewDecoder(r.Body).Decode(&kvs); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("请求内容必须是 {\"key\": \"value\"} 格式的 JSON："+err.Error()))
+		return
+	}
+	for key := range kvs {
+		if len(strings.TrimSpace(key)) < 1 {
+			writeError(w, http.StatusBadRequest, errors.New("配置 key 不能为空"))
+			return
+		}
+	}
+	for key, value := range kvs {
+		xlog.Info("运行时覆盖配置项：[", key, "] => [", h.maskPropertyValue(key, value), "]")
+		h.overrides.Put(key, value)
+	}
+	h.handleGetOverrides(w, r)
+}
+
+func (h *Handler) handleDeleteOverrides(w http.ResponseWriter, r *http.Request) {
+	keys := r.URL.Query()["key"]
+	if len(keys) < 1 {
+		// 避免误操作，删除全部需要显式指定
+		if r.URL.Query().Get("all") != "true" {
+			writeError(w, http.StatusBadRequest, errors.New("需要通过 ?key= 指定删除的配置项，删除全部需要指定 ?all=true"))
+			return
+		}
+		h.overrides.Each(func(key, value string) (stop bool) {
+			keys = append(keys, key)
+			return false
+		})
+	}
+	xlog.Info("删除运行时覆盖的配置项：", keys)
+	h.overrides.Remove(keys...)
+	h.handleGetOverrides(w, r)
+}
+
+func (h *Handler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	auditable, ok := h.env.(AuditableEnvironment)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("环境不支持审计记录"))
+		return
+	}
+	writeJson(w, http.StatusOK, auditable.AuditLog())
+}
+
+func (h *Handler) handleRollback(w http.ResponseWriter, r *http.Request) {
+	auditable, ok := h.env.(AuditableEnvironment)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("环境不支持回滚"))
+		return
+	}
+	seq, err := strconv.ParseInt(r.URL.Query().Get("seq"), 10, 64)
+	if err != nil || seq < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("seq 必须是审计记录的序号"))
+		return
+	}
+	keys, err := auditable.RollbackRuntimeOverrides(seq)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+/**
+请求头中携带了调用链 ID 的话绑定到当前 goroutine，配置变更事件以及审计记录中可以获取到
+*/
+func (h *Handler) withTraceId(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		traceId := r.Header.Get(TraceIdHeader)
+		if len(traceId) > 0 && xcontext.GetContext() == nil {
+			xcontext.BindContext(traceId, r.Context(), nil)
+			defer xcontext.UnBindContext()
+		}
+		handler(w, r)
+	}
+}
+
+/**
+替换占位符并且脱敏，无法替换的话保留原始值
+*/
+func (h *Handler) propertyValue(source xenv.PropertySource, key, value string) *PropertyValue {
+	origin := xenv.GetPropertyOrigin(source, key)
+	if origin == source.GetName() {
+		origin = ""
+	}
+	return &PropertyValue{Source: source.GetName(), Origin: origin, Value: h.maskResolvedPropertyValue(key, value)}
+}
+
+/**
+环境支持的话使用环境的脱敏规则，包括 secret tag 绑定的配置 key，否则使用全局的敏感配置 key 模式
+*/
+func (h *Handler) maskPropertyValue(key, value string) string {
+	if masker, ok := h.env.(interface {
+		MaskPropertyValue(key, value string) string
+	}); ok {
+		return masker.MaskPropertyValue(key, value)
+	}
+	return xenv.MaskPropertyValue(key, value)
+}
+
+/**
+处理占位符之后脱敏，占位符（包括嵌套的）引用了敏感配置的话也要脱敏；
+环境不支持识别占位符引用的配置项的话不处理占位符，返回脱敏之后的原始值，避免通过占位符泄露敏感配置
+*/
+func (h *Handler) maskResolvedPropertyValue(key, value string) string {
+	if masker, ok := h.env.(interface {
+		MaskResolvedPropertyValue(key, value string) string
+	}); ok {
+		return masker.MaskResolvedPropertyValue(key, value)
+	}
+	return h.maskPropertyValue(key, value)
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		xlog.Error("写入响应失败：", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,227 @@
+package xenvhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkgo/xkit/xenv"
+	"github.com/xkgo/xkit/xenv/xenvtest"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler(t *testing.T, options ...Option) (*xenv.StandardEnvironment, *Handler) {
+	env := xenvtest.NewEnvironment(t, map[string]string{
+		"server.port":     "8080",
+		"server.url":      "http://127.0.0.1:${server.port}",
+		"server.password": "123456",
+		"app.name":        "demo",
+	})
+	xenvtest.Override(t, env, "app.name", "override")
+	return env, NewHandler(env, options...)
+}
+
+// 测试中允许所有请求
+var allowAll = Authorize(func(r *http.Request) error { return nil })
+
+// 测试中只开放只读请求
+var allowReads = AllowUnauthenticatedReads()
+
+func serve(h http.Handler, method, target, body string, v interface{}) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if v != nil {
+		_ = json.Unmarshal(recorder.Body.Bytes(), v)
+	}
+	return recorder
+}
+
+func TestHandler_Properties(t *testing.T) {
+	env, h := newTestHandler(t, allowReads)
+
+	properties := make(map[string]*PropertyValue)
+	recorder := serve(h, http.MethodGet, "/env?prefix=server.", "", &properties)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 3, len(properties))
+	assert.Equal(t, "http://127.0.0.1:8080", properties["server.url"].Value)
+	assert.Equal(t, "******", properties["server.password"].Value)
+	assert.Equal(t, xenvtest.PropertySourceName, properties["server.port"].Source)
+
+	detail := &PropertyDetail{}
+	recorder = serve(h, http.MethodGet, "/env/app.name", "", detail)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "override", detail.Value)
+	assert.Equal(t, xenvtest.OverridePropertySourceName, detail.Source)
+	assert.Equal(t, 1, len(detail.Shadowed))
+	assert.Equal(t, "demo", detail.Shadowed[0].Value)
+	assert.Equal(t, xenvtest.PropertySourceName, detail.Shadowed[0].Source)
+
+	detail = &PropertyDetail{}
+	serve(h, http.MethodGet, "/env/server.url", "", detail)
+	assert.Equal(t, "http://127.0.0.1:8080", detail.Value)
+	assert.Equal(t, "http://127.0.0.1:${server.port}", detail.RawValue)
+
+	recorder = serve(h, http.MethodGet, "/env/not.exists", "", nil)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	sources := make([]*SourceInfo, 0)
+	serve(h, http.MethodGet, "/env/sources", "", &sources)
+	assert.True(t, len(sources) > 2)
+	// 覆盖配置来源优先级最高
+	assert.Equal(t, OverridePropertySourceName, sources[0].Name)
+	last := sources[len(sources)-1]
+	assert.Equal(t, xenvtest.PropertySourceName, last.Name)
+	assert.Equal(t, 4, last.Size)
+	assert.Equal(t, "*xenv.MapPropertySource", last.Type)
+
+	// 没有设置鉴权钩子不允许修改配置
+	recorder = serve(h, http.MethodPost, "/env/overrides", `{"server.port": "9090"}`, nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	recorder = serve(h, http.MethodDelete, "/env/overrides?all=true", "", nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	recorder = serve(h, http.MethodPost, "/env/overrides/rollback?seq=0", "", nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, "8080", env.GetPropertyWithDef("server.port", ""))
+}
+
+func TestHandler_DenyByDefault(t *testing.T) {
+	_, h := newTestHandler(t)
+
+	// 没有设置鉴权钩子，也没有开放只读请求，所有请求都拒绝
+	for _, target := range []string{"/env", "/env/app.name", "/env/sources", "/env/overrides", "/env/audit"} {
+		recorder := serve(h, http.MethodGet, target, "", nil)
+		assert.Equal(t, http.StatusForbidden, recorder.Code, target)
+	}
+	recorder := serve(h, http.MethodHead, "/env", "", nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestHandler_SecretReference(t *testing.T) {
+	env := xenvtest.NewEnvironment(t, map[string]string{
+		"db.password": "123456",
+		"db.url":      "mysql://u:${db.password}@h",
+	})
+	h := NewHandler(env, allowReads)
+
+	// 占位符引用了敏感配置，生效值要脱敏，原始值不包含敏感信息
+	properties := make(map[string]*PropertyValue)
+	serve(h, http.MethodGet, "/env?prefix=db.", "", &properties)
+	assert.Equal(t, "******", properties["db.url"].Value)
+
+	detail := &PropertyDetail{}
+	serve(h, http.MethodGet, "/env/db.url", "", detail)
+	assert.Equal(t, "******", detail.Value)
+	assert.Equal(t, "mysql://u:${db.password}@h", detail.RawValue)
+}
+
+func TestHandler_Overrides(t *testing.T) {
+	env, h := newTestHandler(t, allowAll)
+
+	events := make(chan *xenv.KeyChangeEvent, 10)
+	sub := env.Subscribe("server.port", func(event *xenv.KeyChangeEvent) {
+		events <- event
+	})
+	defer sub.Unsubscribe()
+	awaitEvent := func() *xenv.KeyChangeEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(xenvtest.DefaultTimeout):
+			t.Fatal("等待配置变更事件超时")
+		}
+		return nil
+	}
+
+	overrides := make(map[string]string)
+	recorder := serve(h, http.MethodPost, "/env/overrides", `{"server.port": "9090", "server.password": "abc"}`, &overrides)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, map[string]string{"server.port": "9090", "server.password": "******"}, overrides)
+	event := awaitEvent()
+	assert.Equal(t, xenv.PropertyAdd, event.ChangeType)
+	assert.Equal(t, "9090", event.Nv)
+	assert.Equal(t, "9090", env.GetPropertyWithDef("server.port", ""))
+
+	overrides = make(map[string]string)
+	recorder = serve(h, http.MethodDelete, "/env/overrides?key=server.port", "", &overrides)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, map[string]string{"server.password": "******"}, overrides)
+	event = awaitEvent()
+	assert.Equal(t, xenv.PropertyDel, event.ChangeType)
+	assert.Equal(t, "8080", env.GetPropertyWithDef("server.port", ""))
+
+	// 删除全部需要显式指定 all=true
+	recorder = serve(h, http.MethodDelete, "/env/overrides", "", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "abc", h.Overrides().GetPropertyWithDef("server.password", ""))
+	overrides = make(map[string]string)
+	serve(h, http.MethodDelete, "/env/overrides?all=true", "", &overrides)
+	assert.Equal(t, 0, len(overrides))
+
+	recorder = serve(h, http.MethodPost, "/env/overrides", `["server.port"]`, nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = serve(h, http.MethodPut, "/env/overrides", "", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.Equal(t, "DELETE, GET, POST", recorder.Header().Get("Allow"))
+
+	// 再次创建使用同一个覆盖配置来源
+	assert.Equal(t, h.Overrides(), NewHandler(env).Overrides())
+}
+
+func TestHandler_Authorize(t *testing.T) {
+	_, h := newTestHandler(t, PathPrefix("/admin/env/"), Authorize(func(r *http.Request) error {
+		if r.Method != http.MethodGet && r.Header.Get("X-Token") != "secret" {
+			return errors.New("forbidden")
+		}
+		return nil
+	}))
+
+	recorder := serve(h, http.MethodGet, "/admin/env/app.name", "", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = serve(h, http.MethodPost, "/admin/env/overrides", `{"app.name": "x"}`, nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	request := httptest.NewRequest(http.MethodPost, "/admin/env/overrides", strings.NewReader(`{"app.name": "x"}`))
+	request.Header.Set("X-Token", "secret")
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = serve(h, http.MethodGet, "/env", "", nil)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHandler_AuditAndRollback(t *testing.T) {
+	env, h := newTestHandler(t, allowAll)
+
+	request := httptest.NewRequest(http.MethodPost, "/env/overrides", strings.NewReader(`{"server.port": "9090"}`))
+	request.Header.Set(TraceIdHeader, "trace-http")
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	xenvtest.Eventually(t, func() bool {
+		entries := env.AuditLog()
+		return len(entries) > 0 && entries[len(entries)-1].Key == "server.port"
+	})
+
+	entries := make([]*xenv.AuditEntry, 0)
+	serve(h, http.MethodGet, "/env/audit", "", &entries)
+	last := entries[len(entries)-1]
+	assert.Equal(t, OverridePropertySourceName, last.Source)
+	assert.Equal(t, "server.port", last.Key)
+	assert.Equal(t, "trace-http", last.TraceId)
+
+	result := make(map[string][]string)
+	recorder = serve(h, http.MethodPost, "/env/overrides/rollback?seq="+strconv.FormatInt(last.Seq-1, 10), "", &result)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{"server.port"}, result["keys"])
+	xenvtest.Eventually(t, func() bool {
+		return env.GetPropertyWithDef("server.port", "") == "8080"
+	})
+
+	recorder = serve(h, http.MethodPost, "/env/overrides/rollback?seq=x", "", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
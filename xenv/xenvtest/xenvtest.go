@@ -0,0 +1,167 @@
+/**
+xenvtest 提供测试中构造、覆盖 xenv.Environment 的工具：
+	> 不读取命令行参数、系统环境变量和配置文件
+	> 不修改 xlog 全局日志配置
+	> 覆盖的配置项在测试结束之后通过 t.Cleanup 自动还原，并且会触发真实的配置变更事件
+*/
+package xenvtest
+
+import (
+	"context"
+	"github.com/xkgo/xkit/xenv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// 测试配置来源名称，即 NewEnvironment 传入的配置
+	PropertySourceName = "xenvtest"
+	// 覆盖配置来源名称，优先级高于 PropertySourceName
+	OverridePropertySourceName = "xenvtestOverrides"
+)
+
+// 等待配置变更事件、条件满足的超时时间
+var DefaultTimeout = 3 * time.Second
+
+/**
+创建测试环境，环境在测试结束之后自动关闭
+@param properties 配置项
+@param options 额外的环境选项，会覆盖默认选项
+*/
+func NewEnvironment(t testing.TB, properties map[string]string, options ...xenv.Option) *xenv.StandardEnvironment {
+	t.Helper()
+
+	sources := xenv.NewMutablePropertySources(
+		xenv.NewMapPropertySource(OverridePropertySourceName, nil),
+		xenv.NewMapPropertySource(PropertySourceName, properties),
+	)
+
+	opts := []xenv.Option{
+		xenv.IgnoreCommandLine(),
+		xenv.IgnoreSystemEnvironment(),
+		xenv.IgnoreConfigFiles(),
+		xenv.DisableXlogInit(),
+		xenv.CustomRunInfo(&xenv.RunInfo{Env: xenv.Test}),
+		xenv.AdditionalPropertySources(sources),
+	}
+	opts = append(opts, options...)
+
+	env := xenv.New(opts...)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		_ = env.Close(ctx)
+	})
+	return env
+}
+
+/**
+覆盖配置项，等待配置变更事件分发完成之后才返回，测试结束之后自动还原
+*/
+func Override(t testing.TB, env *xenv.StandardEnvironment, key, value string) {
+	t.Helper()
+
+	overrides := getOverrides(t, env)
+	ov, existed := overrides.GetProperty(key)
+
+	awaitChange(t, env, key, func() {
+		overrides.Put(key, value)
+	})
+
+	t.Cleanup(func() {
+		awaitChange(t, env, key, func() {
+			if existed {
+				overrides.Put(key, ov)
+			} else {
+				overrides.Remove(key)
+			}
+		})
+	})
+}
+
+/**
+删除覆盖的配置项，使得配置项回退到 NewEnvironment 传入的值，等待配置变更事件分发完成之后才返回
+*/
+func Restore(t testing.TB, env *xenv.StandardEnvironment, key string) {
+	t.Helper()
+
+	overrides := getOverrides(t, env)
+	if _, exists := overrides.GetProperty(key); !exists {
+		return
+	}
+	awaitChange(t, env, key, func() {
+		overrides.Remove(key)
+	})
+}
+
+/**
+绑定配置 Bean 并监听变更，绑定失败直接结束测试
+*/
+func Bind(t testing.TB, env *xenv.StandardEnvironment, keyPrefix string, cfgPtr interface{}) interface{} {
+	t.Helper()
+
+	bean, err := env.BindProperties(keyPrefix, cfgPtr, true)
+	if err != nil {
+		t.Fatalf("绑定配置Bean失败，keyPrefix:%s, err:%v", keyPrefix, err)
+	}
+	return bean
+}
+
+/**
+等待条件满足，超过 DefaultTimeout 还没有满足的话测试失败，用于断言绑定的配置 Bean 已经响应了配置变更
+*/
+func Eventually(t testing.TB, condition func() bool, msgAndArgs ...interface{}) {
+	t.Helper()
+
+	deadline := time.Now().Add(DefaultTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			if len(msgAndArgs) > 0 {
+				t.Fatal(msgAndArgs...)
+			}
+			t.Fatal("等待条件满足超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func getOverrides(t testing.TB, env *xenv.StandardEnvironment) *xenv.MapPropertySource {
+	t.Helper()
+
+	source, ok := env.GetPropertySources().Get(OverridePropertySourceName)
+	if !ok {
+		t.Fatal("环境中不存在覆盖配置来源，请使用 xenvtest.NewEnvironment 创建环境")
+	}
+	overrides, ok := source.(*xenv.MapPropertySource)
+	if !ok {
+		t.Fatal("覆盖配置来源[" + OverridePropertySourceName + "]必须是 *xenv.MapPropertySource")
+	}
+	return overrides
+}
+
+/**
+执行变更并等待环境分发 key 的配置变更事件
+*/
+func awaitChange(t testing.TB, env *xenv.StandardEnvironment, key string, change func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	once := sync.Once{}
+	sub := env.Subscribe(key, func(event *xenv.KeyChangeEvent) {
+		if event.Key == key {
+			once.Do(func() {
+				close(done)
+			})
+		}
+	})
+	defer sub.Unsubscribe()
+
+	change()
+
+	select {
+	case <-done:
+	case <-time.After(DefaultTimeout):
+		t.Fatalf("等待配置[%s]变更事件超时", key)
+	}
+}
@@ -0,0 +1,56 @@
+package xenvtest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ServerConfig struct {
+	Host string `ck:"host" def:"127.0.0.1"`
+	Port int    `ck:"port" def:"8080"`
+}
+
+func TestOverride(t *testing.T) {
+	env := NewEnvironment(t, map[string]string{
+		"server.port": "9090",
+	})
+
+	config := &ServerConfig{}
+	Bind(t, env, "server.", config)
+	assert.Equal(t, "127.0.0.1", config.Host)
+	assert.Equal(t, 9090, config.Port)
+
+	t.Run("override", func(t *testing.T) {
+		Override(t, env, "server.port", "7070")
+		assert.Equal(t, 7070, config.Port)
+
+		Override(t, env, "server.host", "0.0.0.0")
+		assert.Equal(t, "0.0.0.0", config.Host)
+	})
+
+	// 子测试结束之后自动还原
+	assert.Equal(t, 9090, config.Port)
+	assert.Equal(t, "127.0.0.1", config.Host)
+	_, exists := env.GetProperty("server.host")
+	assert.False(t, exists)
+}
+
+func TestNewEnvironment_Isolated(t *testing.T) {
+	env := NewEnvironment(t, nil)
+
+	assert.True(t, env.IsTest())
+	assert.False(t, env.GetPropertySources().Contains("commandLineEnvironment"))
+	assert.False(t, env.GetPropertySources().Contains("systemEnvironment"))
+	assert.False(t, env.GetPropertySources().Contains("defaultApplicationEnvironment"))
+}
+
+func TestEventually(t *testing.T) {
+	env := NewEnvironment(t, map[string]string{})
+	config := &ServerConfig{}
+	Bind(t, env, "server.", config)
+
+	Override(t, env, "server.port", "1")
+	Eventually(t, func() bool {
+		return config.Port == 1
+	})
+}
@@ -0,0 +1,310 @@
+package xfeature
+
+import (
+	"fmt"
+	"github.com/xkgo/xkit/xenv"
+	"github.com/xkgo/xkit/xlog"
+	"github.com/xkgo/xkit/xstr"
+	"github.com/xkgo/xkit/xver"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// 功能开关配置前缀，配置格式：feature.<name>.enabled|percentage|sets|envs|minVersion|maxVersion
+	KeyPrefix = "feature."
+
+	EnabledKey    = "enabled"    // 是否开启，默认 false
+	PercentageKey = "percentage" // 灰度百分比，取值 [0, 100]，支持小数，默认 100
+	SetsKey       = "sets"       // 生效的部署集，多个用逗号分隔，为空表示不限制
+	EnvsKey       = "envs"       // 生效的运行环境，多个用逗号分隔，为空表示不限制
+	MinVersionKey = "minVersion" // 生效的最小客户端版本，含
+	MaxVersionKey = "maxVersion" // 生效的最大客户端版本，含
+
+	// 灰度分桶数，百分比精确到 0.01
+	bucketCount = 10000
+)
+
+/**
+功能开关判定原因
+*/
+type Reason string
+
+const (
+	ReasonNotFound           Reason = "NOT_FOUND"           // 没有配置这个开关
+	ReasonDisabled           Reason = "DISABLED"            // 开关关闭
+	ReasonEnvMismatch        Reason = "ENV_MISMATCH"        // 运行环境不匹配
+	ReasonSetMismatch        Reason = "SET_MISMATCH"        // 部署集不匹配
+	ReasonVersionMismatch    Reason = "VERSION_MISMATCH"    // 客户端版本不在范围内
+	ReasonMissingKey         Reason = "MISSING_KEY"         // 灰度中但是没有提供灰度 key
+	ReasonPercentageExcluded Reason = "PERCENTAGE_EXCLUDED" // 灰度未命中
+	ReasonPercentageIncluded Reason = "PERCENTAGE_INCLUDED" // 灰度命中
+	ReasonEnabled            Reason = "ENABLED"             // 全量开启
+)
+
+/**
+功能开关配置
+*/
+type Flag struct {
+	Name       string
+	Enabled    bool
+	Percentage float64  // 灰度百分比，取值 [0, 100]
+	Sets       []string // 生效的部署集，为空表示不限制
+	Envs       []string // 生效的运行环境，为空表示不限制
+	MinVersion string   // 生效的最小客户端版本，含
+	MaxVersion string   // 生效的最大客户端版本，含
+}
+
+/**
+判定对象
+*/
+type Target struct {
+	Key     string // 灰度 key，比如用户ID、设备ID，相同的 key 判定结果稳定
+	Version string // 客户端版本，配置了版本限制的开关需要提供
+}
+
+/**
+判定结果，用于排查开关为什么开启或者关闭
+*/
+type Evaluation struct {
+	Flag    string
+	Enabled bool
+	Reason  Reason
+	Bucket  int // 灰度 key 所在的分桶，取值 [0, 10000)，没有进行灰度判定的话为 -1
+}
+
+func (e *Evaluation) String() string {
+	return fmt.Sprintf("feature:[%s], enabled:[%v], reason:[%s], bucket:[%d]", e.Flag, e.Enabled, e.Reason, e.Bucket)
+}
+
+/**
+功能开关管理，从环境中读取 feature. 前缀的配置，配置变更之后自动更新
+*/
+type Manager struct {
+	env          xenv.Environment
+	flags        atomic.Value // map[string]*Flag
+	lock         sync.Mutex   // 串行更新 flags
+	subscription xenv.Subscription
+}
+
+/**
+创建功能开关管理，加载所有已经配置的开关并订阅配置变更
+*/
+func New(env xenv.Environment) *Manager {
+	m := &Manager{env: env}
+	m.flags.Store(m.loadAll())
+	m.subscription = env.Subscribe("^"+strings.Replace(KeyPrefix, ".", "\\.", -1)+".*", func(event *xenv.KeyChangeEvent) {
+		if name, ok := flagName(event.Key); ok {
+			m.reload(name)
+		}
+	})
+	return m
+}
+
+/**
+从配置 key 中解析开关名称，比如 feature.newCheckout.enabled => newCheckout
+*/
+func flagName(key string) (string, bool) {
+	if !strings.HasPrefix(key, KeyPrefix) {
+		return "", false
+	}
+	key = key[len(KeyPrefix):]
+	index := strings.LastIndex(key, ".")
+	if index < 1 {
+		return "", false
+	}
+	return key[:index], true
+}
+
+func (m *Manager) loadAll() map[string]*Flag {
+	names := make(map[string]bool)
+	m.env.GetPropertySources().Each(func(index int, source xenv.PropertySource) (stop bool) {
+		source.Each(func(key, value string) (stop bool) {
+			if name, ok := flagName(key); ok {
+				names[name] = true
+			}
+			return false
+		})
+		return false
+	})
+
+	flags := make(map[string]*Flag, len(names))
+	for name := range names {
+		if flag := m.loadFlag(name); flag != nil {
+			flags[name] = flag
+		}
+	}
+	return flags
+}
+
+/**
+读取开关配置，enabled 没有配置的话表示开关不存在
+*/
+func (m *Manager) loadFlag(name string) *Flag {
+	prefix := KeyPrefix + name + "."
+	enabledValue, exists := m.env.GetProperty(prefix + EnabledKey)
+	if !exists {
+		return nil
+	}
+	flag := &Flag{Name: name, Percentage: 100}
+	flag.Enabled, _ = strconv.ParseBool(xstr.Trim(enabledValue))
+	if value, ok := m.env.GetProperty(prefix + PercentageKey); ok && len(xstr.Trim(value)) > 0 {
+		percentage, err := strconv.ParseFloat(xstr.Trim(value), 64)
+		if err != nil {
+			xlog.Warn("功能开关["+name+"]灰度百分比配置错误：", value, ", 按照 0 处理")
+		}
+		flag.Percentage = percentage
+	}
+	flag.Sets = splitValues(m.env.GetPropertyWithDef(prefix+SetsKey, ""))
+	flag.Envs = splitValues(m.env.GetPropertyWithDef(prefix+EnvsKey, ""))
+	flag.MinVersion = xstr.Trim(m.env.GetPropertyWithDef(prefix+MinVersionKey, ""))
+	flag.MaxVersion = xstr.Trim(m.env.GetPropertyWithDef(prefix+MaxVersionKey, ""))
+	return flag
+}
+
+func splitValues(value string) []string {
+	values := make([]string, 0)
+	for _, item := range xstr.SplitByRegex(xstr.Trim(value), "[,，;；\\s]+") {
+		if item = xstr.Trim(item); len(item) > 0 {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+/**
+重新加载单个开关，copy-on-write，判定过程不加锁
+*/
+func (m *Manager) reload(name string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	current := m.getFlags()
+	flags := make(map[string]*Flag, len(current)+1)
+	for key, flag := range current {
+		flags[key] = flag
+	}
+	if flag := m.loadFlag(name); flag != nil {
+		flags[name] = flag
+	} else {
+		delete(flags, name)
+	}
+	m.flags.Store(flags)
+}
+
+func (m *Manager) getFlags() map[string]*Flag {
+	flags, _ := m.flags.Load().(map[string]*Flag)
+	return flags
+}
+
+/**
+获取开关配置
+*/
+func (m *Manager) Flag(name string) (flag *Flag, exists bool) {
+	flag, exists = m.getFlags()[name]
+	return
+}
+
+/**
+所有开关配置，按照名称排序
+*/
+func (m *Manager) Flags() []*Flag {
+	flags := make([]*Flag, 0)
+	for _, flag := range m.getFlags() {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+/**
+开关对于 target 是否开启
+*/
+func (m *Manager) IsEnabled(name string, target Target) bool {
+	return m.Evaluate(name, target).Enabled
+}
+
+/**
+判定开关并返回判定原因，判定顺序：是否存在 -> 是否开启 -> 运行环境 -> 部署集 -> 客户端版本 -> 灰度百分比
+*/
+func (m *Manager) Evaluate(name string, target Target) *Evaluation {
+	evaluation := &Evaluation{Flag: name, Bucket: -1}
+	flag, exists := m.Flag(name)
+	if !exists {
+		evaluation.Reason = ReasonNotFound
+		return evaluation
+	}
+	if !flag.Enabled {
+		evaluation.Reason = ReasonDisabled
+		return evaluation
+	}
+
+	runInfo := m.env.GetRunInfo()
+	if len(flag.Envs) > 0 && (runInfo == nil || !containsIgnoreCase(flag.Envs, string(runInfo.Env))) {
+		evaluation.Reason = ReasonEnvMismatch
+		return evaluation
+	}
+	if len(flag.Sets) > 0 && (runInfo == nil || !containsIgnoreCase(flag.Sets, runInfo.Set)) {
+		evaluation.Reason = ReasonSetMismatch
+		return evaluation
+	}
+	if len(flag.MinVersion) > 0 || len(flag.MaxVersion) > 0 {
+		versionRange := xver.VersionRange{Min: flag.MinVersion, Max: flag.MaxVersion}
+		if !xver.InRange(target.Version, versionRange, true, true) {
+			evaluation.Reason = ReasonVersionMismatch
+			return evaluation
+		}
+	}
+
+	if flag.Percentage >= 100 {
+		evaluation.Enabled = true
+		evaluation.Reason = ReasonEnabled
+		return evaluation
+	}
+	if len(target.Key) < 1 {
+		evaluation.Reason = ReasonMissingKey
+		return evaluation
+	}
+	evaluation.Bucket = Bucket(name, target.Key)
+	if float64(evaluation.Bucket) < flag.Percentage*bucketCount/100 {
+		evaluation.Enabled = true
+		evaluation.Reason = ReasonPercentageIncluded
+	} else {
+		evaluation.Reason = ReasonPercentageExcluded
+	}
+	return evaluation
+}
+
+/**
+灰度分桶，同一个开关、同一个 key 的结果是稳定的，不同开关之间互相独立
+@return 取值 [0, 10000)
+*/
+func Bucket(name, key string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name + ":" + key))
+	return int(hash.Sum32() % bucketCount)
+}
+
+func containsIgnoreCase(values []string, value string) bool {
+	for _, item := range values {
+		if xstr.EqualsIgnoreCase(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+取消配置变更订阅，之后开关配置不再更新
+*/
+func (m *Manager) Close() {
+	if m.subscription != nil {
+		m.subscription.Unsubscribe()
+	}
+}
@@ -0,0 +1,95 @@
+package xfeature
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkgo/xkit/xenv"
+	"testing"
+	"time"
+)
+
+func newTestEnvironment(properties map[string]string) (*xenv.StandardEnvironment, *xenv.MapPropertySource) {
+	source := xenv.NewMapPropertySource("test", properties)
+	sources := xenv.NewMutablePropertySources()
+	sources.AddLast(source)
+	env := xenv.New(xenv.IgnoreCommandLine(), xenv.IgnoreSystemEnvironment(), xenv.IgnoreConfigFiles(), xenv.DisableXlogInit(),
+		xenv.CustomRunInfo(&xenv.RunInfo{Env: xenv.Test, Set: "sg", Properties: map[string]string{}}),
+		xenv.AdditionalPropertySources(sources))
+	return env, source
+}
+
+func TestManager_Evaluate(t *testing.T) {
+	env, _ := newTestEnvironment(map[string]string{
+		"feature.on.enabled":           "true",
+		"feature.off.enabled":          "false",
+		"feature.prodOnly.enabled":     "true",
+		"feature.prodOnly.envs":        "prod",
+		"feature.sgOnly.enabled":       "true",
+		"feature.sgOnly.envs":          "dev,test",
+		"feature.sgOnly.sets":          "sg, us",
+		"feature.newApp.enabled":       "true",
+		"feature.newApp.minVersion":    "2.1.0",
+		"feature.half.enabled":         "true",
+		"feature.half.percentage":      "50",
+		"feature.new.checkout.enabled": "true",
+	})
+	m := New(env)
+	defer m.Close()
+
+	assert.Equal(t, ReasonEnabled, m.Evaluate("on", Target{}).Reason)
+	assert.Equal(t, ReasonDisabled, m.Evaluate("off", Target{}).Reason)
+	assert.Equal(t, ReasonNotFound, m.Evaluate("none", Target{}).Reason)
+	assert.Equal(t, ReasonEnvMismatch, m.Evaluate("prodOnly", Target{}).Reason)
+	assert.True(t, m.IsEnabled("sgOnly", Target{}))
+	assert.True(t, m.IsEnabled("new.checkout", Target{}))
+
+	assert.Equal(t, ReasonVersionMismatch, m.Evaluate("newApp", Target{Version: "2.0.9"}).Reason)
+	assert.Equal(t, ReasonVersionMismatch, m.Evaluate("newApp", Target{}).Reason)
+	assert.True(t, m.IsEnabled("newApp", Target{Version: "v2.1.0"}))
+
+	assert.Equal(t, ReasonMissingKey, m.Evaluate("half", Target{}).Reason)
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		evaluation := m.Evaluate("half", Target{Key: key})
+		// 相同的 key 结果稳定
+		assert.Equal(t, evaluation.Enabled, m.IsEnabled("half", Target{Key: key}))
+		if evaluation.Enabled {
+			enabled++
+			assert.Equal(t, ReasonPercentageIncluded, evaluation.Reason)
+		}
+	}
+	assert.InDelta(t, 500, enabled, 60)
+
+	assert.Equal(t, []string{"half", "new.checkout", "newApp", "off", "on", "prodOnly", "sgOnly"}, flagNames(m.Flags()))
+}
+
+func flagNames(flags []*Flag) []string {
+	names := make([]string, 0)
+	for _, flag := range flags {
+		names = append(names, flag.Name)
+	}
+	return names
+}
+
+func TestManager_LiveUpdate(t *testing.T) {
+	env, source := newTestEnvironment(map[string]string{"feature.checkout.enabled": "false"})
+	m := New(env)
+	defer m.Close()
+	assert.False(t, m.IsEnabled("checkout", Target{}))
+
+	source.Put("feature.checkout.enabled", "true")
+	assert.Eventually(t, func() bool {
+		return m.IsEnabled("checkout", Target{})
+	}, time.Second, 10*time.Millisecond)
+
+	source.Put("feature.beta.enabled", "true")
+	assert.Eventually(t, func() bool {
+		return m.IsEnabled("beta", Target{})
+	}, time.Second, 10*time.Millisecond)
+
+	source.Remove("feature.beta.enabled")
+	assert.Eventually(t, func() bool {
+		return m.Evaluate("beta", Target{}).Reason == ReasonNotFound
+	}, time.Second, 10*time.Millisecond)
+}
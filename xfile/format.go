@@ -0,0 +1,129 @@
+package xfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/**
+配置文件格式解析器，将内容解析为 kvs map，key 按照 . 以及 [n] 展开
+*/
+type FormatParser func(r io.Reader) (map[string]string, error)
+
+var (
+	formatParsers     = make(map[string]FormatParser) // 小写的文件后缀（不带 .）-> 解析器
+	formatParsersLock sync.RWMutex
+)
+
+func init() {
+	RegisterFormat([]string{"properties", "prop", "props"}, bytesFormatParser(ParsePropertiesAsMap))
+	RegisterFormat([]string{"yaml", "yml"}, bytesFormatParser(func(dataBytes []byte) (map[string]string, error) {
+		return ParseYamlAsMap(dataBytes)
+	}))
+	RegisterFormat([]string{"json"}, bytesFormatParser(func(dataBytes []byte) (map[string]string, error) {
+		return ParseJsonAsMap(dataBytes)
+	}))
+	RegisterFormat([]string{"ini"}, bytesFormatParser(ParseIniAsMap))
+	RegisterFormat([]string{"hcl"}, bytesFormatParser(ParseHclAsMap))
+}
+
+func bytesFormatParser(parse func(dataBytes []byte) (map[string]string, error)) FormatParser {
+	return func(r io.Reader) (map[string]string, error) {
+		dataBytes, err := ioutil.ReadAll(r)
+		if err != nil {
+			return make(map[string]string), err
+		}
+		return parse(dataBytes)
+	}
+}
+
+/**
+注册配置文件格式，ReadAsMap 以及 xenv 加载配置文件的时候根据文件后缀选择解析器，已经注册的后缀会被覆盖
+@param exts 文件后缀，忽略大小写，可以带 . 也可以不带，比如 toml 或者 .toml
+*/
+func RegisterFormat(exts []string, parse func(r io.Reader) (map[string]string, error)) {
+	formatParsersLock.Lock()
+	defer formatParsersLock.Unlock()
+	for _, ext := range exts {
+		formatParsers[normalizeFormatExt(ext)] = parse
+	}
+}
+
+/**
+获取文件后缀对应的解析器
+*/
+func GetFormatParser(ext string) (FormatParser, bool) {
+	formatParsersLock.RLock()
+	defer formatParsersLock.RUnlock()
+	parser, ok := formatParsers[normalizeFormatExt(ext)]
+	return parser, ok
+}
+
+/**
+已经注册的所有文件后缀，按照字典序排序
+*/
+func FormatExtensions() []string {
+	formatParsersLock.RLock()
+	defer formatParsersLock.RUnlock()
+	exts := make([]string, 0, len(formatParsers))
+	for ext := range formatParsers {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+/**
+文件后缀是否有对应的解析器
+*/
+func IsSupportedFormatFile(filePath string) bool {
+	_, ok := GetFormatParser(filepath.Ext(filePath))
+	return ok
+}
+
+func normalizeFormatExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+/**
+按照文件后缀对应的格式解析内容，可以用于读取远程配置等非本地文件的场景
+@param ext 文件后缀或者格式名称，比如 yml、.properties
+*/
+func ParseAsMap(ext string, r io.Reader) (kvs map[string]string, err error) {
+	parser, ok := GetFormatParser(ext)
+	if !ok {
+		return make(map[string]string), errors.New("不支持的 properties 文件类型：" + ext)
+	}
+	return parser(r)
+}
+
+/**
+按照文件后缀对应的格式解析内容，参考 ParseAsMap
+*/
+func ParseBytesAsMap(ext string, dataBytes []byte) (kvs map[string]string, err error) {
+	return ParseAsMap(ext, bytes.NewReader(dataBytes))
+}
+
+/**
+解析为 kvs map，根据文件后缀选择解析器，默认支持 properties、yaml、json、ini 以及 hcl 风格的配置文件，
+其他格式可以通过 RegisterFormat 注册
+*/
+func ReadAsMap(filePath string) (kvs map[string]string, err error) {
+	parser, ok := GetFormatParser(filepath.Ext(filePath))
+	if !ok {
+		return make(map[string]string), errors.New("不支持的 properties 文件类型：" + filePath)
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return make(map[string]string), err
+	}
+	defer file.Close()
+	return parser(file)
+}
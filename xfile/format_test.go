@@ -0,0 +1,71 @@
+package xfile
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 测试结束之后恢复注册的配置文件格式，避免影响其他测试
+func restoreFormatParsers(t *testing.T) {
+	formatParsersLock.RLock()
+	saved := make(map[string]FormatParser, len(formatParsers))
+	for ext, parser := range formatParsers {
+		saved[ext] = parser
+	}
+	formatParsersLock.RUnlock()
+	t.Cleanup(func() {
+		formatParsersLock.Lock()
+		formatParsers = saved
+		formatParsersLock.Unlock()
+	})
+}
+
+func TestRegisterFormat(t *testing.T) {
+	restoreFormatParsers(t)
+
+	// 每行 key value，使用空白分隔
+	RegisterFormat([]string{".KV"}, func(r io.Reader) (map[string]string, error) {
+		kvs := make(map[string]string)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) == 2 {
+				kvs[fields[0]] = fields[1]
+			}
+		}
+		return kvs, scanner.Err()
+	})
+	parser, ok := GetFormatParser("kv")
+	assert.True(t, ok)
+	assert.NotNil(t, parser)
+	assert.Contains(t, FormatExtensions(), "kv")
+	assert.True(t, IsSupportedFormatFile("/config/application.Kv"))
+	assert.False(t, IsSupportedFormatFile("/config/application.bak"))
+
+	kvs, err := ParseBytesAsMap("kv", []byte("server.port 8080\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"server.port": "8080"}, kvs)
+
+	kvs, err = ParseAsMap(".yml", strings.NewReader("servers:\n  - host: a\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"servers": `[{"host":"a"}]`, "servers[0].host": "a"}, kvs)
+
+	dir, err := ioutil.TempDir("", "xfile-format")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "application.kv")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("app.name demo\n"), 0644))
+	kvs, err = ReadAsMap(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"app.name": "demo"}, kvs)
+
+	_, err = ReadAsMap(filepath.Join(dir, "application.bak"))
+	assert.NotNil(t, err)
+	_, err = ParseBytesAsMap("bak", nil)
+	assert.NotNil(t, err)
+}
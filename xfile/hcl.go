@@ -0,0 +1,237 @@
+package xfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+/**
+将 hcl 风格的配置文件读取出来，作为 key value 格式，规则参考 ParseHclAsMap
+*/
+func ReadHclAsMap(hclFile string) (kvs map[string]string, err error) {
+	dataBytes, err := ioutil.ReadFile(hclFile)
+	if err != nil {
+		return make(map[string]string), err
+	}
+	return ParseHclAsMap(dataBytes)
+}
+
+/**
+解析 hcl 风格的配置内容，只支持常用的一部分语法：
+	> 属性：key = value，值可以是双引号字符串、数字、true/false 等不带空白的文本
+	> 块：name "label" { ... }，块名以及 label 按照 . 拼接作为前缀，比如 db "main" { url = "x" } 为 db.main.url；
+	  key = { ... } 的写法等同于块
+	> 数组：key = [1, 2, { name = "a" }]，按照下标展开，比如 key[0]、key[2].name
+	> 注释：#、// 以及块注释
+包含 ${...} 占位符的值需要使用双引号
+*/
+func ParseHclAsMap(dataBytes []byte) (kvs map[string]string, err error) {
+	kvs = make(map[string]string)
+	tokens, err := scanHclTokens(string(dataBytes))
+	if err != nil {
+		return kvs, err
+	}
+	parser := &hclParser{tokens: tokens, kvs: kvs}
+	err = parser.parseBody("", false)
+	return kvs, err
+}
+
+type hclTokenType int
+
+const (
+	hclWord    hclTokenType = iota // 不带引号的文本
+	hclString                      // 双引号字符串，已经去掉了引号以及转义
+	hclSymbol                      // { } [ ] = ,
+	hclNewline                     // 换行，作为属性之间的分隔
+	hclEOF
+)
+
+type hclToken struct {
+	typ  hclTokenType
+	text string
+	line int
+}
+
+func (t *hclToken) is(symbol string) bool {
+	return t.typ == hclSymbol && t.text == symbol
+}
+
+func (t *hclToken) String() string {
+	switch t.typ {
+	case hclNewline:
+		return "换行"
+	case hclEOF:
+		return "文件结尾"
+	}
+	return t.text
+}
+
+func scanHclTokens(text string) ([]*hclToken, error) {
+	tokens := make([]*hclToken, 0)
+	line := 1
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			tokens = append(tokens, &hclToken{typ: hclNewline, line: line})
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(text[i:], "//"):
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("hcl 第 %d 行注释没有结束", line)
+			}
+			comment := text[i : i+2+end+2]
+			line += strings.Count(comment, "\n")
+			i += len(comment)
+		case strings.IndexByte("{}[]=,", c) >= 0:
+			tokens = append(tokens, &hclToken{typ: hclSymbol, text: string(c), line: line})
+			i++
+		case c == '"':
+			end := closingQuoteIndex(text[i:])
+			if end < 0 || strings.Contains(text[i:i+end], "\n") {
+				return nil, fmt.Errorf("hcl 第 %d 行字符串缺少结束的双引号", line)
+			}
+			value, err := strconv.Unquote(text[i : i+end+1])
+			if err != nil {
+				return nil, fmt.Errorf("hcl 第 %d 行字符串格式错误：%v", line, err)
+			}
+			tokens = append(tokens, &hclToken{typ: hclString, text: value, line: line})
+			i += end + 1
+		default:
+			start := i
+			for i < len(text) && strings.IndexByte(" \t\r\n#{}[]=,\"", text[i]) < 0 {
+				i++
+			}
+			tokens = append(tokens, &hclToken{typ: hclWord, text: text[start:i], line: line})
+		}
+	}
+	return append(tokens, &hclToken{typ: hclEOF, line: line}), nil
+}
+
+type hclParser struct {
+	tokens []*hclToken
+	pos    int
+	kvs    map[string]string
+}
+
+func (p *hclParser) peek() *hclToken {
+	return p.tokens[p.pos]
+}
+
+func (p *hclParser) next() *hclToken {
+	token := p.tokens[p.pos]
+	if token.typ != hclEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *hclParser) skipNewlines() {
+	for p.peek().typ == hclNewline {
+		p.pos++
+	}
+}
+
+func (p *hclParser) unexpected(token *hclToken) error {
+	return fmt.Errorf("hcl 第 %d 行存在非预期的内容：%v", token.line, token)
+}
+
+/**
+解析属性以及块，inBlock 的话以 } 结束，否则以文件结尾结束
+*/
+func (p *hclParser) parseBody(prefix string, inBlock bool) error {
+	for {
+		p.skipNewlines()
+		token := p.next()
+		switch {
+		case token.typ == hclEOF:
+			if inBlock {
+				return fmt.Errorf("hcl 第 %d 行缺少结束的 }", token.line)
+			}
+			return nil
+		case token.is("}"):
+			if !inBlock {
+				return p.unexpected(token)
+			}
+			return nil
+		case token.typ != hclWord && token.typ != hclString:
+			return p.unexpected(token)
+		}
+
+		// 块名以及 label
+		names := []string{token.text}
+		for p.peek().typ == hclWord || p.peek().typ == hclString {
+			names = append(names, p.next().text)
+		}
+		key := prefix + strings.Join(names, ".")
+		token = p.next()
+		switch {
+		case token.is("{"):
+			if err := p.parseBody(key+".", true); err != nil {
+				return err
+			}
+		case token.is("=") && len(names) == 1:
+			if err := p.parseValue(key); err != nil {
+				return err
+			}
+		default:
+			return p.unexpected(token)
+		}
+
+		// 属性之间需要换行，或者紧跟块的结束
+		switch token = p.peek(); {
+		case token.typ == hclNewline || token.typ == hclEOF || token.is("}"):
+		case token.is(","):
+			p.next()
+		default:
+			return p.unexpected(token)
+		}
+	}
+}
+
+func (p *hclParser) parseValue(key string) error {
+	p.skipNewlines()
+	token := p.next()
+	switch {
+	case token.typ == hclWord || token.typ == hclString:
+		p.kvs[key] = token.text
+	case token.is("{"):
+		return p.parseBody(key+".", true)
+	case token.is("["):
+		return p.parseList(key)
+	default:
+		return p.unexpected(token)
+	}
+	return nil
+}
+
+func (p *hclParser) parseList(key string) error {
+	for index := 0; ; index++ {
+		p.skipNewlines()
+		if p.peek().is("]") {
+			p.next()
+			return nil
+		}
+		if err := p.parseValue(key + "[" + strconv.Itoa(index) + "]"); err != nil {
+			return err
+		}
+		p.skipNewlines()
+		token := p.next()
+		switch {
+		case token.is(","):
+		case token.is("]"):
+			return nil
+		default:
+			return p.unexpected(token)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package xfile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseHclAsMap(t *testing.T) {
+	kvs, err := ParseHclAsMap([]byte(`
+# 注释
+name = "demo"
+server {
+  port = 8080 // 行尾注释
+  url  = "http://${server.host}:8080"
+  /* 多行
+     注释 */
+  tags = ["a", "b",
+    "c"]
+}
+db "main" {
+  pool = { max = 10 }
+  replicas = [
+    { host = "r1" },
+    { host = "r2" },
+  ]
+}
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"name":                     "demo",
+		"server.port":              "8080",
+		"server.url":               "http://${server.host}:8080",
+		"server.tags[0]":           "a",
+		"server.tags[1]":           "b",
+		"server.tags[2]":           "c",
+		"db.main.pool.max":         "10",
+		"db.main.replicas[0].host": "r1",
+		"db.main.replicas[1].host": "r2",
+	}, kvs)
+
+	_, err = ParseHclAsMap([]byte("server {\n port = 1\n"))
+	assert.NotNil(t, err)
+	_, err = ParseHclAsMap([]byte("a = 1 b = 2"))
+	assert.NotNil(t, err)
+	_, err = ParseHclAsMap([]byte(`name = "demo`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,130 @@
+package xfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+/**
+将 ini 文件读取出来，作为 key value 格式，规则参考 ParseIniAsMap
+*/
+func ReadIniAsMap(iniFile string) (kvs map[string]string, err error) {
+	dataBytes, err := ioutil.ReadFile(iniFile)
+	if err != nil {
+		return make(map[string]string), err
+	}
+	return ParseIniAsMap(dataBytes)
+}
+
+/**
+解析 ini 内容：
+	> [section] 作为 key 的前缀，比如 [server] 下的 port 为 server.port，section 之前的配置没有前缀
+	> key = value 或者 key: value，key、value 前后的空白会被去掉
+	> ; 或者 # 开头的行为注释，没有引号的值中空白之后的 ; 或者 # 也是注释
+	> 值可以使用双引号（支持 \n 等转义）或者单引号（原样保留）
+*/
+func ParseIniAsMap(dataBytes []byte) (kvs map[string]string, err error) {
+	kvs = make(map[string]string)
+	prefix := ""
+	scanner := bufio.NewScanner(bytes.NewReader(dataBytes))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if len(line) < 1 || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.Index(line, "]")
+			if end < 0 || len(stripIniComment(line[end+1:])) > 0 {
+				return kvs, fmt.Errorf("ini 第 %d 行 section 格式错误：%s", lineNo, line)
+			}
+			section := strings.TrimSpace(line[1:end])
+			if len(section) < 1 {
+				return kvs, fmt.Errorf("ini 第 %d 行 section 为空", lineNo)
+			}
+			prefix = section + "."
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return kvs, fmt.Errorf("ini 第 %d 行缺少 = 或者 :：%s", lineNo, line)
+		}
+		key := strings.TrimSpace(line[:sep])
+		if len(key) < 1 {
+			return kvs, fmt.Errorf("ini 第 %d 行 key 为空：%s", lineNo, line)
+		}
+		value, err := parseIniValue(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			return kvs, fmt.Errorf("ini 第 %d 行值格式错误：%v", lineNo, err)
+		}
+		kvs[prefix+key] = value
+	}
+	return kvs, scanner.Err()
+}
+
+/**
+解析 ini 的值，去掉引号以及行尾注释
+*/
+func parseIniValue(text string) (string, error) {
+	if len(text) < 1 {
+		return "", nil
+	}
+	switch text[0] {
+	case '"':
+		end := closingQuoteIndex(text)
+		if end < 0 {
+			return "", fmt.Errorf("缺少结束的双引号：%s", text)
+		}
+		if len(stripIniComment(text[end+1:])) > 0 {
+			return "", fmt.Errorf("双引号之后存在多余内容：%s", text)
+		}
+		return strconv.Unquote(text[:end+1])
+	case '\'':
+		end := strings.Index(text[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("缺少结束的单引号：%s", text)
+		}
+		if len(stripIniComment(text[end+2:])) > 0 {
+			return "", fmt.Errorf("单引号之后存在多余内容：%s", text)
+		}
+		return text[1 : end+1], nil
+	}
+	return stripIniComment(text), nil
+}
+
+/**
+去掉行尾注释，注释需要以 ; 或者 # 开头，并且前面是空白或者位于开头
+*/
+func stripIniComment(text string) string {
+	for i, r := range text {
+		if (r == ';' || r == '#') && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t') {
+			return strings.TrimSpace(text[:i])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+/**
+双引号字符串结束引号的下标，text 以双引号开头，不存在返回 -1
+*/
+func closingQuoteIndex(text string) int {
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,42 @@
+package xfile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseIniAsMap(t *testing.T) {
+	kvs, err := ParseIniAsMap([]byte(`
+; 全局配置
+app.name = demo
+# 注释
+[server]
+port: 8080
+host = 127.0.0.1 ; 行尾注释
+url = http://127.0.0.1#anchor
+desc = "a ; b\n c"
+raw = 'C:\temp # not comment'
+empty =
+
+[db.main]
+password = "p#w"
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"app.name":         "demo",
+		"server.port":      "8080",
+		"server.host":      "127.0.0.1",
+		"server.url":       "http://127.0.0.1#anchor",
+		"server.desc":      "a ; b\n c",
+		"server.raw":       `C:\temp # not comment`,
+		"server.empty":     "",
+		"db.main.password": "p#w",
+	}, kvs)
+
+	_, err = ParseIniAsMap([]byte("[server\nport=1"))
+	assert.NotNil(t, err)
+	_, err = ParseIniAsMap([]byte("[server]\nport"))
+	assert.NotNil(t, err)
+	_, err = ParseIniAsMap([]byte(`name = "demo`))
+	assert.NotNil(t, err)
+}
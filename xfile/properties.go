@@ -2,55 +2,38 @@ package xfile
 
 import (
 	"bytes"
-	"errors"
+	"encoding/json"
 	"fmt"
-	"github.com/json-iterator/go"
 	"github.com/magiconair/properties"
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v2"
+	"io"
 	"io/ioutil"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
-/**
-解析为 kvs map
-*/
-func ReadAsMap(filePath string) (kvs map[string]string, err error) {
-	filename := strings.ToLower(filePath)
-
-	if strings.HasSuffix(filename, "properties") || strings.HasSuffix(filename, "prop") || strings.HasSuffix(filename, "props") {
-		return ReadPropertiesAsMap(filePath)
-	}
-
-	if strings.HasSuffix(filename, "yaml") || strings.HasSuffix(filename, "yml") {
-		return ReadYamlAsMap(filePath)
-	}
-	kvs = make(map[string]string)
-	return kvs, errors.New("不支持的 properties 文件类型")
-}
-
 /**
 读取配置
 */
 func ReadPropertiesAsMap(propertiesFile string) (kvs map[string]string, err error) {
-	kvs = make(map[string]string)
 	file, err := afero.ReadFile(afero.NewOsFs(), propertiesFile)
 	if err != nil {
 		return nil, err
 	}
+	return ParsePropertiesAsMap(file)
+}
 
-	in := bytes.NewReader(file)
-	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(in)
-	if err != nil {
-		return nil, err
-	}
-
+/**
+解析 properties 内容
+*/
+func ParsePropertiesAsMap(dataBytes []byte) (kvs map[string]string, err error) {
+	kvs = make(map[string]string)
 	tempProperties := properties.NewProperties()
 	tempProperties.Postfix = ""
 	tempProperties.Prefix = ""
-	err = tempProperties.Load(buf.Bytes(), properties.UTF8)
+	err = tempProperties.Load(dataBytes, properties.UTF8)
 	if err != nil {
 		return nil, err
 	}
@@ -64,32 +47,136 @@ func ReadPropertiesAsMap(propertiesFile string) (kvs map[string]string, err erro
 }
 
 /**
-将 Yaml 文件读取出来，作为 key value 格式
+读取 yaml 的选项
 */
-func ReadYamlAsMap(yamlFile string) (kvs map[string]string, err error) {
+type YamlOption func(options *yamlOptions)
+
+type yamlOptions struct {
+	keepListJson bool
+}
+
+var defaultKeepYamlListJson = true
+
+/**
+读取 yaml 的时候，除了把数组展开成 servers[0].host 形式之外，是否同时保留数组的 JSON 形式，即 servers=[{"host":"a"}]，
+兼容旧版本只支持 JSON 形式的用法（比如 GetProperty("servers")），默认保留，可以通过 KeepYamlListJson 单次指定
+*/
+func SetKeepYamlListJson(keep bool) {
+	defaultKeepYamlListJson = keep
+}
+
+/**
+是否同时保留数组的 JSON 形式，参考 SetKeepYamlListJson
+*/
+func KeepYamlListJson(keep bool) YamlOption {
+	return func(options *yamlOptions) {
+		options.keepListJson = keep
+	}
+}
+
+/**
+将 Yaml 文件读取出来，作为 key value 格式：
+	> 对象按照 . 展开，比如 server.port
+	> 数组按照下标展开，比如 servers[0].host，可以通过 KeepYamlListJson 同时保留 JSON 形式
+	> 支持锚点、合并（<<）以及多文档（---），后面的文档覆盖前面文档相同的 key
+*/
+func ReadYamlAsMap(yamlFile string, options ...YamlOption) (kvs map[string]string, err error) {
 	kvs = make(map[string]string)
 	dataBytes, err := ioutil.ReadFile(yamlFile)
 	if err != nil {
 		return kvs, err
 	}
+	return ParseYamlAsMap(dataBytes, options...)
+}
 
+/**
+解析 yaml 内容，规则参考 ReadYamlAsMap
+*/
+func ParseYamlAsMap(dataBytes []byte, options ...YamlOption) (kvs map[string]string, err error) {
+	opts := &yamlOptions{keepListJson: defaultKeepYamlListJson}
+	for _, option := range options {
+		option(opts)
+	}
+
+	kvs = make(map[string]string)
+	decoder := yaml.NewDecoder(bytes.NewReader(dataBytes))
+	for {
+		data := make(map[string]interface{})
+		err = decoder.Decode(&data)
+		if err == io.EOF {
+			return kvs, nil
+		}
+		if nil != err {
+			return kvs, err
+		}
+		docKvs := make(map[string]string)
+		for k, v := range data {
+			if err = objectToKvs(k, v, docKvs, opts); err != nil {
+				return kvs, err
+			}
+		}
+		mergeYamlDocument(kvs, docKvs)
+	}
+}
+
+/**
+解析 json 内容，顶层必须是对象，展开规则和 ReadYamlAsMap 一致，数字保留原始文本，null 忽略
+*/
+func ParseJsonAsMap(dataBytes []byte, options ...YamlOption) (kvs map[string]string, err error) {
+	opts := &yamlOptions{keepListJson: defaultKeepYamlListJson}
+	for _, option := range options {
+		option(opts)
+	}
+
+	kvs = make(map[string]string)
 	data := make(map[string]interface{})
-	err = yaml.Unmarshal(dataBytes, data)
-	if nil != err {
+	decoder := json.NewDecoder(bytes.NewReader(dataBytes))
+	decoder.UseNumber()
+	if err = decoder.Decode(&data); err != nil {
 		return kvs, err
 	}
 	for k, v := range data {
-		objectToKvs(k, v, kvs)
+		if err = objectToKvs(k, v, kvs, opts); err != nil {
+			return kvs, err
+		}
+	}
+	return kvs, nil
+}
+
+/**
+合并多文档，后面文档的 key 覆盖前面的；后面文档中的数组整体替换前面文档中的同名数组，不按照下标合并
+*/
+func mergeYamlDocument(kvs map[string]string, docKvs map[string]string) {
+	lists := make(map[string]bool)
+	for key := range docKvs {
+		for i := strings.Index(key, "["); i > 0; {
+			lists[key[:i]] = true
+			next := strings.Index(key[i+1:], "[")
+			if next < 0 {
+				break
+			}
+			i = i + 1 + next
+		}
+	}
+	for key := range kvs {
+		for list := range lists {
+			if strings.HasPrefix(key, list+"[") {
+				delete(kvs, key)
+				break
+			}
+		}
+	}
+	for key, value := range docKvs {
+		kvs[key] = value
 	}
-	return
 }
 
 /**
 对象转成 kvs
 */
-func objectToKvs(pKey string, obj interface{}, kvs map[string]string) {
+func objectToKvs(pKey string, obj interface{}, kvs map[string]string, opts *yamlOptions) error {
 	if nil == obj {
-		return
+		return nil
 	}
 	objType := reflect.TypeOf(obj)
 
@@ -98,13 +185,21 @@ func objectToKvs(pKey string, obj interface{}, kvs map[string]string) {
 	}
 
 	if objType.Kind() == reflect.Array || objType.Kind() == reflect.Slice {
-		// 数组的话，直接转成json
-		dBytes, err := jsoniter.Marshal(obj)
-		if nil != err {
-			fmt.Println("err:", err)
+		if opts.keepListJson {
+			// 兼容旧版本，同时保留 json 格式
+			dBytes, err := json.Marshal(toJsonCompatible(obj))
+			if nil != err {
+				return fmt.Errorf("配置项[%s]的数组无法转换成 JSON：%v", pKey, err)
+			}
+			kvs[pKey] = string(dBytes)
+		}
+		objVal := reflect.ValueOf(obj)
+		for i := 0; i < objVal.Len(); i++ {
+			if err := objectToKvs(pKey+"["+strconv.Itoa(i)+"]", objVal.Index(i).Interface(), kvs, opts); err != nil {
+				return err
+			}
 		}
-		kvs[pKey] = string(dBytes)
-		return
+		return nil
 	}
 
 	// 部署数组，看看是不是map
@@ -114,9 +209,11 @@ func objectToKvs(pKey string, obj interface{}, kvs map[string]string) {
 		for _, mK := range mKeys {
 			val := objVal.MapIndex(mK)
 			key := fmt.Sprintf("%v", mK.Interface())
-			objectToKvs(pKey+"."+key, val.Interface(), kvs)
+			if err := objectToKvs(pKey+"."+key, val.Interface(), kvs, opts); err != nil {
+				return err
+			}
 		}
-		return
+		return nil
 	}
 
 	// 看看是不是原生对象，Struct 对象
@@ -127,10 +224,40 @@ func objectToKvs(pKey string, obj interface{}, kvs map[string]string) {
 			vfield := objVal.Field(i)
 
 			fieldName := tfield.Name
-			objectToKvs(pKey+"."+fieldName, vfield.Interface(), kvs)
+			if err := objectToKvs(pKey+"."+fieldName, vfield.Interface(), kvs, opts); err != nil {
+				return err
+			}
 		}
-		return
+		return nil
 	}
 	// 默认
 	kvs[pKey] = fmt.Sprintf("%v", obj)
+	return nil
+}
+
+/**
+yaml 解析出来的 map 的 key 是 interface{} 类型，转成 map[string]interface{} 才能转成 json
+*/
+func toJsonCompatible(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[interface{}]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			values[fmt.Sprintf("%v", key)] = toJsonCompatible(value)
+		}
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			values[key] = toJsonCompatible(value)
+		}
+		return values
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = toJsonCompatible(value)
+		}
+		return values
+	}
+	return obj
 }
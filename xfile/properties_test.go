@@ -2,6 +2,7 @@ package xfile
 
 import (
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"os"
 	"path/filepath"
 	"testing"
@@ -35,3 +36,53 @@ func TestDirTest(t *testing.T) {
 	wd, _ := os.Getwd()
 	fmt.Println(filepath.Abs(wd + "/../../xver"))
 }
+
+func TestParseYamlAsMap(t *testing.T) {
+	data := []byte(`
+defaults: &defaults
+  timeout: 10s
+  retry: 3
+servers:
+  - <<: *defaults
+    host: a
+    port: 80
+  - <<: *defaults
+    host: b
+    retry: 5
+names: [A, B]
+matrix:
+  - [1, 2]
+---
+names: [C]
+extra: true
+`)
+	kvs, err := ParseYamlAsMap(data, KeepYamlListJson(false))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"defaults.timeout":   "10s",
+		"defaults.retry":     "3",
+		"servers[0].host":    "a",
+		"servers[0].port":    "80",
+		"servers[0].timeout": "10s",
+		"servers[0].retry":   "3",
+		"servers[1].host":    "b",
+		"servers[1].retry":   "5",
+		"servers[1].timeout": "10s",
+		"names[0]":           "C",
+		"matrix[0][0]":       "1",
+		"matrix[0][1]":       "2",
+		"extra":              "true",
+	}, kvs)
+
+	// 默认同时保留 JSON 形式
+	kvs, err = ParseYamlAsMap([]byte("servers:\n  - host: a\n    port: 80\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"servers":         `[{"host":"a","port":80}]`,
+		"servers[0].host": "a",
+		"servers[0].port": "80",
+	}, kvs)
+
+	_, err = ParseYamlAsMap([]byte("a: [1, 2"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,431 @@
+package xfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+配置 key 拆分之后的一段，name 为对象属性名，index >= 0 的话为数组下标
+*/
+type keySegment struct {
+	name  string
+	index int
+	text  string // 原始文本，用于还原 key
+}
+
+var keyIndexRegex = regexp.MustCompile(`\[(\d+)\]$`)
+
+/**
+key 中 [n] 下标的最大值，超过的话不作为数组下标，作为普通 key 处理，避免 servers[999999999] 这样的配置分配过大的数组
+*/
+const MaxKeyIndex = 65535
+
+/**
+拆分配置 key，比如 servers[0].host => servers, [0], host
+*/
+func splitKey(key string) []keySegment {
+	segments := make([]keySegment, 0)
+	for i, part := range strings.Split(key, ".") {
+		name := part
+		indexes := make([]keySegment, 0)
+		// 只解析结尾连续的 [n]，并且前面需要有属性名
+		for {
+			loc := keyIndexRegex.FindStringSubmatchIndex(name)
+			if loc == nil || loc[0] == 0 {
+				break
+			}
+			index, err := strconv.Atoi(name[loc[2]:loc[3]])
+			if err != nil || index > MaxKeyIndex {
+				break
+			}
+			indexes = append([]keySegment{{index: index, text: name[loc[0]:loc[1]]}}, indexes...)
+			name = name[:loc[0]]
+		}
+		text := name
+		if i > 0 {
+			text = "." + name
+		}
+		segments = append(segments, keySegment{name: name, index: -1, text: text})
+		segments = append(segments, indexes...)
+	}
+	return segments
+}
+
+/**
+un-flatten 过程中的节点
+*/
+type flatNode struct {
+	leaf   bool
+	value  interface{}
+	fields map[string]*flatNode
+	items  map[int]*flatNode
+}
+
+func (n *flatNode) isEmpty() bool {
+	return !n.leaf && len(n.fields) == 0 && len(n.items) == 0
+}
+
+func (n *flatNode) toValue() interface{} {
+	if n.leaf {
+		return n.value
+	}
+	if n.items != nil {
+		size := 0
+		for index := range n.items {
+			if index+1 > size {
+				size = index + 1
+			}
+		}
+		items := make([]interface{}, size)
+		for index, item := range n.items {
+			items[index] = item.toValue()
+		}
+		return items
+	}
+	values := make(map[string]interface{}, len(n.fields))
+	for name, field := range n.fields {
+		values[name] = field.toValue()
+	}
+	return values
+}
+
+/**
+按照 key 中的 . 以及 [n] 还原成嵌套结构，对象为 map[string]interface{}，数组为 []interface{}，值为 string，
+参考 UnflattenFunc
+*/
+func Unflatten(kvs map[string]string) map[string]interface{} {
+	return UnflattenFunc(kvs, func(key, value string) interface{} {
+		return value
+	})
+}
+
+/**
+按照 key 中的 . 以及 [n] 还原成嵌套结构，叶子节点的值由 leaf 生成；
+某个 key 和其他 key 的层级冲突的时候，比如 a=1、a.b=2，冲突部分不再拆分，结果为 {"a": "1", "a.b": "2"}，
+再次读取之后仍然是原来的 key
+*/
+func UnflattenFunc(kvs map[string]string, leaf func(key, value string) interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	root := &flatNode{fields: make(map[string]*flatNode)}
+	for _, key := range keys {
+		insertFlatNode(root, splitKey(key), &flatNode{leaf: true, value: leaf(key, kvs[key])})
+	}
+	return root.toValue().(map[string]interface{})
+}
+
+func insertFlatNode(root *flatNode, segments []keySegment, leaf *flatNode) {
+	// 最近的对象节点以及对应的 segment 下标，层级冲突的时候剩余的部分作为一个 key 放到这个对象下
+	object, objectAt := root, 0
+	node := root
+	for i, segment := range segments {
+		var next *flatNode
+		if segment.index < 0 {
+			if node.leaf || node.items != nil {
+				break
+			}
+			if node.fields == nil {
+				node.fields = make(map[string]*flatNode)
+			}
+			object, objectAt = node, i
+			if next = node.fields[segment.name]; next == nil {
+				next = &flatNode{}
+				node.fields[segment.name] = next
+			}
+		} else {
+			if node.leaf || node.fields != nil {
+				break
+			}
+			if node.items == nil {
+				node.items = make(map[int]*flatNode)
+			}
+			if next = node.items[segment.index]; next == nil {
+				next = &flatNode{}
+				node.items[segment.index] = next
+			}
+		}
+		if i == len(segments)-1 {
+			if next.isEmpty() {
+				*next = *leaf
+				return
+			}
+			break
+		}
+		node = next
+	}
+
+	// 层级冲突
+	buf := bytes.Buffer{}
+	for _, segment := range segments[objectAt:] {
+		buf.WriteString(segment.text)
+	}
+	object.fields[strings.TrimPrefix(buf.String(), ".")] = leaf
+}
+
+/**
+按照 key 排序输出 properties 格式，参考 WritePropertiesWithComments
+*/
+func WriteProperties(w io.Writer, kvs map[string]string) error {
+	return WritePropertiesWithComments(w, kvs, nil)
+}
+
+/**
+按照 key 排序输出 properties 格式，key、value 按照 properties 规范转义
+@param comments 配置项注释，会输出在配置项的上一行，可以为 nil
+*/
+func WritePropertiesWithComments(w io.Writer, kvs map[string]string, comments map[string]string) error {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.Buffer{}
+	for _, key := range keys {
+		if comment, ok := comments[key]; ok {
+			for _, line := range strings.Split(comment, "\n") {
+				buf.WriteString("# " + line + "\n")
+			}
+		}
+		buf.WriteString(escapeProperties(key, true) + "=" + escapeProperties(kvs[key], false) + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+/**
+按照 properties 规范转义
+@param isKey key 中的 =、: 以及空格也需要转义
+*/
+func escapeProperties(text string, isKey bool) string {
+	buf := bytes.Buffer{}
+	for i, r := range text {
+		switch r {
+		case '\\':
+			buf.WriteString("\\\\")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\r':
+			buf.WriteString("\\r")
+		case '\t':
+			buf.WriteString("\\t")
+		case '\f':
+			buf.WriteString("\\f")
+		case '=', ':':
+			if isKey {
+				buf.WriteRune('\\')
+			}
+			buf.WriteRune(r)
+		case '#', '!':
+			if i == 0 {
+				buf.WriteRune('\\')
+			}
+			buf.WriteRune(r)
+		case ' ':
+			if isKey || i == 0 {
+				buf.WriteRune('\\')
+			}
+			buf.WriteRune(r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+/**
+还原成嵌套结构之后输出 yaml 格式，参考 Unflatten、WriteYamlWithComments
+*/
+func WriteYaml(w io.Writer, kvs map[string]string) error {
+	return WriteYamlWithComments(w, kvs, nil)
+}
+
+/**
+还原成嵌套结构之后输出 yaml 格式，字符串值在需要的时候使用双引号，保证再次读取的结果一致；
+数组同时存在 JSON 形式以及 key[n] 形式的配置（参考 KeepYamlListJson）的话只输出 key[n] 形式
+@param comments 配置项注释，以行尾注释的形式输出，可以为 nil
+*/
+func WriteYamlWithComments(w io.Writer, kvs map[string]string, comments map[string]string) error {
+	buf := &bytes.Buffer{}
+	writeYamlMap(buf, Unflatten(withoutListJson(kvs)), "", "", comments)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeYamlMap(buf *bytes.Buffer, values map[string]interface{}, indent string, path string, comments map[string]string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := name
+		if len(path) > 0 {
+			key = path + "." + name
+		}
+		buf.WriteString(indent + yamlScalar(name) + ":")
+		writeYamlValue(buf, values[name], indent, key, comments)
+	}
+}
+
+/**
+输出 key: 之后的部分
+*/
+func writeYamlValue(buf *bytes.Buffer, value interface{}, indent string, key string, comments map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYamlMap(buf, v, indent+"  ", key, comments)
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYamlList(buf, v, indent+"  ", key, comments)
+	case nil:
+		buf.WriteString(" null\n")
+	default:
+		buf.WriteString(" " + yamlScalar(v.(string)))
+		if comment, ok := comments[key]; ok {
+			buf.WriteString(" # " + strings.Replace(comment, "\n", " ", -1))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+func writeYamlList(buf *bytes.Buffer, items []interface{}, indent string, path string, comments map[string]string) {
+	for i, item := range items {
+		key := path + "[" + strconv.Itoa(i) + "]"
+		switch v := item.(type) {
+		case map[string]interface{}, []interface{}:
+			if isEmptyYamlContainer(v) {
+				buf.WriteString(indent + "-")
+				writeYamlValue(buf, v, indent, key, comments)
+				continue
+			}
+			// 第一行放在 - 之后，其余行和第一行对齐
+			itemBuf := &bytes.Buffer{}
+			if values, ok := v.(map[string]interface{}); ok {
+				writeYamlMap(itemBuf, values, indent+"  ", key, comments)
+			} else {
+				writeYamlList(itemBuf, v.([]interface{}), indent+"  ", key, comments)
+			}
+			buf.WriteString(indent + "- " + strings.TrimPrefix(itemBuf.String(), indent+"  "))
+		default:
+			buf.WriteString(indent + "-")
+			writeYamlValue(buf, v, indent, key, comments)
+		}
+	}
+}
+
+func isEmptyYamlContainer(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+var yamlPlainScalarRegex = regexp.MustCompile(`^[A-Za-z_/$][A-Za-z0-9_./$@-]*$`)
+
+/**
+yaml 标量，可能被解析成非字符串或者需要转义的使用双引号
+*/
+func yamlScalar(text string) string {
+	if yamlPlainScalarRegex.MatchString(text) {
+		switch strings.ToLower(text) {
+		case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		default:
+			return text
+		}
+	}
+	return strconv.Quote(text)
+}
+
+/**
+还原成嵌套结构之后输出 json 格式，参考 Unflatten，数组的 JSON 形式的处理和 WriteYamlWithComments 一致
+*/
+func WriteJson(w io.Writer, kvs map[string]string) error {
+	data, err := json.MarshalIndent(Unflatten(withoutListJson(kvs)), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+/**
+去掉存在 key[n] 形式配置的数组的 JSON 形式，避免还原成嵌套结构的时候和数组冲突，比如 servers 以及 servers[0].host
+*/
+func withoutListJson(kvs map[string]string) map[string]string {
+	lists := make(map[string]bool)
+	for key := range kvs {
+		for i := strings.Index(key, "["); i > 0; {
+			lists[key[:i]] = true
+			next := strings.Index(key[i+1:], "[")
+			if next < 0 {
+				break
+			}
+			i = i + 1 + next
+		}
+	}
+	if len(lists) < 1 {
+		return kvs
+	}
+	values := make(map[string]string, len(kvs))
+	for key, value := range kvs {
+		// JSON 形式的值是数组，其他同名的配置保留
+		if !lists[key] || !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+/**
+写入 properties 文件，参考 WriteProperties
+*/
+func WritePropertiesFile(filePath string, kvs map[string]string) error {
+	return writeFile(filePath, kvs, WriteProperties)
+}
+
+/**
+写入 yaml 文件，参考 WriteYaml
+*/
+func WriteYamlFile(filePath string, kvs map[string]string) error {
+	return writeFile(filePath, kvs, WriteYaml)
+}
+
+/**
+写入 json 文件，参考 WriteJson
+*/
+func WriteJsonFile(filePath string, kvs map[string]string) error {
+	return writeFile(filePath, kvs, WriteJson)
+}
+
+func writeFile(filePath string, kvs map[string]string, writer func(w io.Writer, kvs map[string]string) error) error {
+	buf := &bytes.Buffer{}
+	if err := writer(buf, kvs); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, buf.Bytes(), os.ModePerm&0644)
+}
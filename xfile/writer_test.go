@@ -0,0 +1,201 @@
+package xfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnflatten(t *testing.T) {
+	values := Unflatten(map[string]string{
+		"app.name":              "demo",
+		"app.timeout":           "10s",
+		"app.timeout.unit":      "s",
+		"servers[0].host":       "a",
+		"servers[0].port":       "80",
+		"servers[1].host":       "b",
+		"matrix[0][1]":          "x",
+		"tags[0]":               "t1",
+		"tags[1]":               "t2",
+		"tags.size":             "2",
+		"[0]":                   "weird",
+		"servers[1].alias[0]":   "b1",
+		"servers[1].alias.size": "1",
+		"ports[99999999]":       "8080",
+	})
+	assert.Equal(t, map[string]interface{}{
+		"app": map[string]interface{}{
+			"name":         "demo",
+			"timeout":      "10s",
+			"timeout.unit": "s",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a", "port": "80"},
+			// 按照 key 排序，alias.size 在 alias[0] 之前，alias 为对象
+			map[string]interface{}{"host": "b", "alias": map[string]interface{}{"size": "1"}, "alias[0]": "b1"},
+		},
+		"matrix":  []interface{}{[]interface{}{nil, "x"}},
+		"tags":    map[string]interface{}{"size": "2"},
+		"tags[0]": "t1",
+		"tags[1]": "t2",
+		"[0]":     "weird",
+		// 超过 MaxKeyIndex 的下标作为普通 key
+		"ports[99999999]": "8080",
+	}, values)
+}
+
+func TestWriteProperties(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WritePropertiesWithComments(buf, map[string]string{
+		"b.key":    " leading space",
+		"a.key":    "line1\nline2\\",
+		"c key=:x": "#not comment",
+	}, map[string]string{"a.key": "source: test"})
+	assert.Nil(t, err)
+	assert.Equal(t, "# source: test\n"+
+		"a.key=line1\\nline2\\\\\n"+
+		"b.key=\\ leading space\n"+
+		"c\\ key\\=\\:x=\\#not comment\n", buf.String())
+}
+
+func TestWriteYaml(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteYamlWithComments(buf, map[string]string{
+		"app.name":        "demo",
+		"app.port":        "8080",
+		"app.enabled":     "true",
+		"servers[0].host": "a",
+		"servers[0].port": "80",
+		"servers[1].host": "b",
+		"tags[0]":         "t1",
+	}, map[string]string{"app.name": "source: test", "servers[1].host": "source: file"})
+	assert.Nil(t, err)
+	assert.Equal(t, "app:\n"+
+		"  enabled: \"true\"\n"+
+		"  name: demo # source: test\n"+
+		"  port: \"8080\"\n"+
+		"servers:\n"+
+		"  - host: a\n"+
+		"    port: \"80\"\n"+
+		"  - host: b # source: file\n"+
+		"tags:\n"+
+		"  - t1\n", buf.String())
+}
+
+func TestWriteJson(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteJson(buf, map[string]string{"app.name": "demo", "servers[0].host": "a"})
+	assert.Nil(t, err)
+	values := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &values))
+	assert.Equal(t, map[string]interface{}{
+		"app":     map[string]interface{}{"name": "demo"},
+		"servers": []interface{}{map[string]interface{}{"host": "a"}},
+	}, values)
+}
+
+func TestWriteFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	kvs := map[string]string{
+		"app.name":         "demo",
+		"app.port":         "8080",
+		"app.ratio":        "1.50",
+		"app.enabled":      "yes",
+		"app.empty":        "",
+		"app.url":          "http://${app.host}:8080/a?b=c#d",
+		"app.desc":         " a: b\n\tc \\ \"d\"",
+		"app.timeout":      "10s",
+		"app.timeout.unit": "s",
+		"app.中文":           "值",
+		"app.map.0":        "zero",
+		"servers[0].host":  "a",
+		"servers[0].port":  "80",
+		"servers[1].host":  "b",
+		"tags[0]":          "t1",
+		"tags[1]":          "t2",
+	}
+	for _, name := range []string{"application.properties", "application.yml"} {
+		file := filepath.Join(dir, name)
+		var readKvs map[string]string
+		if filepath.Ext(name) == ".yml" {
+			assert.Nil(t, WriteYamlFile(file, kvs))
+			// 写入的时候只有 key[n] 形式，读取的时候不保留数组的 JSON 形式
+			readKvs, err = ReadYamlAsMap(file, KeepYamlListJson(false))
+		} else {
+			assert.Nil(t, WritePropertiesFile(file, kvs))
+			readKvs, err = ReadAsMap(file)
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, kvs, readKvs, name)
+	}
+
+	file := filepath.Join(dir, "application.json")
+	assert.Nil(t, WriteJsonFile(file, kvs))
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	values := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(data, &values))
+	assert.Equal(t, "s", values["app"].(map[string]interface{})["timeout.unit"])
+}
+
+func TestWriteListRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// 默认同时保留数组的 JSON 形式
+	kvs, err := ParseYamlAsMap([]byte("app:\n" +
+		"  name: demo\n" +
+		"servers:\n" +
+		"  - host: a\n" +
+		"    port: \"80\"\n" +
+		"    alias: [a1, a2]\n" +
+		"  - host: b\n" +
+		"matrix:\n" +
+		"  - [x, z]\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"alias":["a1","a2"],"host":"a","port":"80"},{"host":"b"}]`, kvs["servers"])
+	assert.Equal(t, "a2", kvs["servers[0].alias[1]"])
+	assert.Equal(t, "z", kvs["matrix[0][1]"])
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteYaml(buf, kvs))
+	assert.NotContains(t, buf.String(), "servers[0]")
+	readKvs, err := ParseYamlAsMap(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, kvs, readKvs)
+
+	for _, name := range []string{"application.yml", "application.json"} {
+		file := filepath.Join(dir, name)
+		if filepath.Ext(name) == ".yml" {
+			assert.Nil(t, WriteYamlFile(file, kvs))
+		} else {
+			assert.Nil(t, WriteJsonFile(file, kvs))
+		}
+		readKvs, err = ReadAsMap(file)
+		assert.Nil(t, err)
+		assert.Equal(t, kvs, readKvs, name)
+	}
+}
+
+func TestParseJsonAsMap(t *testing.T) {
+	kvs, err := ParseJsonAsMap([]byte(`{"app": {"port": 8080, "ratio": 1.50, "enabled": true, "none": null}, "tags": ["t1", "t2"]}`), KeepYamlListJson(false))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"app.port":    "8080",
+		"app.ratio":   "1.50",
+		"app.enabled": "true",
+		"tags[0]":     "t1",
+		"tags[1]":     "t2",
+	}, kvs)
+
+	_, err = ParseJsonAsMap([]byte(`["t1"]`))
+	assert.NotNil(t, err)
+}
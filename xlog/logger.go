@@ -62,16 +62,16 @@ func ParseLevel(level string) Level {
 日志配置
 */
 type Properties struct {
-	Level            string `ck:"level" def:"DEBUG"`                         // 日志级别: DEBUG, INFO, WARN, ERROR, FATAL， 默认是： DEBUG
-	Dir              string `ck:"dir" def:"./logs"`                          // 日志存放目录, 默认是 ./logs
-	Filename         string `ck:"filename" def:"app.log"`                    // 文件名，含后缀, 默认：app.log
-	TimeFormat       string `ck:"time-format" def:"2006-01-02 15:04:05.000"` // 时间格式，默认是 2006-01-02 15:04:05.000
-	MaxSize          int    `ck:"max-size" def:"500"`                        // 单个配置文件大小最大限制，单位：M，默认是 500 M
-	MaxBackups       int    `ck:"max-backups" def:"30"`                      // 最多保留多少个日志文件，默认 30
-	MaxAge           int    `ck:"max-age" def:"30"`                          // 日志文件存活时间，单位：天，默认是30天
-	Compress         bool   `ck:"compress" def:"false"`                      // 是否需要自动gzip进行压缩，默认：false
-	ConsoleLog       bool   `ck:"console-log" def:"false"`                   // 是否需要输出控制台日志，默认是 false
-	CallerSkipOffset int    `ck:"caller-skip-offset" def:"0"`                // 输出日志时候，计算输入日志的日志所在文件和行数偏移，一般给应用进行二次封装使用，正负数都可以
+	Level            string `ck:"level" def:"DEBUG" desc:"日志级别: DEBUG, INFO, WARN, ERROR, FATAL"` // 日志级别: DEBUG, INFO, WARN, ERROR, FATAL， 默认是： DEBUG
+	Dir              string `ck:"dir" def:"./logs" desc:"日志存放目录"`                                 // 日志存放目录, 默认是 ./logs
+	Filename         string `ck:"filename" def:"app.log" desc:"日志文件名，含后缀"`                        // 文件名，含后缀, 默认：app.log
+	TimeFormat       string `ck:"time-format" def:"2006-01-02 15:04:05.000" desc:"日志时间格式"`        // 时间格式，默认是 2006-01-02 15:04:05.000
+	MaxSize          int    `ck:"max-size" def:"500" desc:"单个日志文件大小最大限制，单位：M"`                    // 单个配置文件大小最大限制，单位：M，默认是 500 M
+	MaxBackups       int    `ck:"max-backups" def:"30" desc:"最多保留多少个日志文件"`                        // 最多保留多少个日志文件，默认 30
+	MaxAge           int    `ck:"max-age" def:"30" desc:"日志文件存活时间，单位：天"`                          // 日志文件存活时间，单位：天，默认是30天
+	Compress         bool   `ck:"compress" def:"false" desc:"是否需要自动gzip进行压缩"`                     // 是否需要自动gzip进行压缩，默认：false
+	ConsoleLog       bool   `ck:"console-log" def:"false" desc:"是否需要输出控制台日志"`                     // 是否需要输出控制台日志，默认是 false
+	CallerSkipOffset int    `ck:"caller-skip-offset" def:"0" desc:"计算日志所在文件和行数的偏移，一般给应用进行二次封装使用"` // 输出日志时候，计算输入日志的日志所在文件和行数偏移，一般给应用进行二次封装使用，正负数都可以
 }
 
 func (p *Properties) Equals(properties *Properties) bool {
@@ -0,0 +1,73 @@
+package xplaceholder
+
+import (
+	"strings"
+)
+
+/**
+占位符循环引用，比如 a=${b}、b=${a}
+*/
+type CircularPlaceholderError struct {
+	Placeholder string   // 循环引用的占位符
+	Chain       []string // 占位符链，从最外层的占位符开始，最后一个就是 Placeholder，比如 [a b a]
+}
+
+func (e *CircularPlaceholderError) Error() string {
+	return "Circular placeholder reference '" + e.Placeholder + "' in property definitions: " + strings.Join(e.Chain, " -> ")
+}
+
+/**
+无法识别的占位符，即占位符对应的配置项不存在并且没有默认值
+*/
+type UnresolvablePlaceholderError struct {
+	Placeholder string   // 无法识别的占位符
+	Value       string   // 包含这个占位符的文本
+	Chain       []string // 占位符链，从最外层的占位符开始，最后一个就是 Placeholder
+}
+
+func (e *UnresolvablePlaceholderError) Error() string {
+	msg := "Could not resolve placeholder '" + e.Placeholder + "'" + " in value \"" + e.Value + "\""
+	if len(e.Chain) > 1 {
+		msg += ", placeholder chain: " + strings.Join(e.Chain, " -> ")
+	}
+	return msg
+}
+
+/**
+正在处理的占位符链，用于检查循环引用以及出错时定位
+*/
+type placeholderChain struct {
+	visited map[string]bool
+	stack   []string
+}
+
+func newPlaceholderChain() *placeholderChain {
+	return &placeholderChain{visited: make(map[string]bool)}
+}
+
+/**
+占位符入栈，已经在链中的话返回 false，即存在循环引用
+*/
+func (c *placeholderChain) push(placeholder string) bool {
+	if c.visited[placeholder] {
+		return false
+	}
+	c.visited[placeholder] = true
+	c.stack = append(c.stack, placeholder)
+	return true
+}
+
+func (c *placeholderChain) pop() {
+	last := len(c.stack) - 1
+	delete(c.visited, c.stack[last])
+	c.stack = c.stack[:last]
+}
+
+/**
+当前的占位符链，可以追加额外的占位符
+*/
+func (c *placeholderChain) keys(extra ...string) []string {
+	keys := make([]string, 0, len(c.stack)+len(extra))
+	keys = append(keys, c.stack...)
+	return append(keys, extra...)
+}
@@ -31,19 +31,32 @@ type PropertyPlaceholderHelper struct {
 	minValueLen                    int    // 要进行占位符处理的值最小长度
 }
 
+/**
+替换占位符，存在循环引用或者无法识别的占位符（ignoreUnresolvablePlaceholders 为 false）的话 panic，
+和旧版本一样 panic 的值为异常信息字符串，需要 *CircularPlaceholderError 或者 *UnresolvablePlaceholderError 的话使用 ReplacePlaceholdersE
+*/
 func (h *PropertyPlaceholderHelper) ReplacePlaceholders(value string, placeholderResolver func(key string) string) string {
-	if len(value) < h.minValueLen {
-		// 不需要进行处理， 加起来还没有占位符长
-		return value
+	value, err := h.ReplacePlaceholdersE(value, placeholderResolver)
+	if err != nil {
+		panic(err.Error())
 	}
-	value, _ = h.parseStringValue(value, nil, placeholderResolver)
 	return value
 }
 
-func (h *PropertyPlaceholderHelper) parseStringValue(value string, visitedPlaceholders map[string]bool, placeholderResolver func(key string) string) (val string, visited map[string]bool) {
-	if visitedPlaceholders == nil {
-		visitedPlaceholders = make(map[string]bool)
+/**
+替换占位符，存在循环引用或者无法识别的占位符（ignoreUnresolvablePlaceholders 为 false）的话返回异常，
+异常为 *CircularPlaceholderError 或者 *UnresolvablePlaceholderError，包含出错时的占位符链
+*/
+func (h *PropertyPlaceholderHelper) ReplacePlaceholdersE(value string, placeholderResolver func(key string) string) (string, error) {
+	if len(value) < h.minValueLen {
+		// 不需要进行处理， 加起来还没有占位符长
+		return value, nil
 	}
+	return h.parseStringValue(value, newPlaceholderChain(), placeholderResolver)
+}
+
+func (h *PropertyPlaceholderHelper) parseStringValue(value string, chain *placeholderChain, placeholderResolver func(key string) string) (string, error) {
+	var err error
 	result := value
 	startIndex := strings.Index(value, h.placeholderPrefix)
 	for startIndex != -1 {
@@ -51,13 +64,14 @@ func (h *PropertyPlaceholderHelper) parseStringValue(value string, visitedPlaceh
 		if endIndex != -1 {
 			placeholder := result[startIndex+len(h.placeholderPrefix) : endIndex] // 注意，endIndex 是不包含进来的
 			originalPlaceholder := placeholder
-			if _, dup := visitedPlaceholders[originalPlaceholder]; dup {
-				errMsg := "Circular placeholder reference '" + originalPlaceholder + "' in property definitions"
-				panic(errMsg)
+			if !chain.push(originalPlaceholder) {
+				return result, &CircularPlaceholderError{Placeholder: originalPlaceholder, Chain: chain.keys(originalPlaceholder)}
 			}
-			visitedPlaceholders[originalPlaceholder] = true
 			// 递归处理
-			placeholder, visitedPlaceholders = h.parseStringValue(placeholder, visitedPlaceholders, placeholderResolver)
+			placeholder, err = h.parseStringValue(placeholder, chain, placeholderResolver)
+			if err != nil {
+				return result, err
+			}
 			propVal := placeholderResolver(placeholder)
 			useDefault := false
 			if len(propVal) == 0 && len(h.valueSeparator) > 0 {
@@ -74,7 +88,10 @@ func (h *PropertyPlaceholderHelper) parseStringValue(value string, visitedPlaceh
 			}
 			if len(propVal) > 0 || useDefault {
 				if !useDefault {
-					propVal, visitedPlaceholders = h.parseStringValue(propVal, visitedPlaceholders, placeholderResolver)
+					propVal, err = h.parseStringValue(propVal, chain, placeholderResolver)
+					if err != nil {
+						return result, err
+					}
 				}
 				// 将解析出来的值进行替换
 				result, _ = xstr.ReplaceRange(result, propVal, startIndex, endIndex+len(h.placeholderSuffix))
@@ -83,15 +100,14 @@ func (h *PropertyPlaceholderHelper) parseStringValue(value string, visitedPlaceh
 				// Proceed with unprocessed value.
 				startIndex, _ = xstr.IndexFrom(result, h.placeholderPrefix, endIndex+len(h.placeholderSuffix))
 			} else {
-				errMsg := "Could not resolve placeholder '" + placeholder + "'" + " in value \"" + value + "\""
-				panic(errMsg)
+				return result, &UnresolvablePlaceholderError{Placeholder: placeholder, Value: value, Chain: chain.keys()}
 			}
-			delete(visitedPlaceholders, originalPlaceholder)
+			chain.pop()
 		} else {
 			startIndex = -1
 		}
 	}
-	return result, visitedPlaceholders
+	return result, nil
 }
 
 /**
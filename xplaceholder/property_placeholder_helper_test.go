@@ -50,3 +50,43 @@ func TestPropertyPlaceholderHelper2_ReplacePlaceholders(t *testing.T) {
 	assert.Equal(t, "你好:--Arvin", helper.ReplacePlaceholders("你好:#{user.no::}--#{user.name}", placeholderResolver))
 	assert.Equal(t, "你好:#{user.no}--Arvin", helper.ReplacePlaceholders("你好:#{user.no}--#{user.name}", placeholderResolver))
 }
+
+func TestPropertyPlaceholderHelper_ReplacePlaceholdersE(t *testing.T) {
+	properties := map[string]string{
+		"circular.var1": "${circular.var2}",
+		"circular.var2": "prefix-${circular.var1}",
+		"url":           "http://${host}:${port}",
+		"host":          "${host.name}",
+		"port":          "8080",
+		"user.name":     "Arvin",
+	}
+	placeholderResolver := func(key string) string {
+		return properties[key]
+	}
+
+	helper := NewPropertyPlaceholderHelper(DefaultPlaceholderPrefix, DefaultPlaceholderSuffix, DefaultPlaceholderValueSeparator, true)
+	value, err := helper.ReplacePlaceholdersE("你好:${user.name}", placeholderResolver)
+	assert.Nil(t, err)
+	assert.Equal(t, "你好:Arvin", value)
+
+	_, err = helper.ReplacePlaceholdersE("${circular.var1}", placeholderResolver)
+	circularErr, ok := err.(*CircularPlaceholderError)
+	assert.True(t, ok)
+	assert.Equal(t, "circular.var1", circularErr.Placeholder)
+	assert.Equal(t, []string{"circular.var1", "circular.var2", "circular.var1"}, circularErr.Chain)
+
+	// 忽略无法识别的占位符
+	value, err = helper.ReplacePlaceholdersE("${url}", placeholderResolver)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://${host.name}:8080", value)
+
+	strictHelper := NewPropertyPlaceholderHelper(DefaultPlaceholderPrefix, DefaultPlaceholderSuffix, DefaultPlaceholderValueSeparator, false)
+	_, err = strictHelper.ReplacePlaceholdersE("${url}", placeholderResolver)
+	unresolvableErr, ok := err.(*UnresolvablePlaceholderError)
+	assert.True(t, ok)
+	assert.Equal(t, "host.name", unresolvableErr.Placeholder)
+	assert.Equal(t, "${host.name}", unresolvableErr.Value)
+	assert.Equal(t, []string{"url", "host", "host.name"}, unresolvableErr.Chain)
+
+	assert.PanicsWithValue(t, err.Error(), func() { strictHelper.ReplacePlaceholders("${url}", placeholderResolver) })
+}